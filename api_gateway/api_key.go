@@ -0,0 +1,460 @@
+package apigateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	ratelimiter "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/rate_limiter"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// APIKeyHeader carries the api key of a machine client.
+	APIKeyHeader = "X-Api-Key"
+	apiKeyPrefix = "ak_"
+	// apiKeyUserPrefix marks the identity of an api key, so services can tell
+	// machine clients from users.
+	apiKeyUserPrefix = "api_key:"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey is an issued key. Only the hash of its secret is kept; the key
+// itself is shown once, when it is created or rotated.
+type APIKey struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+	// PreviousHash is the hash replaced by the last rotation, accepted until
+	// PreviousExpiresAt so clients have time to switch.
+	PreviousHash      string    `json:"previous_hash,omitempty"`
+	PreviousExpiresAt time.Time `json:"previous_expires_at,omitzero"`
+	// Services the key may call, * for every service.
+	Services []string `json:"services"`
+	// Routes are path patterns the key is limited to, any path when empty.
+	Routes []string `json:"routes,omitempty"`
+	// Roles are the roles of the identity forwarded for the key.
+	Roles []string `json:"roles,omitempty"`
+	// RateLimit is the number of requests per window, the configured default
+	// when 0.
+	RateLimit int        `json:"rate_limit,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// allows reports whether the key may make a request matched to match.
+func (k *APIKey) allows(match *RouteMatch, path string) bool {
+	if !slices.Contains(k.Services, "*") && !slices.Contains(k.Services, match.Target.ServiceName) {
+		return false
+	}
+	if len(k.Routes) == 0 {
+		return true
+	}
+	for _, route := range k.Routes {
+		segments, _, err := parsePattern(route)
+		if err != nil {
+			continue
+		}
+		if _, ok := matchSegments(segments, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyStore keeps issued keys by id.
+type APIKeyStore interface {
+	// Get returns ErrAPIKeyNotFound when there is no key with id.
+	Get(ctx context.Context, id string) (*APIKey, error)
+	Save(ctx context.Context, key *APIKey) error
+}
+
+// RedisAPIKeyStore keeps keys in redis as JSON. Revoked keys are kept too.
+type RedisAPIKeyStore struct {
+	client *redis.Client
+}
+
+func NewRedisAPIKeyStore(client *redis.Client) *RedisAPIKeyStore {
+	return &RedisAPIKeyStore{client: client}
+}
+
+func (s *RedisAPIKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	value, err := s.client.Get(ctx, "api_key:"+id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, shared.NewServiceUnavailableError("api key store is not available").WithCause(err)
+	}
+	var key APIKey
+	if err := json.Unmarshal([]byte(value), &key); err != nil {
+		return nil, fmt.Errorf("fail to decode api key %s: %w", id, err)
+	}
+	return &key, nil
+}
+
+func (s *RedisAPIKeyStore) Save(ctx context.Context, key *APIKey) error {
+	value, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("fail to encode api key %s: %w", key.Id, err)
+	}
+	if err := s.client.Set(ctx, "api_key:"+key.Id, value, 0).Err(); err != nil {
+		return shared.NewServiceUnavailableError("api key store is not available").WithCause(err)
+	}
+	return nil
+}
+
+// APIKeyLimiter rate-limits the requests of each key.
+type APIKeyLimiter interface {
+	Allow(ctx context.Context, key *APIKey) (ratelimiter.Result, error)
+}
+
+// RedisAPIKeyLimiter counts the requests of a key with the limiters of a
+// LimiterFactory, so a key is limited in redis and falls back like the rate
+// limit policies while redis is down.
+type RedisAPIKeyLimiter struct {
+	newLimiter LimiterFactory
+	config     configs.APIKeysConfig
+	algorithm  ratelimiter.Algorithm
+
+	mu sync.Mutex
+	// limiters are kept by limit, since a local fallback counts in the
+	// limiter itself
+	limiters map[int]ratelimiter.Limiter
+}
+
+func NewRedisAPIKeyLimiter(newLimiter LimiterFactory, config configs.APIKeysConfig) (*RedisAPIKeyLimiter, error) {
+	algorithm, err := ratelimiter.ParseAlgorithm(config.RateLimitAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisAPIKeyLimiter{
+		newLimiter: newLimiter,
+		config:     config,
+		algorithm:  algorithm,
+		limiters:   map[int]ratelimiter.Limiter{},
+	}, nil
+}
+
+func (l *RedisAPIKeyLimiter) Allow(ctx context.Context, key *APIKey) (ratelimiter.Result, error) {
+	limit := key.RateLimit
+	if limit <= 0 {
+		limit = l.config.DefaultRateLimit
+	}
+	return l.limiter(limit).Allow(ctx, apiKeyUserPrefix+key.Id)
+}
+
+func (l *RedisAPIKeyLimiter) limiter(limit int) ratelimiter.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := l.limiters[limit]
+	if !ok {
+		limiter = l.newLimiter(l.algorithm, limit, l.config.RateLimitWindow)
+		l.limiters[limit] = limiter
+	}
+	return limiter
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Services  []string `json:"services"`
+	Routes    []string `json:"routes"`
+	Roles     []string `json:"roles"`
+	RateLimit int      `json:"rate_limit"`
+}
+
+// APIKeys issues, rotates, revokes and checks api keys.
+type APIKeys struct {
+	store   APIKeyStore
+	limiter APIKeyLimiter
+	config  configs.APIKeysConfig
+}
+
+func NewAPIKeys(store APIKeyStore, limiter APIKeyLimiter, config configs.APIKeysConfig) *APIKeys {
+	return &APIKeys{store: store, limiter: limiter, config: config}
+}
+
+// LoadAPIKeys builds the api keys on redis from apigateway.api_keys, limited
+// by the limiters of newLimiter.
+func LoadAPIKeys(redisClient *redis.Client, newLimiter LimiterFactory) (*APIKeys, error) {
+	config := configs.LoadAPIKeysConfig()
+	limiter, err := NewRedisAPIKeyLimiter(newLimiter, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewAPIKeys(NewRedisAPIKeyStore(redisClient), limiter, config), nil
+}
+
+// Create issues a key and returns it with its secret form, the one the
+// client sends.
+func (k *APIKeys) Create(ctx context.Context, req CreateAPIKeyRequest) (*APIKey, string, error) {
+	if req.Name == "" {
+		return nil, "", shared.NewBadRequestError("api key needs a name")
+	}
+	if len(req.Services) == 0 {
+		return nil, "", shared.NewBadRequestError("api key needs at least one service")
+	}
+	for _, route := range req.Routes {
+		if _, _, err := parsePattern(route); err != nil {
+			return nil, "", shared.NewBadRequestError(err.Error())
+		}
+	}
+	if req.RateLimit < 0 {
+		return nil, "", shared.NewBadRequestError("rate limit must not be negative")
+	}
+	id, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return nil, "", err
+	}
+	hash, plain, err := newSecret(id)
+	if err != nil {
+		return nil, "", err
+	}
+	key := &APIKey{
+		Id:        id,
+		Name:      req.Name,
+		Hash:      hash,
+		Services:  req.Services,
+		Routes:    req.Routes,
+		Roles:     req.Roles,
+		RateLimit: req.RateLimit,
+		CreatedAt: time.Now(),
+	}
+	if err := k.store.Save(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, plain, nil
+}
+
+// Rotate gives the key a new secret. The old one keeps working for the
+// rotation grace period.
+func (k *APIKeys) Rotate(ctx context.Context, id string) (*APIKey, string, error) {
+	key, err := k.get(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if key.RevokedAt != nil {
+		return nil, "", shared.NewConflictError(fmt.Sprintf("api key %s is revoked", id))
+	}
+	hash, plain, err := newSecret(id)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	key.PreviousHash = key.Hash
+	key.PreviousExpiresAt = now.Add(k.config.RotationGrace)
+	key.Hash = hash
+	key.RotatedAt = &now
+	if err := k.store.Save(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, plain, nil
+}
+
+// Revoke stops the key from working at once.
+func (k *APIKeys) Revoke(ctx context.Context, id string) error {
+	key, err := k.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	return k.store.Save(ctx, key)
+}
+
+func (k *APIKeys) get(ctx context.Context, id string) (*APIKey, error) {
+	key, err := k.store.Get(ctx, id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, shared.NewNotFoundError(fmt.Sprintf("api key %s not found", id))
+	}
+	return key, err
+}
+
+// Authenticate returns the key plain is the secret form of. A revoked, unknown
+// or malformed key is a 401.
+func (k *APIKeys) Authenticate(ctx context.Context, plain string) (*APIKey, error) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(plain, apiKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(plain, apiKeyPrefix) {
+		return nil, shared.NewUnauthorizedError("malformed api key")
+	}
+	key, err := k.store.Get(ctx, id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, shared.NewUnauthorizedError("unknown api key")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, shared.NewUnauthorizedError("api key is revoked")
+	}
+	hash := hashSecret(plain)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(key.Hash)) == 1 {
+		return key, nil
+	}
+	if key.PreviousHash != "" && time.Now().Before(key.PreviousExpiresAt) &&
+		subtle.ConstantTimeCompare([]byte(hash), []byte(key.PreviousHash)) == 1 {
+		return key, nil
+	}
+	return nil, shared.NewUnauthorizedError("unknown api key")
+}
+
+// newSecret returns the secret form of a key, ak_<id>_<random>, and its hash.
+func newSecret(id string) (string, string, error) {
+	random, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", "", err
+	}
+	plain := apiKeyPrefix + id + "_" + random
+	return hashSecret(plain), plain, nil
+}
+
+// hashSecret needs no salt or stretching: the secret is random, not a password.
+func hashSecret(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int, encode func([]byte) string) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("fail to generate api key: %w", err)
+	}
+	return encode(b), nil
+}
+
+// APIKeyMiddleware authenticates requests carrying APIKeyHeader, checks the
+// key may call the route and rate-limits it. The identity of the key is then
+// signed and forwarded by AuthMiddleware, which has to come after it along
+// with RouteMiddleware before it. Requests without a key pass through.
+func APIKeyMiddleware(keys *APIKeys) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			plain := r.Header.Get(APIKeyHeader)
+			if plain == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// the secret is for the gateway only
+			r.Header.Del(APIKeyHeader)
+			key, err := keys.Authenticate(r.Context(), plain)
+			if err != nil {
+				writeAppError(w, shared.ToAppError(err))
+				return
+			}
+			match, ok := RouteFromContext(r.Context())
+			if !ok || !key.allows(match, r.URL.Path) {
+				writeAppError(w, shared.NewForbiddenError(fmt.Sprintf("api key %s may not call %s %s", key.Id, r.Method, r.URL.Path)))
+				return
+			}
+			result, err := keys.limiter.Allow(r.Context(), key)
+			if err != nil {
+				writeAppError(w, shared.NewServiceUnavailableError("fail to check the rate limit of the api key").WithCause(err))
+				return
+			}
+			// the limit of the key is the one that matters to its client
+			setRateLimitHeaders(w.Header(), result)
+			if !result.Allowed {
+				writeAppError(w, shared.NewTooManyRequestsError(fmt.Sprintf("api key %s is over its rate limit", key.Id)))
+				return
+			}
+			caller := &identity.Identity{UserId: apiKeyUserPrefix + key.Id, Roles: key.Roles}
+			next.ServeHTTP(w, r.WithContext(identity.NewContext(r.Context(), caller, "")))
+		})
+	}
+}
+
+// APIKeyResponse is an api key as the admin endpoint shows it. Key is the
+// secret form, only set right after it was created or rotated.
+type APIKeyResponse struct {
+	Id        string     `json:"id"`
+	Name      string     `json:"name"`
+	Key       string     `json:"key,omitempty"`
+	Services  []string   `json:"services"`
+	Routes    []string   `json:"routes,omitempty"`
+	Roles     []string   `json:"roles,omitempty"`
+	RateLimit int        `json:"rate_limit,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+}
+
+func toAPIKeyResponse(key *APIKey, plain string) APIKeyResponse {
+	return APIKeyResponse{
+		Id:        key.Id,
+		Name:      key.Name,
+		Key:       plain,
+		Services:  key.Services,
+		Routes:    key.Routes,
+		Roles:     key.Roles,
+		RateLimit: key.RateLimit,
+		CreatedAt: key.CreatedAt,
+		RotatedAt: key.RotatedAt,
+	}
+}
+
+// APIKeyAdminHandler serves the key management endpoints to callers with
+// adminRole, so it runs after AuthMiddleware:
+//   - POST /admin/api-keys creates a key
+//   - POST /admin/api-keys/{id}/rotate gives a key a new secret
+//   - DELETE /admin/api-keys/{id} revokes a key
+func APIKeyAdminHandler(keys *APIKeys, adminRole string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		var req CreateAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAppError(w, shared.NewBadRequestError("invalid request body").WithCause(err))
+			return
+		}
+		key, plain, err := keys.Create(r.Context(), req)
+		if err != nil {
+			writeAppError(w, shared.ToAppError(err))
+			return
+		}
+		writeJSON(w, http.StatusCreated, toAPIKeyResponse(key, plain))
+	})
+	mux.HandleFunc("POST /admin/api-keys/{id}/rotate", func(w http.ResponseWriter, r *http.Request) {
+		key, plain, err := keys.Rotate(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeAppError(w, shared.ToAppError(err))
+			return
+		}
+		writeJSON(w, http.StatusOK, toAPIKeyResponse(key, plain))
+	})
+	mux.HandleFunc("DELETE /admin/api-keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := keys.Revoke(r.Context(), r.PathValue("id")); err != nil {
+			writeAppError(w, shared.ToAppError(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := identity.FromContext(r.Context())
+		if !ok {
+			writeAppError(w, shared.NewUnauthorizedError("request has no identity"))
+			return
+		}
+		if !caller.HasRole(adminRole) {
+			writeAppError(w, shared.NewForbiddenError("managing api keys needs the role "+adminRole))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,246 @@
+package apigateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	auth_api "github.com/hoangdaochuz/ecommerce-microservice-golang/apps/auth/api/auth"
+	cache_pkg "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/cache"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	zitadel_pkg "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/zitadel"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/zitadel/oidc/v3/pkg/crypto"
+)
+
+// SessionStore reads the session the auth service saved at login.
+type SessionStore interface {
+	GetSession(ctx context.Context, sessionId string) (*identity.Identity, error)
+}
+
+// TokenResolver resolves a bearer token to the identity of its owner.
+type TokenResolver interface {
+	ResolveToken(ctx context.Context, token string) (*identity.Identity, error)
+}
+
+// RedisSessionStore reads sessions straight from the redis the auth service
+// keeps them in, so a request with a session cookie costs no call to it.
+type RedisSessionStore struct {
+	client *redis.Client
+}
+
+func NewRedisSessionStore(client *redis.Client) *RedisSessionStore {
+	return &RedisSessionStore{client: client}
+}
+
+// sessionClaims is the part of the claims saved by the auth service the
+// gateway needs.
+type sessionClaims struct {
+	UserId         string   `json:"user_id"`
+	ExternalUserId string   `json:"external_user_id"`
+	Roles          []string `json:"roles"`
+	Scopes         []string `json:"scopes"`
+	TenantId       string   `json:"tenant_id"`
+	ExpiresAt      int64    `json:"exp"`
+}
+
+func (s *RedisSessionStore) GetSession(ctx context.Context, sessionId string) (*identity.Identity, error) {
+	value, err := s.client.Get(ctx, sessionId).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, shared.NewUnauthorizedError("session not found")
+	}
+	if err != nil {
+		return nil, shared.NewServiceUnavailableError("session store is not available").WithCause(err)
+	}
+	var claims sessionClaims
+	if err := json.Unmarshal([]byte(value), &claims); err != nil {
+		return nil, fmt.Errorf("fail to decode session: %w", err)
+	}
+	userId := claims.UserId
+	if userId == "" {
+		// users are not stored by the auth service yet, so most sessions only
+		// know the zitadel user id
+		userId = claims.ExternalUserId
+	}
+	return &identity.Identity{
+		UserId:    userId,
+		Roles:     claims.Roles,
+		Scopes:    claims.Scopes,
+		SessionId: sessionId,
+		TenantId:  claims.TenantId,
+		ExpiresAt: claims.ExpiresAt,
+	}, nil
+}
+
+// NatsTokenResolver asks the auth service to introspect bearer tokens.
+type NatsTokenResolver struct {
+	client *custom_nats.ServiceClient
+}
+
+func NewNatsTokenResolver(natsConn *nats.Conn) *NatsTokenResolver {
+	return &NatsTokenResolver{
+		client: custom_nats.NewServiceClient(natsConn, auth_api.SERVICE_NAME, auth_api.NATS_SUBJECT),
+	}
+}
+
+func (r *NatsTokenResolver) ResolveToken(ctx context.Context, token string) (*identity.Identity, error) {
+	return custom_nats.Call[identity.ResolveTokenRequest, identity.Identity](ctx, r.client, identity.ResolveTokenPath, &identity.ResolveTokenRequest{Token: token})
+}
+
+type AuthenticatorConfig struct {
+	CookieName string
+	// EncryptKey is zitadel_configs.encrypt_key, base64 encoded like the auth
+	// service reads it, which decrypts the session cookie to the session id.
+	EncryptKey string
+	// CacheTTL is how long a resolved identity is reused, 0 disables the cache.
+	CacheTTL time.Duration
+}
+
+// Authenticator resolves the session cookie or the bearer token of a request
+// to the identity forwarded to services.
+type Authenticator struct {
+	config AuthenticatorConfig
+	// encryptKey is the decoded EncryptKey
+	encryptKey string
+	sessions   SessionStore
+	tokens     TokenResolver
+	signer     *identity.Signer
+	cache      *cache_pkg.LocalCache
+}
+
+func NewAuthenticator(config AuthenticatorConfig, sessions SessionStore, tokens TokenResolver, signer *identity.Signer) (*Authenticator, error) {
+	encryptKey, err := zitadel_pkg.DecodeEncryptKey(config.EncryptKey)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode the session cookie encrypt key: %w", err)
+	}
+	return &Authenticator{
+		config:     config,
+		encryptKey: encryptKey,
+		sessions:   sessions,
+		tokens:     tokens,
+		signer:     signer,
+		cache:      cache_pkg.NewLocalCacheWithExpiration(config.CacheTTL, time.Minute),
+	}, nil
+}
+
+// LoadAuthenticator builds the authenticator from zitadel_configs,
+// apigateway.auth and identity.
+func LoadAuthenticator(redisClient *redis.Client, natsConn *nats.Conn) (*Authenticator, error) {
+	signer, err := identity.LoadSigner()
+	if err != nil {
+		return nil, err
+	}
+	return NewAuthenticator(AuthenticatorConfig{
+		CookieName: viper.GetString("zitadel_configs.cookie_name"),
+		EncryptKey: viper.GetString("zitadel_configs.encrypt_key"),
+		CacheTTL:   viper.GetDuration("apigateway.auth.cache_ttl"),
+	}, NewRedisSessionStore(redisClient), NewNatsTokenResolver(natsConn), signer)
+}
+
+// Authenticate returns the identity of r. A bearer token wins over the
+// session cookie. Every failure to prove an identity is a 401.
+func (a *Authenticator) Authenticate(r *http.Request) (*identity.Identity, error) {
+	ctx := r.Context()
+	if token, ok := bearerToken(r); ok {
+		return a.resolve(ctx, "token:"+token, func() (*identity.Identity, error) {
+			return a.tokens.ResolveToken(ctx, token)
+		})
+	}
+	cookie, err := r.Cookie(a.config.CookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, shared.NewUnauthorizedError("no session cookie or bearer token")
+	}
+	sessionId, err := crypto.DecryptAES(cookie.Value, a.encryptKey)
+	if err != nil || sessionId == "" {
+		return nil, shared.NewUnauthorizedError("invalid session cookie")
+	}
+	return a.resolve(ctx, "session:"+sessionId, func() (*identity.Identity, error) {
+		return a.sessions.GetSession(ctx, sessionId)
+	})
+}
+
+// resolve loads the identity behind credential through the cache. Failures
+// are not cached, so a user who just logged in is not kept out.
+func (a *Authenticator) resolve(ctx context.Context, credential string, load func() (*identity.Identity, error)) (*identity.Identity, error) {
+	var id *identity.Identity
+	if a.config.CacheTTL <= 0 {
+		var err error
+		if id, err = load(); err != nil {
+			return nil, err
+		}
+	} else {
+		// keep hashes rather than the credentials themselves in memory
+		sum := sha256.Sum256([]byte(credential))
+		key := hex.EncodeToString(sum[:])
+		value, err := a.cache.GetOrSetWithEx(ctx, key, func() (any, error) {
+			return load()
+		}, int(a.config.CacheTTL.Seconds()))
+		if err != nil {
+			return nil, err
+		}
+		id = value.(*identity.Identity)
+		if id.Expired(time.Now()) {
+			_ = a.cache.Delete(ctx, key)
+		}
+	}
+	if id == nil || id.UserId == "" {
+		return nil, shared.NewUnauthorizedError("credentials do not belong to a user")
+	}
+	if id.Expired(time.Now()) {
+		return nil, shared.NewUnauthorizedError("session expired")
+	}
+	return id, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// AuthMiddleware resolves the identity of every request to a route that
+// needs one and forwards it signed in identity.Header. Identity headers sent
+// by the client are always dropped so they cannot be forged.
+func AuthMiddleware(authenticator *Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Del(identity.Header)
+			r.Header.Del(identity.UserIdHeader)
+			// routes such as login are declared with auth: false in the route
+			// table; a request that was not routed always needs a session
+			if match, ok := RouteFromContext(r.Context()); ok && !match.Route.Auth {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// an api key was checked by APIKeyMiddleware already
+			id, ok := identity.FromContext(r.Context())
+			if !ok {
+				var err error
+				if id, err = authenticator.Authenticate(r); err != nil {
+					writeAppError(w, shared.ToAppError(err))
+					return
+				}
+			}
+			signed, err := authenticator.signer.Sign(*id)
+			if err != nil {
+				writeAppError(w, shared.NewInternalServerError("fail to sign identity").WithCause(err))
+				return
+			}
+			r.Header.Set(identity.Header, signed)
+			next.ServeHTTP(w, r.WithContext(identity.NewContext(r.Context(), id, signed)))
+		})
+	}
+}
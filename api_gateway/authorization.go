@@ -0,0 +1,137 @@
+package apigateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+)
+
+// OwnerCheck reports whether caller owns the resource of r. params are the
+// path parameters of the rule that asked for the check.
+type OwnerCheck func(ctx context.Context, caller *identity.Identity, params map[string]string, r *http.Request) (bool, error)
+
+// OwnerParam is the owner check of resources named by the user id in the
+// path parameter name, or in the query parameter of the same name.
+func OwnerParam(name string) OwnerCheck {
+	return func(ctx context.Context, caller *identity.Identity, params map[string]string, r *http.Request) (bool, error) {
+		userId, ok := params[name]
+		if !ok {
+			userId = r.URL.Query().Get(name)
+		}
+		return userId != "" && userId == caller.UserId, nil
+	}
+}
+
+// DefaultOwnerChecks are the owner checks rules can name out of the box.
+func DefaultOwnerChecks() map[string]OwnerCheck {
+	return map[string]OwnerCheck{
+		"user_id": OwnerParam("userId"),
+	}
+}
+
+type policy struct {
+	rule     configs.AuthorizationRule
+	method   string
+	segments []string
+	owner    OwnerCheck
+}
+
+// PolicyEngine decides whether the caller of a request may make it, by the
+// first rule matching the request.
+type PolicyEngine struct {
+	policies []*policy
+}
+
+func NewPolicyEngine(rules []configs.AuthorizationRule, ownerChecks map[string]OwnerCheck) (*PolicyEngine, error) {
+	engine := &PolicyEngine{}
+	for _, rule := range rules {
+		segments, _, err := parsePattern(rule.Path)
+		if err != nil {
+			return nil, err
+		}
+		p := &policy{rule: rule, method: strings.ToUpper(rule.Method), segments: segments}
+		if rule.Owner != "" {
+			if p.owner = ownerChecks[rule.Owner]; p.owner == nil {
+				return nil, fmt.Errorf("authorization rule %s uses the unknown owner check %q", rule.Path, rule.Owner)
+			}
+		}
+		engine.policies = append(engine.policies, p)
+	}
+	return engine, nil
+}
+
+// LoadPolicyEngine builds the engine from apigateway.authorization.
+func LoadPolicyEngine(ownerChecks map[string]OwnerCheck) (*PolicyEngine, error) {
+	rules, err := configs.LoadAuthorizationRules()
+	if err != nil {
+		return nil, err
+	}
+	return NewPolicyEngine(rules, ownerChecks)
+}
+
+// Authorize returns a 403 AppError when caller may not make r. A request no
+// rule matches is allowed.
+func (e *PolicyEngine) Authorize(ctx context.Context, caller *identity.Identity, r *http.Request) error {
+	for _, p := range e.policies {
+		if p.method != "" && p.method != r.Method {
+			continue
+		}
+		params, ok := matchSegments(p.segments, r.URL.Path)
+		if !ok {
+			continue
+		}
+		return p.authorize(ctx, caller, params, r)
+	}
+	return nil
+}
+
+func (p *policy) authorize(ctx context.Context, caller *identity.Identity, params map[string]string, r *http.Request) error {
+	if len(p.rule.Roles) > 0 && !slices.ContainsFunc(p.rule.Roles, caller.HasRole) {
+		return shared.NewForbiddenError(fmt.Sprintf("%s %s needs one of the roles %s", r.Method, r.URL.Path, strings.Join(p.rule.Roles, ", ")))
+	}
+	for _, scope := range p.rule.Scopes {
+		if !caller.HasScope(scope) {
+			return shared.NewForbiddenError(fmt.Sprintf("%s %s needs the scope %s", r.Method, r.URL.Path, scope))
+		}
+	}
+	if p.owner == nil || slices.ContainsFunc(p.rule.OwnerExemptRoles, caller.HasRole) {
+		return nil
+	}
+	owns, err := p.owner(ctx, caller, params, r)
+	if err != nil {
+		return fmt.Errorf("fail to check the owner of %s: %w", r.URL.Path, err)
+	}
+	if !owns {
+		return shared.NewForbiddenError(fmt.Sprintf("%s %s is only allowed to the owner of the resource", r.Method, r.URL.Path))
+	}
+	return nil
+}
+
+// AuthorizeMiddleware applies engine to the identity AuthMiddleware resolved,
+// so it runs after it. Routes declared with auth: false are not checked.
+func AuthorizeMiddleware(engine *PolicyEngine) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if match, ok := RouteFromContext(r.Context()); ok && !match.Route.Auth {
+				next.ServeHTTP(w, r)
+				return
+			}
+			caller, ok := identity.FromContext(r.Context())
+			if !ok {
+				writeAppError(w, shared.NewUnauthorizedError("request has no identity"))
+				return
+			}
+			if err := engine.Authorize(r.Context(), caller, r); err != nil {
+				writeAppError(w, shared.ToAppError(err))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
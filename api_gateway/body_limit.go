@@ -0,0 +1,36 @@
+package apigateway
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+)
+
+// BodyLimitMiddleware bounds the request body by the max_body_size of the
+// route, defaultLimit when it sets none. A request announcing a larger body
+// in Content-Length is answered 413 before its body is read; reading past the
+// limit otherwise fails when the body is put in the nats envelope. It runs
+// after RouteMiddleware.
+func BodyLimitMiddleware(defaultLimit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := defaultLimit
+			if match, ok := RouteFromContext(r.Context()); ok && match.Route.MaxBodySize > 0 {
+				limit = match.Route.MaxBodySize
+			}
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > limit {
+				// the client would otherwise send the whole body first
+				w.Header().Set("Connection", "close")
+				writeAppError(w, shared.NewPayloadTooLargeError(fmt.Sprintf("request body is larger than %d bytes", limit)))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
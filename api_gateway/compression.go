@@ -0,0 +1,307 @@
+package apigateway
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encoder is what gzip.Writer and zstd.Encoder have in common.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var newEncoders = map[string]func() (encoder, error){
+	"gzip": func() (encoder, error) {
+		return gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+	},
+	"zstd": func() (encoder, error) {
+		return zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	},
+}
+
+// Compression compresses responses with the encoding the client prefers among
+// the configured ones, and decompresses gzip request bodies.
+type Compression struct {
+	config configs.CompressionConfig
+	// pools keep the encoders of every encoding, which are costly to create
+	pools map[string]*sync.Pool
+}
+
+func NewCompression(config configs.CompressionConfig) (*Compression, error) {
+	c := &Compression{config: config, pools: map[string]*sync.Pool{}}
+	for _, encoding := range config.Encodings {
+		newEncoder, ok := newEncoders[encoding]
+		if !ok {
+			return nil, fmt.Errorf("unsupported compression encoding %q", encoding)
+		}
+		c.pools[encoding] = &sync.Pool{New: func() any {
+			enc, err := newEncoder()
+			if err != nil {
+				// the options are fixed, so this does not happen at runtime
+				panic(fmt.Errorf("fail to create %s encoder: %w", encoding, err))
+			}
+			return enc
+		}}
+	}
+	return c, nil
+}
+
+// LoadCompression builds the compression of apigateway.compression.
+func LoadCompression() (*Compression, error) {
+	return NewCompression(configs.LoadCompressionConfig())
+}
+
+// Negotiate picks the encoding of the response to a request with
+// acceptEncoding, "" to send it as is. The client's weights decide, the order
+// of the configured encodings breaks ties.
+func (c *Compression) Negotiate(acceptEncoding string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		weights[strings.ToLower(name)] = weight
+	}
+	best, bestWeight := "", 0.0
+	for _, encoding := range c.config.Encodings {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight = weights["*"]
+		}
+		if weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+	return best
+}
+
+func (c *Compression) compressible(header http.Header) bool {
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(c.config.ContentTypes, func(allowed string) bool {
+		if strings.HasSuffix(allowed, "/") {
+			return strings.HasPrefix(mediaType, allowed)
+		}
+		return mediaType == allowed
+	})
+}
+
+// decompressRequest replaces a gzip body of r with its content, so the
+// service receives it as sent before compression.
+func (c *Compression) decompressRequest(r *http.Request) error {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+	default:
+		return shared.NewUnsupportedMediaError(fmt.Sprintf("request body encoding %s is not supported, use gzip", encoding))
+	}
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return shared.NewBadRequestError("request body is not valid gzip").WithCause(err)
+	}
+	defer reader.Close()
+	// read one byte more than allowed to tell a body of the maximum size
+	// from a larger one
+	body, err := io.ReadAll(io.LimitReader(reader, c.config.MaxDecompressedSize+1))
+	if err != nil {
+		return shared.NewBadRequestError("request body is not valid gzip").WithCause(err)
+	}
+	if int64(len(body)) > c.config.MaxDecompressedSize {
+		return shared.NewPayloadTooLargeError(fmt.Sprintf("decompressed request body is larger than %d bytes", c.config.MaxDecompressedSize))
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.Header.Del("Content-Encoding")
+	return nil
+}
+
+// CompressionMiddleware decompresses gzip request bodies and, when enabled,
+// compresses responses of the allowed content types from MinSize bytes.
+func CompressionMiddleware(c *Compression) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := c.decompressRequest(r); err != nil {
+				writeAppError(w, shared.ToAppError(err))
+				return
+			}
+			encoding := c.Negotiate(r.Header.Get("Accept-Encoding"))
+			if !c.config.Enabled || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressResponseWriter{ResponseWriter: w, compression: c, encoding: encoding}
+			next.ServeHTTP(cw, r)
+			if err := cw.finish(); err != nil {
+				logging.GetSugaredLogger().Errorf("fail to finish compressed response: %v", err)
+			}
+		})
+	}
+}
+
+type compressState int
+
+const (
+	// compressPending buffers the body until it reaches MinSize
+	compressPending compressState = iota
+	compressOff
+	compressOn
+)
+
+// compressResponseWriter holds back the status and the first MinSize bytes
+// of the body, to send small bodies as they are.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	compression *Compression
+	encoding    string
+
+	state       compressState
+	wroteHeader bool
+	statusCode  int
+	buf         []byte
+	encoder     encoder
+}
+
+func (cw *compressResponseWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	if statusCode < http.StatusOK {
+		// informational responses are not the final one
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	cw.wroteHeader = true
+	cw.statusCode = statusCode
+	header := cw.Header()
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified || !cw.compression.compressible(header) {
+		cw.skip()
+		return
+	}
+	// the response depends on Accept-Encoding even when sent as is
+	header.Add("Vary", "Accept-Encoding")
+	if cw.encoding == "" {
+		cw.skip()
+		return
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < cw.compression.config.MinSize {
+		cw.skip()
+	}
+}
+
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	switch cw.state {
+	case compressOff:
+		return cw.ResponseWriter.Write(p)
+	case compressOn:
+		return cw.encoder.Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.compression.config.MinSize {
+		if err := cw.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends what was written so far. A body still held back is compressed
+// from here on, as a flushed response is streamed and its size unknown.
+func (cw *compressResponseWriter) Flush() {
+	if cw.wroteHeader && cw.state == compressPending {
+		if err := cw.start(); err != nil {
+			logging.GetSugaredLogger().Errorf("fail to start compressed response: %v", err)
+			return
+		}
+	}
+	if cw.state == compressOn {
+		if err := cw.encoder.Flush(); err != nil {
+			logging.GetSugaredLogger().Errorf("fail to flush compressed response: %v", err)
+			return
+		}
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// skip sends the response as is.
+func (cw *compressResponseWriter) skip() {
+	cw.state = compressOff
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+}
+
+func (cw *compressResponseWriter) start() error {
+	header := cw.Header()
+	header.Set("Content-Encoding", cw.encoding)
+	header.Del("Content-Length")
+	// the compressed body is another representation of the same content
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+	cw.state = compressOn
+	cw.encoder = cw.compression.pools[cw.encoding].Get().(encoder)
+	cw.encoder.Reset(cw.ResponseWriter)
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+	buf := cw.buf
+	cw.buf = nil
+	_, err := cw.encoder.Write(buf)
+	return err
+}
+
+// finish writes a body that stayed under MinSize as is, or ends the
+// compressed stream.
+func (cw *compressResponseWriter) finish() error {
+	switch cw.state {
+	case compressPending:
+		if !cw.wroteHeader {
+			return nil
+		}
+		cw.skip()
+		_, err := cw.ResponseWriter.Write(cw.buf)
+		return err
+	case compressOn:
+		err := cw.encoder.Close()
+		cw.encoder.Reset(nil)
+		cw.compression.pools[cw.encoding].Put(cw.encoder)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package apigateway
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Route priorities, from the last to the first shed.
+const (
+	PriorityCritical = "critical"
+	PriorityNormal   = "normal"
+	PriorityLow      = "low"
+)
+
+// OtherServices is the limit shared by the services not listed in
+// apigateway.concurrency.services. The service comes from the request path,
+// so a limit and metric labels of its own would grow with every name a
+// client makes up.
+const OtherServices = "other"
+
+// ConcurrencyLimits bounds the requests in flight to each service with a
+// limit adapted to its latency. A nil ConcurrencyLimits admits everything.
+type ConcurrencyLimits struct {
+	config configs.ConcurrencyConfig
+
+	// known are the services with a limit of their own
+	known map[string]bool
+
+	mu       sync.Mutex
+	services map[string]*serviceConcurrency
+
+	limit    *prometheus.GaugeVec
+	inFlight *prometheus.GaugeVec
+	shed     *prometheus.CounterVec
+}
+
+type serviceConcurrency struct {
+	limit    float64
+	inFlight int
+}
+
+func NewConcurrencyLimits(config configs.ConcurrencyConfig, registry *prometheus.Registry) (*ConcurrencyLimits, error) {
+	if config.MinLimit < 1 || config.MaxLimit < config.MinLimit || config.InitialLimit < config.MinLimit || config.InitialLimit > config.MaxLimit {
+		return nil, fmt.Errorf("concurrency limits %d <= %d <= %d are not valid", config.MinLimit, config.InitialLimit, config.MaxLimit)
+	}
+	if config.Backoff <= 0 || config.Backoff >= 1 {
+		return nil, fmt.Errorf("concurrency backoff %v must be between 0 and 1", config.Backoff)
+	}
+	if config.LatencyThreshold <= 0 {
+		return nil, fmt.Errorf("concurrency latency threshold %s is not valid", config.LatencyThreshold)
+	}
+	for priority, share := range config.Priorities {
+		if share <= 0 || share > 1 {
+			return nil, fmt.Errorf("concurrency share %v of priority %s must be between 0 and 1", share, priority)
+		}
+	}
+	known := make(map[string]bool, len(config.Services))
+	for _, service := range config.Services {
+		known[service] = true
+	}
+	limits := &ConcurrencyLimits{
+		config:   config,
+		known:    known,
+		services: map[string]*serviceConcurrency{},
+		limit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "concurrency_limit",
+			Help: "Requests allowed in flight to a service",
+		}, []string{"service"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "concurrency_in_flight",
+			Help: "Requests in flight to a service",
+		}, []string{"service"}),
+		shed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "concurrency_shed_total",
+			Help: "Requests shed over the concurrency limit of a service",
+		}, []string{"service", "priority"}),
+	}
+	if registry != nil {
+		registry.MustRegister(limits.limit, limits.inFlight, limits.shed)
+	}
+	return limits, nil
+}
+
+// LoadConcurrencyLimits builds the limits of apigateway.concurrency, nil when
+// disabled.
+func LoadConcurrencyLimits(registry *prometheus.Registry) (*ConcurrencyLimits, error) {
+	config, err := configs.LoadConcurrencyConfig()
+	if err != nil {
+		return nil, err
+	}
+	if !config.Enabled {
+		return nil, nil
+	}
+	return NewConcurrencyLimits(config, registry)
+}
+
+// Acquire admits a request of priority to service while fewer requests than
+// the share of the limit the priority may fill are in flight. A service not
+// listed in the config counts against OtherServices. The permit must be
+// finished once the service replied.
+func (c *ConcurrencyLimits) Acquire(service, priority string) (*ConcurrencyPermit, bool) {
+	if c == nil {
+		return nil, true
+	}
+	share, ok := c.config.Priorities[priority]
+	if !ok {
+		share = 1
+	}
+	if !c.known[service] {
+		service = OtherServices
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.services[service]
+	if !ok {
+		state = &serviceConcurrency{limit: float64(c.config.InitialLimit)}
+		c.services[service] = state
+		c.limit.WithLabelValues(service).Set(float64(int(state.limit)))
+	}
+	// one request always goes through, or a small limit would shed a
+	// priority entirely
+	if state.inFlight > 0 && float64(state.inFlight) >= float64(int(state.limit))*share {
+		c.shed.WithLabelValues(service, priority).Inc()
+		return nil, false
+	}
+	state.inFlight++
+	c.inFlight.WithLabelValues(service).Set(float64(state.inFlight))
+	return &ConcurrencyPermit{limits: c, service: service}, true
+}
+
+// ConcurrencyPermit is a request admitted to a service.
+type ConcurrencyPermit struct {
+	limits  *ConcurrencyLimits
+	service string
+	once    sync.Once
+}
+
+// Done releases the permit and adapts the limit to the reply: a failure or a
+// reply slower than the threshold lowers it, a quick one raises it while the
+// limit is in use.
+func (p *ConcurrencyPermit) Done(latency time.Duration, failed bool) {
+	p.finish(func(state *serviceConcurrency, config configs.ConcurrencyConfig) {
+		if failed || latency > config.LatencyThreshold {
+			state.limit = max(state.limit*config.Backoff, float64(config.MinLimit))
+			return
+		}
+		// an idle service says nothing about how much more it could take
+		if float64(state.inFlight) >= state.limit/2 {
+			state.limit = min(state.limit+1/state.limit, float64(config.MaxLimit))
+		}
+	})
+}
+
+// Release releases the permit without judging the service, e.g. when the
+// client went away before the reply.
+func (p *ConcurrencyPermit) Release() {
+	p.finish(nil)
+}
+
+func (p *ConcurrencyPermit) finish(adapt func(state *serviceConcurrency, config configs.ConcurrencyConfig)) {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() {
+		c := p.limits
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		state := c.services[p.service]
+		if adapt != nil {
+			adapt(state, c.config)
+			c.limit.WithLabelValues(p.service).Set(float64(int(state.limit)))
+		}
+		state.inFlight--
+		c.inFlight.WithLabelValues(p.service).Set(float64(state.inFlight))
+	})
+}
+
+// writeShed answers a request over the concurrency limit of service.
+func (c *ConcurrencyLimits) writeShed(w http.ResponseWriter, service string) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(c.config.RetryAfter), 1)))
+	writeAppError(w, shared.NewServiceUnavailableError(fmt.Sprintf("service %s is overloaded", service)).WithRetryable(true))
+}
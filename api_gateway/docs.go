@@ -0,0 +1,126 @@
+package apigateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/openapi"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/spf13/viper"
+)
+
+// docsSpecFile is served under the docs path next to the UI.
+const docsSpecFile = "/openapi.json"
+
+// Names of the security schemes of the documented credentials.
+const (
+	sessionSecurityScheme = "session"
+	bearerSecurityScheme  = "bearer"
+	apiKeySecurityScheme  = "apiKey"
+)
+
+var docsPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "{{.SpecURL}}", dom_id: "#swagger-ui", withCredentials: true });
+  </script>
+</body>
+</html>
+`))
+
+// Docs serves the OpenAPI document of every service and a UI to browse it.
+type Docs struct {
+	path  string
+	title string
+	spec  []byte
+}
+
+// NewDocs serves document at path. Operations of routes needing a caller are
+// marked with the session, bearer and api key credentials the gateway takes.
+func NewDocs(path string, document *openapi.Document, routes *RouteTable, cookieName string) (*Docs, error) {
+	if document.Components.SecuritySchemes == nil {
+		document.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{}
+	}
+	document.Components.SecuritySchemes[sessionSecurityScheme] = &openapi.SecurityScheme{Type: "apiKey", In: "cookie", Name: cookieName}
+	document.Components.SecuritySchemes[bearerSecurityScheme] = &openapi.SecurityScheme{Type: "http", Scheme: "bearer"}
+	document.Components.SecuritySchemes[apiKeySecurityScheme] = &openapi.SecurityScheme{Type: "apiKey", In: "header", Name: APIKeyHeader}
+	for specPath, item := range document.Paths {
+		if item.Post == nil {
+			continue
+		}
+		match, err := routes.Match(http.MethodPost, specPath)
+		if err != nil {
+			// the gateway does not expose the method
+			delete(document.Paths, specPath)
+			continue
+		}
+		if match.Route.Auth {
+			item.Post.Security = []openapi.SecurityRequirement{
+				{sessionSecurityScheme: {}},
+				{bearerSecurityScheme: {}},
+				{apiKeySecurityScheme: {}},
+			}
+		}
+	}
+	spec, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("fail to marshal openapi document: %w", err)
+	}
+	return &Docs{path: strings.TrimSuffix(path, "/"), title: document.Info.Title, spec: spec}, nil
+}
+
+// LoadDocs merges the documents of apigateway.docs.specs, nil when disabled.
+func LoadDocs(routes *RouteTable) (*Docs, error) {
+	config := configs.LoadDocsConfig()
+	if !config.Enabled {
+		return nil, nil
+	}
+	documents := []*openapi.Document{}
+	for _, spec := range config.Specs {
+		document, err := openapi.ReadFile(spec)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	merged, err := openapi.Merge(openapi.Info{Title: config.Title, Version: "v1"}, documents...)
+	if err != nil {
+		return nil, err
+	}
+	return NewDocs(config.Path, merged, routes, viper.GetString("zitadel_configs.cookie_name"))
+}
+
+// Path is where the UI is served; the document is at Path + /openapi.json.
+func (d *Docs) Path() string {
+	return d.path
+}
+
+func (d *Docs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAppError(w, shared.NewMethodNotAllowedError(fmt.Sprintf("method %s is not allowed on %s", r.Method, r.URL.Path)))
+		return
+	}
+	switch r.URL.Path {
+	case d.path, d.path + "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = docsPage.Execute(w, map[string]string{"Title": d.title, "SpecURL": d.path + docsSpecFile})
+	case d.path + docsSpecFile:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(d.spec)
+	default:
+		writeAppError(w, shared.NewNotFoundError(fmt.Sprintf("no document at %s", r.URL.Path)))
+	}
+}
@@ -0,0 +1,313 @@
+package apigateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/spf13/viper"
+	"golang.org/x/net/websocket"
+)
+
+// Event is a message published to a user event subject.
+type Event struct {
+	// Id orders the events of a user; a client resumes after it.
+	Id string
+	// Topic is the subject after events.user.<user id>., e.g. order.status.
+	Topic string
+	Data  []byte
+}
+
+// EventSource delivers the events of a user until ctx is done, starting
+// after lastEventId, or with the next event when it is "".
+type EventSource interface {
+	Subscribe(ctx context.Context, userId, lastEventId string) (<-chan Event, error)
+}
+
+// JetStreamEventSource reads the events of a user from a JetStream stream
+// over events.user.>, so the stream sequence serves as event id.
+type JetStreamEventSource struct {
+	natsConn  *nats.Conn
+	stream    string
+	retention time.Duration
+
+	mu sync.Mutex
+	js jetstream.JetStream
+}
+
+func NewJetStreamEventSource(natsConn *nats.Conn, stream string, retention time.Duration) *JetStreamEventSource {
+	return &JetStreamEventSource{natsConn: natsConn, stream: stream, retention: retention}
+}
+
+// jetStream creates the stream on first use.
+func (s *JetStreamEventSource) jetStream(ctx context.Context) (jetstream.JetStream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.js != nil {
+		return s.js, nil
+	}
+	js, err := jetstream.New(s.natsConn)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create jetstream context: %w", err)
+	}
+	if _, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     s.stream,
+		Subjects: []string{custom_nats.UserEventsPrefix + ">"},
+		MaxAge:   s.retention,
+	}); err != nil {
+		return nil, fmt.Errorf("fail to create stream %s: %w", s.stream, err)
+	}
+	s.js = js
+	return js, nil
+}
+
+func (s *JetStreamEventSource) Subscribe(ctx context.Context, userId, lastEventId string) (<-chan Event, error) {
+	filter, err := custom_nats.UserEventsFilter(userId)
+	if err != nil {
+		return nil, err
+	}
+	config := jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{filter},
+		DeliverPolicy:  jetstream.DeliverNewPolicy,
+	}
+	if lastEventId != "" {
+		seq, err := strconv.ParseUint(lastEventId, 10, 64)
+		if err != nil {
+			return nil, shared.NewBadRequestError(fmt.Sprintf("last event id %q is not valid", lastEventId))
+		}
+		config.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		config.OptStartSeq = seq + 1
+	}
+	js, err := s.jetStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := js.OrderedConsumer(ctx, s.stream, config)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create event consumer: %w", err)
+	}
+	prefix := filter[:len(filter)-1]
+	events := make(chan Event)
+	consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
+		metadata, err := msg.Metadata()
+		if err != nil {
+			logging.GetSugaredLogger().Warnf("fail to read event metadata: %v", err)
+			return
+		}
+		event := Event{
+			Id:    strconv.FormatUint(metadata.Sequence.Stream, 10),
+			Topic: strings.TrimPrefix(msg.Subject(), prefix),
+			Data:  msg.Data(),
+		}
+		// the consumer waits for a slow client rather than skip its events
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fail to consume events: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		consumeCtx.Stop()
+	}()
+	return events, nil
+}
+
+// EventBridge forwards the events of the caller to a WebSocket or, for any
+// other request, a Server-Sent Events stream.
+type EventBridge struct {
+	source        EventSource
+	authenticator *Authenticator
+	config        configs.EventsConfig
+	// allowedOrigin is the only browser origin allowed to open a WebSocket
+	allowedOrigin string
+
+	mu          sync.Mutex
+	connections map[string]int
+}
+
+func NewEventBridge(source EventSource, authenticator *Authenticator, config configs.EventsConfig, allowedOrigin string) *EventBridge {
+	return &EventBridge{
+		source:        source,
+		authenticator: authenticator,
+		config:        config,
+		allowedOrigin: allowedOrigin,
+		connections:   map[string]int{},
+	}
+}
+
+// LoadEventBridge builds the bridge of apigateway.events on JetStream.
+func LoadEventBridge(natsConn *nats.Conn, authenticator *Authenticator) *EventBridge {
+	config := configs.LoadEventsConfig()
+	source := NewJetStreamEventSource(natsConn, config.Stream, config.Retention)
+	return NewEventBridge(source, authenticator, config, viper.GetString("general_config.frontend_user_endpoint"))
+}
+
+// acquire takes one of the connections of userId, false when all are used.
+func (b *EventBridge) acquire(userId string) (func(), bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.config.MaxConnectionsPerUser > 0 && b.connections[userId] >= b.config.MaxConnectionsPerUser {
+		return nil, false
+	}
+	b.connections[userId]++
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.connections[userId]--; b.connections[userId] <= 0 {
+			delete(b.connections, userId)
+		}
+	}, true
+}
+
+func (b *EventBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	caller, err := b.authenticator.Authenticate(r)
+	if err != nil {
+		writeAppError(w, shared.ToAppError(err))
+		return
+	}
+	release, ok := b.acquire(caller.UserId)
+	if !ok {
+		writeAppError(w, shared.NewTooManyRequestsError(fmt.Sprintf("at most %d event connections per user", b.config.MaxConnectionsPerUser)))
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	if caller.ExpiresAt > 0 {
+		// the connection ends with the session
+		ctx, cancel = context.WithDeadline(ctx, time.Unix(caller.ExpiresAt, 0))
+		defer cancel()
+	}
+	// browsers send Last-Event-ID when an EventSource reconnects; WebSocket
+	// clients cannot set headers, so they use the query string
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = r.URL.Query().Get("last_event_id")
+	}
+	events, err := b.source.Subscribe(ctx, caller.UserId, lastEventId)
+	if err != nil {
+		writeAppError(w, shared.ToAppError(err))
+		return
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		b.serveWebSocket(ctx, cancel, w, r, events)
+		return
+	}
+	b.serveSSE(ctx, w, events)
+}
+
+func (b *EventBridge) serveSSE(ctx context.Context, w http.ResponseWriter, events <-chan Event) {
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// keep proxies from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	controller := http.NewResponseController(w)
+	write := func(frame string) bool {
+		if _, err := io.WriteString(w, frame); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+	if !write(fmt.Sprintf("retry: %d\n\n", b.config.ReconnectDelay.Milliseconds())) {
+		return
+	}
+
+	heartbeat := time.NewTicker(b.config.HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			if !write(sseFrame(event)) {
+				return
+			}
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		}
+	}
+}
+
+func sseFrame(event Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "id: %s\nevent: %s\n", event.Id, event.Topic)
+	for _, line := range strings.Split(string(event.Data), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// EventMessage is what a WebSocket client receives, an event or a heartbeat.
+type EventMessage struct {
+	Type  string          `json:"type"`
+	Id    string          `json:"id,omitempty"`
+	Topic string          `json:"topic,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+func (b *EventBridge) serveWebSocket(ctx context.Context, cancel context.CancelFunc, w http.ResponseWriter, r *http.Request, events <-chan Event) {
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			origin := r.Header.Get("Origin")
+			if origin != "" && b.allowedOrigin != "" && origin != b.allowedOrigin {
+				return fmt.Errorf("origin %s is not allowed", origin)
+			}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+			// clients send nothing, reading only notices when they leave
+			go func() {
+				_, _ = io.Copy(io.Discard, conn)
+				cancel()
+			}()
+			send := func(message EventMessage) bool {
+				_ = conn.SetWriteDeadline(time.Now().Add(b.config.HeartbeatInterval))
+				return websocket.JSON.Send(conn, message) == nil
+			}
+
+			heartbeat := time.NewTicker(b.config.HeartbeatInterval)
+			defer heartbeat.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case event := <-events:
+					data := json.RawMessage(event.Data)
+					if !json.Valid(data) {
+						data, _ = json.Marshal(string(event.Data))
+					}
+					if !send(EventMessage{Type: "event", Id: event.Id, Topic: event.Topic, Data: data}) {
+						return
+					}
+				case <-heartbeat.C:
+					if !send(EventMessage{Type: "heartbeat"}) {
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(w, r)
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,9 +16,9 @@ import (
 	di "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/dependency-injection"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/metric"
-	ratelimiter "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/rate_limiter"
 	redis_pkg "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/redis"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/tracing"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
 	"github.com/nats-io/nats.go"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
@@ -25,9 +27,19 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 )
 
+const defaultRequestTimeout = 30 * time.Second
+
 type APIGateway struct {
 	natsConn *nats.Conn
-	timeout  time.Duration
+	routes   *RouteTable
+	// responseCache is nil when no backend could be set up
+	responseCache *ResponseCache
+	// concurrency is nil when the concurrency limits are disabled
+	concurrency *ConcurrencyLimits
+	// bodies holds request bodies too large for a nats message
+	bodies *custom_nats.BodyStore
+	// policies is the request policy table, loaded once at start
+	policies configs.RequestPolicies
 	server   *http.Server
 	mux      *http.ServeMux
 	ctx      context.Context
@@ -37,7 +49,7 @@ type APIGateway struct {
 func NewAPIGateway(natsConn *nats.Conn, server *http.Server, mux *http.ServeMux, ctx context.Context) *APIGateway {
 	gateway := &APIGateway{
 		natsConn: natsConn,
-		timeout:  30 * time.Second,
+		bodies:   custom_nats.LoadBodyStore(natsConn),
 		server:   server,
 		mux:      mux,
 		ctx:      ctx,
@@ -46,21 +58,32 @@ func NewAPIGateway(natsConn *nats.Conn, server *http.Server, mux *http.ServeMux,
 }
 
 func (gw *APIGateway) sendErrorResponse(w http.ResponseWriter, err string, statusCode int) {
+	gw.sendAppError(w, shared.NewAppError(shared.ErrorTypeFromStatusCode(statusCode), err))
+}
+
+func (gw *APIGateway) sendAppError(w http.ResponseWriter, appErr *shared.AppError) {
+	writeAppError(w, appErr)
+}
+
+func writeAppError(w http.ResponseWriter, appErr *shared.AppError) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	errResponse := map[string]string{
-		"error": err,
-	}
-	if encodeErr := json.NewEncoder(w).Encode(errResponse); encodeErr != nil {
-		logging.GetSugaredLogger().Errorf("failed to encode error response: %v", encodeErr)
+	w.WriteHeader(appErr.StatusCode)
+	if _, err := w.Write(appErr.ToJSON()); err != nil {
+		logging.GetSugaredLogger().Errorf("failed to encode error response: %v", err)
 	}
 }
 
-func (gw *APIGateway) writeResponse(w http.ResponseWriter, response custom_nats.Response) {
-	// Set default content type
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logging.GetSugaredLogger().Errorf("failed to encode response: %v", err)
+	}
+}
 
+func copyResponseHeaders(w http.ResponseWriter, headers http.Header) {
 	// Copy headers from response but skip Content-Length
-	for key, items := range response.Headers {
+	for key, items := range headers {
 		// Skip headers that should be handled by Go HTTP server
 		if key == "Content-Length" || key == "Transfer-Encoding" {
 			continue
@@ -69,6 +92,19 @@ func (gw *APIGateway) writeResponse(w http.ResponseWriter, response custom_nats.
 			w.Header().Add(key, v)
 		}
 	}
+}
+
+func (gw *APIGateway) writeResponse(w http.ResponseWriter, response custom_nats.Response) {
+	copyResponseHeaders(w, response.Headers)
+	// A typed error from the service decides the status code and, when the
+	// service did not write one, the body.
+	if response.Error != nil {
+		if len(response.Body) == 0 {
+			gw.sendAppError(w, response.Error)
+			return
+		}
+		response.StatusCode = response.Error.StatusCode
+	}
 	w.WriteHeader(response.StatusCode)
 	_, err := w.Write(response.Body)
 	if err != nil {
@@ -78,9 +114,7 @@ func (gw *APIGateway) writeResponse(w http.ResponseWriter, response custom_nats.
 
 func (gw *APIGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Here is entry point for api gateway
-	timeoutCtx, cancel := context.WithTimeout(r.Context(), gw.timeout)
-	defer cancel()
-	ctx, span := tracing.SpanContext(timeoutCtx, r.Header, fmt.Sprintf("outbound request: %s", r.URL.Path))
+	ctx, span := tracing.SpanContext(r.Context(), r.Header, fmt.Sprintf("outbound request: %s", r.URL.Path))
 	defer span.End()
 	// r, err := http.NewRequestWithContext(ctx, r.Method, r.URL.Path, r.Body)
 	// if err != nil {
@@ -89,16 +123,40 @@ func (gw *APIGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	r = r.WithContext(ctx)
 	tracing.InjectTraceIntoHttpReq(ctx, r)
 
-	natsReq, err := custom_nats.HttpRequestToNatsRequest(*r)
+	match, ok := RouteFromContext(ctx)
+	if !ok {
+		var err error
+		if match, err = gw.routes.Match(r.Method, r.URL.Path); err != nil {
+			gw.sendAppError(w, shared.ToAppError(err))
+			return
+		}
+	}
+	cacheKey, cacheable := gw.responseCache.Key(ctx, r, match)
+	if cacheable {
+		if cached, ok := gw.responseCache.Get(ctx, r, cacheKey); ok {
+			writeCachedResponse(w, r, cached)
+			return
+		}
+	}
+	natsReq, err := custom_nats.HttpRequestToNatsRequestWithTarget(*r, match.Target)
 	if err != nil {
-		gw.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		// a malformed query string comes back as a typed 400
+		gw.sendAppError(w, shared.ToAppError(err))
 		return
 	}
-	// Add necessary information to header for updating to context and use it if we need
-	natsReq.AddHeader("X-User-Id", "test@1234")
+	serviceName := natsReq.GetServiceName()
+	permit, ok := gw.concurrency.Acquire(serviceName, match.Route.Priority)
+	if !ok {
+		gw.concurrency.writeShed(w, serviceName)
+		return
+	}
+	// a no-op once the reply judged the service
+	defer permit.Release()
+	policy := gw.policies.Lookup(serviceName, r.URL.Path)
+	timeoutCtx, cancel := context.WithTimeout(ctx, requestTimeout(r, policy))
+	defer cancel()
 	// continue add if we want
 
-	serviceName := natsReq.GetServiceName()
 	circuitBreakerConfigService := configs.LoadNatsCircuitBreakerConfigByServiceName(serviceName)
 	cbRegistry := circuitbreaker.GetRegistry[*nats.Msg]()
 	breaker, err := cbRegistry.GetOrCreateBreaker(serviceName, circuitbreaker.ToCircuitBreakerConfig(serviceName, circuitBreakerConfigService))
@@ -109,36 +167,87 @@ func (gw *APIGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	natsConnWithCircuitBreakerWrapper := custom_nats.NewNatsConnWithCircuitBreaker(gw.natsConn, breaker)
 
-	// natsSubject := natsReq.Subject
-	natsReqByte, err := json.Marshal(*natsReq)
+	codec, err := custom_nats.GetEnvelopeCodec(configs.LoadEnvelopeEncodingByServiceName(serviceName))
+	if err != nil {
+		gw.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	objectName, err := gw.bodies.Offload(timeoutCtx, natsReq, gw.natsConn.MaxPayload())
 	if err != nil {
-		gw.sendErrorResponse(w, fmt.Errorf("fail to marshal nats request: %w", err).Error(), http.StatusInternalServerError)
+		gw.sendAppError(w, shared.NewServiceUnavailableError("fail to hand off the request body").WithCause(err))
 		return
 	}
-	natsSendRequest := &custom_nats.NatsSendRequest{
-		Subject: natsReq.Subject,
-		Content: natsReqByte,
+	if objectName != "" {
+		// every attempt reads the same object, so it goes once all are done
+		defer func() {
+			if err := gw.bodies.Delete(context.Background(), objectName); err != nil {
+				logging.GetSugaredLogger().Warnf("%v", err)
+			}
+		}()
+	}
+	// every attempt is encoded again, so the service learns the budget left
+	// at the time it was sent
+	send := func(ctx context.Context) (*nats.Msg, *custom_nats.ReplyStream, error) {
+		attemptReq := *natsReq
+		attemptReq.Header = maps.Clone(natsReq.Header)
+		// let the service know how long we are going to wait for the reply, so
+		// its handler is cancelled once we have given up
+		if deadline, ok := ctx.Deadline(); ok {
+			attemptReq.SetHeader(custom_nats.RequestTimeoutHeader, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+		}
+		natsReqByte, err := codec.MarshalRequest(&attemptReq)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fail to marshal nats request: %w", err)
+		}
+		sendReq := &custom_nats.NatsSendRequest{
+			Subject: natsReq.Subject,
+			Content: natsReqByte,
+			Header:  custom_nats.EnvelopeHeader(codec),
+		}
+		if match.Route.Stream {
+			return natsConnWithCircuitBreakerWrapper.SendStreamRequest(ctx, sendReq)
+		}
+		// a unary request waits on the shared inbox of the connection, which
+		// spares a subscription per request
+		msg, err := natsConnWithCircuitBreakerWrapper.SendRequest(ctx, sendReq)
+		return msg, nil, err
 	}
-	// logging.GetSugaredLogger().Infof("Sending request ")
 	start := time.Now()
-	msgResponse, err := natsConnWithCircuitBreakerWrapper.SendRequest(timeoutCtx, natsSendRequest)
-
+	reply, err := SendWithPolicy(timeoutCtx, policy, send)
+	if r.Context().Err() == nil {
+		// a client leaving early tells nothing about the service
+		permit.Done(time.Since(start), err != nil || isOverloadReply(reply))
+	}
 	if err != nil {
 		// set span attribute error
 		tracing.SetSpanError(span, err)
-		gw.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
-		logging.GetSugaredLogger().Errorf("%s %s %v statusCode: %v traceId: %s", r.Method, r.URL.Path, time.Since(start), http.StatusInternalServerError, span.SpanContext().TraceID().String())
+		appErr := shared.ToAppError(custom_nats.TransportError(serviceName, err))
+		gw.sendAppError(w, appErr)
+		logging.GetSugaredLogger().Errorf("%s %s %v statusCode: %v traceId: %s", r.Method, r.URL.Path, time.Since(start), appErr.StatusCode, span.SpanContext().TraceID().String())
 		return
 	}
+	defer func() { _ = reply.Close() }()
 
-	var natsResponse custom_nats.Response
-	err = json.Unmarshal(msgResponse.Data, &natsResponse)
-	if err != nil {
-		tracing.SetSpanError(span, err)
-		gw.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
-		logging.GetSugaredLogger().Errorf("%s %s %v statusCode: %v traceId: %s", r.Method, r.URL.Path, time.Since(start), http.StatusInternalServerError, span.SpanContext().TraceID().String())
+	if reply.Response == nil {
+		statusCode, err := gw.writeStream(timeoutCtx, w, reply.Msg, reply.Replies)
+		span.SetAttributes(
+			semconv.HTTPMethod(r.Method),
+			semconv.HTTPRoute(r.URL.Path),
+			semconv.HTTPScheme(r.URL.Scheme),
+			semconv.HTTPStatusCode(statusCode),
+		)
+		if err != nil {
+			tracing.SetSpanError(span, err)
+			logging.GetSugaredLogger().Errorf("%s %s %v stream aborted: %v traceId: %s", r.Method, r.URL.Path, time.Since(start), err, span.SpanContext().TraceID().String())
+			// the status line is already sent, so cut the connection to keep
+			// the client from taking a truncated body as complete
+			panic(http.ErrAbortHandler)
+		}
+		logging.GetSugaredLogger().Infof("%s %s %v statusCode: %v streamed traceId: %s", r.Method, r.URL.Path, time.Since(start), statusCode, span.SpanContext().TraceID().String())
 		return
 	}
+
+	natsResponse := *reply.Response
 	if strings.Contains(r.URL.Path, "Logout") {
 		// clear cookie
 		http.SetCookie(w, &http.Cookie{
@@ -151,6 +260,9 @@ func (gw *APIGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			SameSite: http.SameSiteNoneMode,
 		})
 	}
+	if natsResponse.Error != nil && natsResponse.Error.StatusCode >= http.StatusInternalServerError {
+		tracing.SetSpanError(span, natsResponse.Error)
+	}
 	span.SetAttributes(
 		semconv.HTTPMethod(r.Method),
 		semconv.HTTPRoute(r.URL.Path),
@@ -158,9 +270,85 @@ func (gw *APIGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		semconv.HTTPStatusCode(natsResponse.StatusCode),
 	)
 	logging.GetSugaredLogger().Infof("%s %s %v statusCode: %v traceId: %s", r.Method, r.URL.Path, time.Since(start), natsResponse.StatusCode, span.SpanContext().TraceID().String())
+	if cacheable {
+		if etag := gw.responseCache.Store(ctx, cacheKey, match, &natsResponse); etagMatches(r.Header.Get("If-None-Match"), etag) {
+			copyResponseHeaders(w, natsResponse.Headers)
+			writeNotModified(w)
+			return
+		}
+	}
 	gw.writeResponse(w, natsResponse)
 }
 
+// writeStream forwards a streamed response: the head frame gives the status
+// and headers, then every chunk is written and flushed as it arrives until the
+// end frame. An error frame, a gap in the sequence or a timeout is returned
+// after the status was sent.
+func (gw *APIGateway) writeStream(ctx context.Context, w http.ResponseWriter, head *nats.Msg, replies *custom_nats.ReplyStream) (int, error) {
+	var response custom_nats.Response
+	codec, err := custom_nats.EnvelopeCodecFromHeader(head.Header)
+	if err == nil {
+		err = codec.UnmarshalResponse(head.Data, &response)
+	}
+	if err != nil {
+		gw.sendErrorResponse(w, fmt.Errorf("fail to decode stream head: %w", err).Error(), http.StatusBadGateway)
+		return http.StatusBadGateway, nil
+	}
+	copyResponseHeaders(w, response.Headers)
+	w.WriteHeader(response.StatusCode)
+	controller := http.NewResponseController(w)
+	_ = controller.Flush()
+
+	for seq := 1; ; seq++ {
+		frame, err := replies.Next(ctx)
+		if err != nil {
+			return response.StatusCode, fmt.Errorf("fail to receive stream frame: %w", err)
+		}
+		if frame.Header.Get(custom_nats.StreamSeqHeader) != strconv.Itoa(seq) {
+			return response.StatusCode, fmt.Errorf("stream frame %s arrived, expected %d", frame.Header.Get(custom_nats.StreamSeqHeader), seq)
+		}
+		switch frame.Header.Get(custom_nats.StreamFrameHeader) {
+		case custom_nats.StreamFrameChunk:
+			if _, err := w.Write(frame.Data); err != nil {
+				return response.StatusCode, fmt.Errorf("fail to write stream chunk: %w", err)
+			}
+			_ = controller.Flush()
+		case custom_nats.StreamFrameEnd:
+			return response.StatusCode, nil
+		case custom_nats.StreamFrameError:
+			appErr := &shared.AppError{}
+			if err := json.Unmarshal(frame.Data, appErr); err != nil {
+				return response.StatusCode, fmt.Errorf("fail to decode stream error: %w", err)
+			}
+			return response.StatusCode, appErr
+		default:
+			return response.StatusCode, fmt.Errorf("unexpected stream frame %q", frame.Header.Get(custom_nats.StreamFrameHeader))
+		}
+	}
+}
+
+// isOverloadReply tells whether the service answered that it cannot keep up.
+func isOverloadReply(reply *Reply) bool {
+	if reply == nil || reply.Response == nil || reply.Response.Error == nil {
+		return false
+	}
+	statusCode := reply.Response.Error.StatusCode
+	return statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
+
+// requestTimeout is the timeout of the policy, shortened to the budget the
+// client sent in X-Request-Timeout when that is smaller.
+func requestTimeout(r *http.Request, policy configs.RequestPolicy) time.Duration {
+	timeout := policy.Timeout
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	if budgetMs, err := strconv.ParseInt(r.Header.Get(custom_nats.RequestTimeoutHeader), 10, 64); err == nil && budgetMs > 0 {
+		timeout = min(timeout, time.Duration(budgetMs)*time.Millisecond)
+	}
+	return timeout
+}
+
 func useMiddleware(handler http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
 	return MiddlewareChain(handler, middlewares...)
 }
@@ -172,18 +360,75 @@ func (gw *APIGateway) Start() error {
 		logging.GetSugaredLogger().Fatalf("failed to load configuration: %v", err)
 		return err
 	}
+	gw.routes, err = LoadRouteTable()
+	if err != nil {
+		logging.GetSugaredLogger().Errorf("failed to load route table: %v", err)
+		return err
+	}
+	if gw.policies, err = configs.LoadRequestPolicies(); err != nil {
+		logging.GetSugaredLogger().Errorf("failed to load request policies: %v", err)
+		return err
+	}
 	otlpEndpoint := viper.GetString("general_config.otlp_endpoint")
 	var redisClient *redis.Client
 	_ = di.Resolve(func(redisPkg *redis_pkg.Redis) {
 		redisClient = redisPkg.GetClient()
 	})
 	defer redisClient.Close()
-	rateLimiter := ratelimiter.NewRateLimiter(redisClient, 50, 1*time.Minute, gw.ctx)
-
 	registryWrapper := metric.NewMetricWrapper()
 	registryWrapper.RegisterCollectorDefault()
 	registry := registryWrapper.GetRegistry()
 
+	newLimiter, err := LoadLimiterFactory(redisClient, registry)
+	if err != nil {
+		logging.GetSugaredLogger().Errorf("failed to create rate limiters: %v", err)
+		return err
+	}
+	rateLimitPolicies, err := LoadRateLimitPolicies(newLimiter)
+	if err != nil {
+		logging.GetSugaredLogger().Errorf("failed to load rate limit policies: %v", err)
+		return err
+	}
+	if gw.concurrency, err = LoadConcurrencyLimits(registry); err != nil {
+		logging.GetSugaredLogger().Errorf("failed to load concurrency limits: %v", err)
+		return err
+	}
+	authenticator, err := LoadAuthenticator(redisClient, gw.natsConn)
+	if err != nil {
+		logging.GetSugaredLogger().Errorf("failed to create authenticator: %v", err)
+		return err
+	}
+	apiKeys, err := LoadAPIKeys(redisClient, newLimiter)
+	if err != nil {
+		logging.GetSugaredLogger().Errorf("failed to load api keys: %v", err)
+		return err
+	}
+	eventBridge := LoadEventBridge(gw.natsConn, authenticator)
+	policyEngine, err := LoadPolicyEngine(DefaultOwnerChecks())
+	if err != nil {
+		logging.GetSugaredLogger().Errorf("failed to load authorization rules: %v", err)
+		return err
+	}
+	compression, err := LoadCompression()
+	if err != nil {
+		logging.GetSugaredLogger().Errorf("failed to load compression: %v", err)
+		return err
+	}
+	docs, err := LoadDocs(gw.routes)
+	if err != nil {
+		// a missing or broken document must not keep the gateway down
+		logging.GetSugaredLogger().Warnf("api docs disabled: %v", err)
+		docs = nil
+	}
+	if gw.responseCache, err = LoadResponseCache(); err != nil {
+		// the gateway still works without the cache, every request just goes
+		// to the service
+		logging.GetSugaredLogger().Warnf("response cache disabled: %v", err)
+	} else if _, err := gw.responseCache.SubscribeInvalidations(gw.natsConn); err != nil {
+		logging.GetSugaredLogger().Errorf("failed to subscribe to cache invalidations: %v", err)
+		return err
+	}
+
 	shutdownTracing, err := tracing.InitializeTraceRegistry(&tracing.TracingConfig{
 		ServiceName: "api_gateway",
 		// Attributes:,
@@ -201,8 +446,8 @@ func (gw *APIGateway) Start() error {
 	rootHandler := otelhttp.NewHandler(gw, "", otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
 		return r.URL.Path
 	}))
-	_ = useMiddleware(rootHandler, CorsMiddleware, ContentTypeMiddleware, RateLimitMiddleware(rateLimiter))
-	protectResourceHandler := useMiddleware(rootHandler, CorsMiddleware, ContentTypeMiddleware, RateLimitMiddleware(rateLimiter), MetricMiddleware(registry), AuthMiddleware)
+	protectResourceHandler := useMiddleware(rootHandler, CorsMiddleware, CompressionMiddleware(compression), ContentTypeMiddleware, RouteMiddleware(gw.routes), BodyLimitMiddleware(configs.LoadMaxBodySize()), MetricMiddleware(registry), ClientRateLimitMiddleware(rateLimitPolicies), APIKeyMiddleware(apiKeys), AuthMiddleware(authenticator), RateLimitMiddleware(rateLimitPolicies), AuthorizeMiddleware(policyEngine))
+	apiKeyAdminHandler := useMiddleware(APIKeyAdminHandler(apiKeys, configs.LoadAPIKeysConfig().AdminRole), CorsMiddleware, ContentTypeMiddleware, BodyLimitMiddleware(configs.LoadMaxBodySize()), MetricMiddleware(registry), ClientRateLimitMiddleware(rateLimitPolicies), AuthMiddleware(authenticator), RateLimitMiddleware(rateLimitPolicies))
 	healthCheckHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_ = json.NewEncoder(w).Encode(map[string]string{
@@ -212,7 +457,16 @@ func (gw *APIGateway) Start() error {
 	healthResourceHanlder := useMiddleware(healthCheckHandler, CorsMiddleware, ContentTypeMiddleware, MetricMiddleware(registry))
 	gw.mux.Handle("/", protectResourceHandler)
 	gw.mux.Handle("/health", healthResourceHanlder)
+	// event connections stay open, so they skip the metrics of requests
+	gw.mux.Handle(configs.LoadEventsConfig().Path, useMiddleware(eventBridge, CorsMiddleware))
+	gw.mux.Handle("/admin/api-keys", apiKeyAdminHandler)
+	gw.mux.Handle("/admin/api-keys/", apiKeyAdminHandler)
 	gw.mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	if docs != nil {
+		docsHandler := useMiddleware(docs, CorsMiddleware, CompressionMiddleware(compression), MetricMiddleware(registry))
+		gw.mux.Handle(docs.Path(), docsHandler)
+		gw.mux.Handle(docs.Path()+"/", docsHandler)
+	}
 	errChan := make(chan error, 1)
 
 	go func() {
@@ -2,16 +2,11 @@ package apigateway
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
-	"slices"
 	"time"
 
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/metric/httpmiddleware"
-	ratelimiter "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/rate_limiter"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/tracing"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
@@ -50,58 +45,6 @@ func ContentTypeMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		url := r.URL.Path
-		skipAuthPaths := []string{
-			"/api/v1/auth/Login",
-			"/api/v1/auth/Callback",
-			"/callback",
-		}
-		if slices.Contains(skipAuthPaths, url) {
-			next.ServeHTTP(w, r)
-			return
-		}
-		cookieKey := viper.GetString("zitadel_configs.cookie_name")
-		cookie, err := r.Cookie(cookieKey)
-		if err != nil || cookie.Value == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			bodyByte, err := json.Marshal(map[string]string{
-				"error": "Not found cookie",
-			})
-			if err != nil {
-				log.Fatal("fail to marshal err response")
-			}
-			_, _ = w.Write(bodyByte)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
-func RateLimitMiddleware(rateLimiter *ratelimiter.RateLimiter) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			clientIP := r.RemoteAddr
-			uri := r.URL.Path
-			key := clientIP + uri
-			isAllow, err := rateLimiter.IsAllow(key)
-			if err != nil || !isAllow {
-				// return 429 code
-				w.WriteHeader(http.StatusTooManyRequests)
-				_ = json.NewEncoder(w).Encode(map[string]string{
-					"error": err.Error(),
-				})
-				return
-			}
-			limit := rateLimiter.GetLimit()
-			currentNumRequest := rateLimiter.GetCurrentNumberRequest()
-			w.Header().Set("X-Request-Remaining", fmt.Sprintf("%d", limit-currentNumRequest))
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
 func MiddlewareChain(handler http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
 	for i := len(middlewares) - 1; i >= 0; i-- {
 		handler = middlewares[i](handler)
@@ -0,0 +1,184 @@
+package apigateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
+	"github.com/nats-io/nats.go"
+)
+
+// Reply is the first reply of a service to a request sent with SendWithPolicy.
+type Reply struct {
+	Msg     *nats.Msg
+	Replies *custom_nats.ReplyStream
+	// Response is the decoded reply, nil when Msg is the head frame of a
+	// streamed response.
+	Response *custom_nats.Response
+}
+
+func (r *Reply) Close() error {
+	if r == nil || r.Replies == nil {
+		return nil
+	}
+	return r.Replies.Close()
+}
+
+// SendFunc sends one copy of a request and waits for its first reply.
+type SendFunc func(ctx context.Context) (*nats.Msg, *custom_nats.ReplyStream, error)
+
+// SendWithPolicy sends a request the way policy says. A request of an
+// idempotent policy is hedged after policy.HedgeDelay and retried with backoff
+// while the service is unavailable; any other request is sent exactly once.
+// Every request goes over NATS, so its HTTP method says nothing about whether
+// it is safe to send twice.
+func SendWithPolicy(ctx context.Context, policy configs.RequestPolicy, send SendFunc) (*Reply, error) {
+	if !policy.IsIdempotent() {
+		return receive(send(ctx))
+	}
+	for attempt := 0; ; attempt++ {
+		reply, err := sendHedged(ctx, policy, send)
+		if attempt >= policy.GetRetries() || !shouldRetry(reply, err) {
+			return reply, err
+		}
+		delay := backoff(policy, attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			// no time left for another attempt, answer with this one
+			return reply, err
+		}
+		_ = reply.Close()
+		logging.GetSugaredLogger().Warnf("service unavailable, retry attempt %d in %v", attempt+1, delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// sendHedged sends a copy of the request, and another one each time
+// policy.HedgeDelay passes without a usable reply, at most policy.MaxHedges
+// more. The first usable reply wins and the others are closed.
+func sendHedged(ctx context.Context, policy configs.RequestPolicy, send SendFunc) (*Reply, error) {
+	maxHedges := policy.GetMaxHedges()
+	if policy.HedgeDelay <= 0 || maxHedges <= 0 {
+		return receive(send(ctx))
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan sendResult, maxHedges+1)
+	launched, pending := 0, 0
+	launch := func() {
+		launched++
+		pending++
+		go func() {
+			reply, err := receive(send(ctx))
+			results <- sendResult{reply: reply, err: err}
+		}()
+	}
+	launch()
+	timer := time.NewTimer(policy.HedgeDelay)
+	defer timer.Stop()
+
+	var last sendResult
+	for {
+		select {
+		case <-timer.C:
+			if launched <= maxHedges {
+				launch()
+				timer.Reset(policy.HedgeDelay)
+			}
+		case res := <-results:
+			pending--
+			if !shouldRetry(res.reply, res.err) {
+				_ = last.reply.Close()
+				go closeReplies(results, pending)
+				return res.reply, res.err
+			}
+			_ = last.reply.Close()
+			last = res
+			if launched <= maxHedges {
+				// no reason to wait for the delay once a copy has failed
+				launch()
+				timer.Reset(policy.HedgeDelay)
+			} else if pending == 0 {
+				return last.reply, last.err
+			}
+		}
+	}
+}
+
+type sendResult struct {
+	reply *Reply
+	err   error
+}
+
+// closeReplies waits for the copies still in flight and closes their replies.
+func closeReplies(results <-chan sendResult, pending int) {
+	for range pending {
+		res := <-results
+		_ = res.reply.Close()
+	}
+}
+
+// receive decodes the first reply unless it opens a streamed response.
+func receive(msg *nats.Msg, replies *custom_nats.ReplyStream, err error) (*Reply, error) {
+	if err != nil {
+		return nil, err
+	}
+	reply := &Reply{Msg: msg, Replies: replies}
+	if msg.Header.Get(custom_nats.StreamFrameHeader) == custom_nats.StreamFrameHead {
+		if replies == nil {
+			// the frames after the head went to the shared inbox and are lost
+			return nil, errors.New("service streamed the response of a route without stream")
+		}
+		return reply, nil
+	}
+	// an older service ignores the encoding header and answers in JSON, so
+	// decode by what the reply announces rather than by what was sent
+	var response custom_nats.Response
+	codec, err := custom_nats.EnvelopeCodecFromHeader(msg.Header)
+	if err == nil {
+		err = codec.UnmarshalResponse(msg.Data, &response)
+	}
+	if err != nil {
+		_ = reply.Close()
+		return nil, fmt.Errorf("fail to decode response: %w", err)
+	}
+	reply.Response = &response
+	return reply, nil
+}
+
+// shouldRetry is true when no instance took the request: nobody listened or
+// the one that got it was overloaded. A timeout or an open circuit breaker is
+// not retried since another attempt would fail the same way.
+func shouldRetry(reply *Reply, err error) bool {
+	if err != nil {
+		return errors.Is(err, nats.ErrNoResponders)
+	}
+	return reply.Response != nil && reply.Response.StatusCode == http.StatusServiceUnavailable
+}
+
+// backoff doubles policy.Backoff on each attempt up to policy.MaxBackoff,
+// with full jitter so retries of many requests do not arrive together.
+func backoff(policy configs.RequestPolicy, attempt int) time.Duration {
+	delay := policy.Backoff
+	if delay <= 0 {
+		return 0
+	}
+	for range attempt {
+		delay *= 2
+		if policy.MaxBackoff > 0 && delay >= policy.MaxBackoff {
+			delay = policy.MaxBackoff
+			break
+		}
+	}
+	return time.Duration(rand.Int64N(int64(delay)) + 1)
+}
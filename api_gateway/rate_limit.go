@@ -0,0 +1,478 @@
+package apigateway
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/circuitbreaker"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
+	ratelimiter "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/rate_limiter"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// Keys a rate limit policy counts requests by.
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyUser   = "user"
+	RateLimitKeyAPIKey = "api_key"
+	RateLimitKeyTenant = "tenant"
+)
+
+// LimiterFactory builds the limiter of one rule.
+type LimiterFactory func(algorithm ratelimiter.Algorithm, limit int, window time.Duration) ratelimiter.Limiter
+
+// RedisLimiterFactory counts every rule in redis.
+func RedisLimiterFactory(redisClient *redis.Client) LimiterFactory {
+	return func(algorithm ratelimiter.Algorithm, limit int, window time.Duration) ratelimiter.Limiter {
+		return ratelimiter.NewRateLimiter(redisClient, algorithm, limit, window)
+	}
+}
+
+// Modes of deciding requests while redis fails.
+const (
+	RateLimitFallbackLocal  = "local"
+	RateLimitFallbackOpen   = "open"
+	RateLimitFallbackClosed = "closed"
+)
+
+// RateLimitMetrics reports the rate limiter running without redis.
+type RateLimitMetrics struct {
+	fallbacks *prometheus.CounterVec
+}
+
+// NewRateLimitMetrics registers rate_limit_degraded, 1 while breaker keeps
+// requests away from redis, and rate_limit_fallback_total, the requests
+// decided without redis by mode and result.
+func NewRateLimitMetrics(registry *prometheus.Registry, breaker *circuitbreaker.Breaker[ratelimiter.Result]) *RateLimitMetrics {
+	metrics := &RateLimitMetrics{
+		fallbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rate_limit_fallback_total",
+			Help: "Requests rate limited without redis",
+		}, []string{"mode", "result"}),
+	}
+	degraded := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "rate_limit_degraded",
+		Help: "Whether the rate limiter runs without redis",
+	}, func() float64 {
+		if breaker.IsClose() {
+			return 0
+		}
+		return 1
+	})
+	registry.MustRegister(metrics.fallbacks, degraded)
+	return metrics
+}
+
+// observedLimiter counts the decisions of a fallback limiter.
+type observedLimiter struct {
+	limiter ratelimiter.Limiter
+	mode    string
+	metrics *RateLimitMetrics
+}
+
+func (l *observedLimiter) Allow(ctx context.Context, key string) (ratelimiter.Result, error) {
+	result, err := l.limiter.Allow(ctx, key)
+	if l.metrics != nil {
+		outcome := "allowed"
+		if err != nil {
+			outcome = "error"
+		} else if !result.Allowed {
+			outcome = "denied"
+		}
+		l.metrics.fallbacks.WithLabelValues(l.mode, outcome).Inc()
+	}
+	return result, err
+}
+
+// Peek is not counted, every request is already counted once by Allow.
+func (l *observedLimiter) Peek(ctx context.Context, key string) (ratelimiter.Result, error) {
+	return l.limiter.Peek(ctx, key)
+}
+
+// FallbackLimiterFactory builds the limiters of primary behind breaker. While
+// redis fails the mode of config decides: local counts each limit in memory,
+// divided between the instances, open allows and closed answers 503.
+func FallbackLimiterFactory(primary LimiterFactory, config configs.RateLimitFallbackConfig, breaker *circuitbreaker.Breaker[ratelimiter.Result], metrics *RateLimitMetrics) (LimiterFactory, error) {
+	if config.Instances < 1 {
+		return nil, fmt.Errorf("rate limit fallback needs at least 1 instance, got %d", config.Instances)
+	}
+	var newFallback func(limit int, window time.Duration) ratelimiter.Limiter
+	switch config.Mode {
+	case RateLimitFallbackLocal:
+		newFallback = func(limit int, window time.Duration) ratelimiter.Limiter {
+			share := (limit + config.Instances - 1) / config.Instances
+			return ratelimiter.NewLocalRateLimiter(share, window)
+		}
+	case RateLimitFallbackOpen:
+		newFallback = func(limit int, window time.Duration) ratelimiter.Limiter {
+			return ratelimiter.NewOpenLimiter(limit, window)
+		}
+	case RateLimitFallbackClosed:
+		newFallback = func(limit int, window time.Duration) ratelimiter.Limiter {
+			return ratelimiter.ClosedLimiter{}
+		}
+	default:
+		return nil, fmt.Errorf("unknown rate limit fallback mode %q", config.Mode)
+	}
+	return func(algorithm ratelimiter.Algorithm, limit int, window time.Duration) ratelimiter.Limiter {
+		fallback := &observedLimiter{limiter: newFallback(limit, window), mode: config.Mode, metrics: metrics}
+		return ratelimiter.NewFallbackLimiter(primary(algorithm, limit, window), fallback, breaker)
+	}, nil
+}
+
+type rateLimitPolicy struct {
+	method   string
+	segments []string
+	// prefix policies match every path under segments
+	prefix bool
+	// scope keeps the counts of the policy apart from the others
+	scope  string
+	key    string
+	limits []ratelimiter.Limiter
+}
+
+func (p *rateLimitPolicy) matches(r *http.Request) bool {
+	if p.method != "" && p.method != r.Method {
+		return false
+	}
+	if !p.prefix {
+		_, ok := matchSegments(p.segments, r.URL.Path)
+		return ok
+	}
+	if len(p.segments) == 0 {
+		return true
+	}
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(segments) <= len(p.segments) {
+		return false
+	}
+	_, ok := matchSegments(p.segments, "/"+strings.Join(segments[:len(p.segments)], "/"))
+	return ok
+}
+
+// RateLimitPolicies limits each request by the first policy matching it.
+type RateLimitPolicies struct {
+	policies       []*rateLimitPolicy
+	plans          map[string][]ratelimiter.Limiter
+	planRolePrefix string
+	defaultPlan    string
+	trustedProxies []netip.Prefix
+	// preAuth limits every client address before its credentials are checked
+	preAuth []ratelimiter.Limiter
+}
+
+func NewRateLimitPolicies(config configs.RateLimitConfig, newLimiter LimiterFactory) (*RateLimitPolicies, error) {
+	defaultAlgorithm, err := ratelimiter.ParseAlgorithm(config.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	buildLimits := func(rules []configs.RateLimitRule) ([]ratelimiter.Limiter, error) {
+		limits := []ratelimiter.Limiter{}
+		for _, rule := range rules {
+			algorithm := defaultAlgorithm
+			if rule.Algorithm != "" {
+				if algorithm, err = ratelimiter.ParseAlgorithm(rule.Algorithm); err != nil {
+					return nil, err
+				}
+			}
+			if rule.Limit <= 0 || rule.Window < time.Millisecond {
+				return nil, fmt.Errorf("rate limit of %d per %s is not valid", rule.Limit, rule.Window)
+			}
+			limits = append(limits, newLimiter(algorithm, rule.Limit, rule.Window))
+		}
+		return limits, nil
+	}
+
+	policies := &RateLimitPolicies{
+		plans:          map[string][]ratelimiter.Limiter{},
+		planRolePrefix: config.PlanRolePrefix,
+		defaultPlan:    config.DefaultPlan,
+	}
+	for name, rules := range config.Plans {
+		if policies.plans[name], err = buildLimits(rules); err != nil {
+			return nil, fmt.Errorf("rate limit plan %s: %w", name, err)
+		}
+	}
+	if policies.preAuth, err = buildLimits(config.PreAuth); err != nil {
+		return nil, fmt.Errorf("pre auth rate limit: %w", err)
+	}
+	if _, ok := policies.plans[config.DefaultPlan]; config.DefaultPlan != "" && !ok {
+		return nil, fmt.Errorf("default rate limit plan %q is not defined", config.DefaultPlan)
+	}
+	for _, policyConfig := range config.Policies {
+		policy := &rateLimitPolicy{
+			method: strings.ToUpper(policyConfig.Method),
+			scope:  strings.TrimSpace(strings.ToUpper(policyConfig.Method) + " " + policyConfig.Path),
+			key:    policyConfig.Key,
+		}
+		pattern, prefix := strings.CutSuffix(policyConfig.Path, "/*")
+		if policy.prefix = prefix; !prefix || pattern != "" {
+			if policy.segments, _, err = parsePattern(pattern); err != nil {
+				return nil, err
+			}
+		}
+		switch policy.key {
+		case "":
+			policy.key = RateLimitKeyIP
+		case RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyAPIKey, RateLimitKeyTenant:
+		default:
+			return nil, fmt.Errorf("rate limit policy %s has the unknown key %q", policyConfig.Path, policyConfig.Key)
+		}
+		if policy.limits, err = buildLimits(policyConfig.Limits); err != nil {
+			return nil, fmt.Errorf("rate limit policy %s: %w", policyConfig.Path, err)
+		}
+		if len(policy.limits) == 0 && len(policies.plans) == 0 {
+			return nil, fmt.Errorf("rate limit policy %s has no limits and no plan is defined", policyConfig.Path)
+		}
+		policies.policies = append(policies.policies, policy)
+	}
+	for _, proxy := range config.TrustedProxies {
+		prefix, err := parseAddressRange(proxy)
+		if err != nil {
+			return nil, err
+		}
+		policies.trustedProxies = append(policies.trustedProxies, prefix)
+	}
+	return policies, nil
+}
+
+// LoadLimiterFactory builds the limiters counting in redisClient behind the
+// redis circuit breaker, falling back as apigateway.rate_limit.fallback says.
+// The rate limit policies and the api keys share it, so both degrade the same
+// way when redis is down.
+func LoadLimiterFactory(redisClient *redis.Client, registry *prometheus.Registry) (LimiterFactory, error) {
+	config, err := configs.LoadRateLimitConfig()
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := circuitbreaker.GetRegistry[ratelimiter.Result]().GetOrCreateBreaker(shared.REDIS_CIRCUIT_BREAKER, circuitbreaker.ToCircuitBreakerConfig(shared.REDIS_CIRCUIT_BREAKER, configs.LoadDatabaseCircuitBreakerConfig()))
+	if err != nil {
+		return nil, err
+	}
+	return FallbackLimiterFactory(RedisLimiterFactory(redisClient), config.Fallback, breaker, NewRateLimitMetrics(registry, breaker))
+}
+
+// LoadRateLimitPolicies builds the policies of apigateway.rate_limit with the
+// limiters of newLimiter.
+func LoadRateLimitPolicies(newLimiter LimiterFactory) (*RateLimitPolicies, error) {
+	config, err := configs.LoadRateLimitConfig()
+	if err != nil {
+		return nil, err
+	}
+	return NewRateLimitPolicies(config, newLimiter)
+}
+
+func parseAddressRange(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("trusted proxy %q is not valid: %w", value, err)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("trusted proxy %q is not valid: %w", value, err)
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+func (p *RateLimitPolicies) trusted(addr netip.Addr) bool {
+	for _, prefix := range p.trustedProxies {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientAddress is the address of the client of r. Behind trusted proxies it
+// is the last address of X-Forwarded-For not added by one of them, since
+// anything left of it may be forged by the client.
+func (p *RateLimitPolicies) ClientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !p.trusted(remote) {
+		return host
+	}
+	hops := []string{}
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !p.trusted(client) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// countKey names what the requests of r are counted by, falling back to the
+// client address for anonymous callers.
+func (p *RateLimitPolicies) countKey(r *http.Request, key string) string {
+	caller, ok := identity.FromContext(r.Context())
+	if ok {
+		switch key {
+		case RateLimitKeyUser:
+			return "user:" + caller.UserId
+		case RateLimitKeyAPIKey:
+			if strings.HasPrefix(caller.UserId, apiKeyUserPrefix) {
+				return caller.UserId
+			}
+		case RateLimitKeyTenant:
+			if caller.TenantId != "" {
+				return "tenant:" + caller.TenantId
+			}
+			return "user:" + caller.UserId
+		}
+	}
+	return "ip:" + p.ClientAddress(r)
+}
+
+// plan returns the limits of the plan of the caller of ctx.
+func (p *RateLimitPolicies) plan(ctx context.Context) (string, []ratelimiter.Limiter) {
+	if caller, ok := identity.FromContext(ctx); ok && p.planRolePrefix != "" {
+		for _, role := range caller.Roles {
+			name, ok := strings.CutPrefix(role, p.planRolePrefix)
+			if limits, defined := p.plans[name]; ok && defined {
+				return name, limits
+			}
+		}
+	}
+	return p.defaultPlan, p.plans[p.defaultPlan]
+}
+
+// Allow counts r against every limit of its policy. It returns the result of
+// the limit that denied it or, when all allowed it, of the one with the least
+// room left; false when no policy limits r.
+func (p *RateLimitPolicies) Allow(r *http.Request) (ratelimiter.Result, bool, error) {
+	for _, policy := range p.policies {
+		if !policy.matches(r) {
+			continue
+		}
+		scope := policy.scope
+		limits := policy.limits
+		if len(limits) == 0 {
+			var plan string
+			plan, limits = p.plan(r.Context())
+			scope += " plan:" + plan
+		}
+		return allowAll(r.Context(), scope+"|"+p.countKey(r, policy.key), limits)
+	}
+	return ratelimiter.Result{}, false, nil
+}
+
+// AllowClient counts r against the pre auth limits of its client address.
+func (p *RateLimitPolicies) AllowClient(r *http.Request) (ratelimiter.Result, bool, error) {
+	return allowAll(r.Context(), "pre_auth|ip:"+p.ClientAddress(r), p.preAuth)
+}
+
+// allowAll counts the request under key against every limit and answers with
+// the tightest. Stacked limits are peeked first and only counted when all of
+// them allow the request, so a denied request takes nothing from the others.
+// Two requests racing between the peek and the count may still both be
+// counted by the first limits when the last one has room for only one.
+func allowAll(ctx context.Context, key string, limits []ratelimiter.Limiter) (ratelimiter.Result, bool, error) {
+	if len(limits) > 1 {
+		for _, limiter := range limits {
+			result, err := limiter.Peek(ctx, key)
+			if err != nil {
+				return ratelimiter.Result{}, true, err
+			}
+			if !result.Allowed {
+				return result, true, nil
+			}
+		}
+	}
+	var tightest ratelimiter.Result
+	for i, limiter := range limits {
+		result, err := limiter.Allow(ctx, key)
+		if err != nil {
+			return ratelimiter.Result{}, true, err
+		}
+		if !result.Allowed {
+			return result, true, nil
+		}
+		if i == 0 || result.Remaining < tightest.Remaining {
+			tightest = result
+		}
+	}
+	return tightest, len(limits) > 0, nil
+}
+
+// RateLimitMiddleware limits requests by the policies. It runs after the
+// caller is known, so requests can be counted per user, api key or tenant.
+// Every limited response tells the client its quota in the RateLimit headers.
+func RateLimitMiddleware(policies *RateLimitPolicies) func(http.Handler) http.Handler {
+	return rateLimitMiddleware(policies.Allow)
+}
+
+// ClientRateLimitMiddleware limits the requests of each client address by the
+// pre auth limits. It runs before the credentials are checked, so a client
+// cannot send bad ones without bound.
+func ClientRateLimitMiddleware(policies *RateLimitPolicies) func(http.Handler) http.Handler {
+	return rateLimitMiddleware(policies.AllowClient)
+}
+
+func rateLimitMiddleware(allow func(r *http.Request) (ratelimiter.Result, bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, limited, err := allow(r)
+			if err != nil {
+				logging.GetSugaredLogger().Errorf("fail to check rate limit: %v", err)
+				writeAppError(w, shared.NewServiceUnavailableError("fail to check the rate limit").WithCause(err))
+				return
+			}
+			if limited {
+				setRateLimitHeaders(w.Header(), result)
+				if !result.Allowed {
+					writeAppError(w, shared.NewTooManyRequestsError("too many requests"))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setRateLimitHeaders writes the RateLimit headers of the IETF draft, and
+// Retry-After when the request is denied. Times are in whole seconds,
+// rounded up so a client waiting them is not denied again.
+func setRateLimitHeaders(header http.Header, result ratelimiter.Result) {
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(time.Until(result.ResetAt))))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", result.Limit, ceilSeconds(result.Window)))
+	if !result.Allowed {
+		header.Set("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,282 @@
+package apigateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	cache_pkg "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/cache"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	di "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/dependency-injection"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
+	"github.com/nats-io/nats.go"
+)
+
+// CacheStatusHeader tells the client whether the response came from the cache.
+const CacheStatusHeader = "X-Cache"
+
+// CachedResponse is a response kept by ResponseCache.
+type CachedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers"`
+	Body       []byte      `json:"body"`
+	ETag       string      `json:"etag"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+// ResponseCache keeps the responses of GET requests to routes with a cache
+// setting. A tag is invalidated by changing its version, which is part of the
+// key of every response cached under it, so any cache_pkg.Cache works.
+type ResponseCache struct {
+	cache cache_pkg.Cache
+}
+
+func NewResponseCache(cache cache_pkg.Cache) *ResponseCache {
+	return &ResponseCache{cache: cache}
+}
+
+// LoadResponseCache builds the cache on the backend of apigateway.response_cache.
+func LoadResponseCache() (*ResponseCache, error) {
+	switch backend := configs.LoadResponseCacheConfig().Backend; backend {
+	case "local":
+		return NewResponseCache(cache_pkg.NewLocalCacheWithExpiration(5*time.Minute, time.Minute)), nil
+	case "redis":
+		var redisCache *cache_pkg.RedisCache
+		if err := di.Resolve(func(cache *cache_pkg.RedisCache) {
+			redisCache = cache
+		}); err != nil {
+			return nil, fmt.Errorf("fail to get redis cache: %w", err)
+		}
+		return NewResponseCache(redisCache), nil
+	default:
+		return nil, fmt.Errorf("unknown response cache backend %q", backend)
+	}
+}
+
+// Key returns the cache key of r, built from its path, its normalized query,
+// the vary headers of the route and, on routes that need a session, the
+// caller. It is false when r may not be answered from the cache.
+func (c *ResponseCache) Key(ctx context.Context, r *http.Request, match *RouteMatch) (string, bool) {
+	if c == nil || match == nil || match.Route.Cache == nil || r.Method != http.MethodGet {
+		return "", false
+	}
+	if _, ok := cacheControl(r.Header)["no-store"]; ok {
+		return "", false
+	}
+	var b strings.Builder
+	b.WriteString(r.URL.Path)
+	b.WriteString("?")
+	b.WriteString(normalizeQuery(r.URL.Query()))
+	for _, name := range match.Route.Cache.VaryHeaders {
+		fmt.Fprintf(&b, "\n%s: %s", http.CanonicalHeaderKey(name), strings.Join(r.Header.Values(name), ","))
+	}
+	if match.Route.Auth {
+		caller, ok := identity.FromContext(ctx)
+		if !ok {
+			return "", false
+		}
+		fmt.Fprintf(&b, "\nuser: %s", caller.UserId)
+	}
+	for _, tag := range c.tags(match) {
+		fmt.Fprintf(&b, "\ntag: %s=%s", tag, c.tagVersion(ctx, tag))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return "response_cache:" + hex.EncodeToString(sum[:]), true
+}
+
+// Get returns the response cached under key. A request asking for
+// Cache-Control: no-cache always goes to the service.
+func (c *ResponseCache) Get(ctx context.Context, r *http.Request, key string) (*CachedResponse, bool) {
+	if _, ok := cacheControl(r.Header)["no-cache"]; ok {
+		return nil, false
+	}
+	value, err := c.cache.Get(ctx, key)
+	if err != nil || value == nil {
+		// a miss, or a cache that is down: ask the service either way
+		return nil, false
+	}
+	data, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	var cached CachedResponse
+	if err := json.Unmarshal([]byte(data), &cached); err != nil {
+		logging.GetSugaredLogger().Warnf("fail to decode cached response: %v", err)
+		return nil, false
+	}
+	return &cached, true
+}
+
+// Store caches a successful response under key for as long as its
+// Cache-Control allows, the TTL of the route otherwise. It returns the ETag of
+// the response, which is added to its headers, or "" when it is not cached.
+func (c *ResponseCache) Store(ctx context.Context, key string, match *RouteMatch, response *custom_nats.Response) string {
+	if response.StatusCode != http.StatusOK || response.Error != nil {
+		return ""
+	}
+	if response.Headers == nil {
+		response.Headers = http.Header{}
+	}
+	headers := response.Headers
+	if headers.Get("Set-Cookie") != "" {
+		return ""
+	}
+	directives := cacheControl(headers)
+	if _, ok := directives["no-store"]; ok {
+		return ""
+	}
+	if _, ok := directives["private"]; ok && !match.Route.Auth {
+		// the key of a public route is shared by every caller
+		return ""
+	}
+	ttl := match.Route.Cache.TTL
+	for _, directive := range []string{"max-age", "s-maxage"} {
+		if seconds, err := strconv.Atoi(directives[directive]); err == nil {
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+	if ttl < time.Second {
+		return ""
+	}
+	etag := headers.Get("ETag")
+	if etag == "" {
+		sum := sha256.Sum256(response.Body)
+		etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+	headers.Set("ETag", etag)
+
+	data, err := json.Marshal(CachedResponse{
+		StatusCode: response.StatusCode,
+		Headers:    headers.Clone(),
+		Body:       response.Body,
+		ETag:       etag,
+		StoredAt:   time.Now(),
+	})
+	if err != nil {
+		logging.GetSugaredLogger().Warnf("fail to encode response for the cache: %v", err)
+		return ""
+	}
+	if err := c.cache.SetEx(ctx, key, string(data), int(ttl.Seconds())); err != nil {
+		logging.GetSugaredLogger().Warnf("fail to cache response: %v", err)
+	}
+	headers.Set(CacheStatusHeader, "MISS")
+	return etag
+}
+
+// Invalidate drops every response cached under any of tags.
+func (c *ResponseCache) Invalidate(ctx context.Context, tags ...string) error {
+	version := strconv.FormatInt(time.Now().UnixNano(), 36)
+	for _, tag := range tags {
+		if err := c.cache.Set(ctx, tagVersionKey(tag), version); err != nil {
+			return fmt.Errorf("fail to invalidate cache tag %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// SubscribeInvalidations applies the invalidations services publish with
+// custom_nats.InvalidateCache.
+func (c *ResponseCache) SubscribeInvalidations(natsConn *nats.Conn) (*nats.Subscription, error) {
+	return natsConn.Subscribe(custom_nats.CacheInvalidationSubject, func(msg *nats.Msg) {
+		var invalidation custom_nats.CacheInvalidation
+		if err := json.Unmarshal(msg.Data, &invalidation); err != nil {
+			logging.GetSugaredLogger().Warnf("fail to decode cache invalidation: %v", err)
+			return
+		}
+		if err := c.Invalidate(context.Background(), invalidation.Tags...); err != nil {
+			logging.GetSugaredLogger().Errorf("%v", err)
+		}
+	})
+}
+
+func (c *ResponseCache) tags(match *RouteMatch) []string {
+	tags := make([]string, 0, len(match.Route.Cache.Tags))
+	for _, tag := range match.Route.Cache.Tags {
+		tags = append(tags, expandTemplate(tag, match.Params))
+	}
+	return tags
+}
+
+func (c *ResponseCache) tagVersion(ctx context.Context, tag string) string {
+	value, err := c.cache.Get(ctx, tagVersionKey(tag))
+	if err != nil || value == nil {
+		return "0"
+	}
+	version, _ := value.(string)
+	return version
+}
+
+func tagVersionKey(tag string) string {
+	return "response_cache_tag:" + tag
+}
+
+// writeCachedResponse answers from the cache, with a 304 when the client has
+// the same version already.
+func writeCachedResponse(w http.ResponseWriter, r *http.Request, cached *CachedResponse) {
+	copyResponseHeaders(w, cached.Headers)
+	w.Header().Set(CacheStatusHeader, "HIT")
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(cached.StoredAt).Seconds())))
+	if etagMatches(r.Header.Get("If-None-Match"), cached.ETag) {
+		writeNotModified(w)
+		return
+	}
+	w.WriteHeader(cached.StatusCode)
+	if _, err := w.Write(cached.Body); err != nil {
+		logging.GetSugaredLogger().Errorf("fail to write cached response: %v", err)
+	}
+}
+
+// writeNotModified sends a 304, which has no body, so the Content-Type set by
+// ContentTypeMiddleware does not apply.
+func writeNotModified(w http.ResponseWriter) {
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		// If-None-Match uses the weak comparison
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl parses the Cache-Control directives of headers, lowercased.
+func cacheControl(headers http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range headers.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// normalizeQuery encodes query with its keys and the values of every key
+// sorted, so the order of the parameters does not split the cache.
+func normalizeQuery(query url.Values) string {
+	for key := range query {
+		slices.Sort(query[key])
+	}
+	return query.Encode()
+}
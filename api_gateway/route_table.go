@@ -0,0 +1,276 @@
+package apigateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+)
+
+type routeContextKey struct{}
+
+// Route is one compiled entry of the route table.
+type Route struct {
+	Method  string
+	Pattern string
+	Service string
+	Subject string
+	Rewrite string
+	Auth    bool
+	// Cache is the response cache setting of the route, nil when off.
+	Cache *configs.RouteCacheConfig
+	// MaxBodySize bounds the request body, the gateway default when 0.
+	MaxBodySize int64
+	// Priority decides which requests are shed first under load.
+	Priority string
+	// Stream lets the service answer with a streamed response.
+	Stream bool
+
+	segments []string
+	// used are the path parameters consumed by Service, Subject or Rewrite
+	used map[string]bool
+}
+
+// RouteMatch is a request matched to its route.
+type RouteMatch struct {
+	Route  *Route
+	Params map[string]string
+	Target custom_nats.Target
+}
+
+// RouteTable maps HTTP requests to services. Routes are tried in order.
+type RouteTable struct {
+	routes []*Route
+}
+
+func NewRouteTable(routes []configs.RouteConfig) (*RouteTable, error) {
+	table := &RouteTable{}
+	for _, config := range routes {
+		route, err := compileRoute(config)
+		if err != nil {
+			return nil, err
+		}
+		table.routes = append(table.routes, route)
+	}
+	return table, nil
+}
+
+// LoadRouteTable builds the route table from apigateway.routes.
+func LoadRouteTable() (*RouteTable, error) {
+	routes, err := configs.LoadRoutes()
+	if err != nil {
+		return nil, err
+	}
+	return NewRouteTable(routes)
+}
+
+func compileRoute(config configs.RouteConfig) (*Route, error) {
+	segments, params, err := parsePattern(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	if config.Service == "" {
+		return nil, fmt.Errorf("route %s has no service", config.Path)
+	}
+	route := &Route{
+		Method:      strings.ToUpper(config.Method),
+		Pattern:     config.Path,
+		Service:     config.Service,
+		Subject:     config.Subject,
+		Rewrite:     config.Rewrite,
+		Auth:        config.Auth == nil || *config.Auth,
+		Cache:       config.Cache,
+		MaxBodySize: config.MaxBodySize,
+		Priority:    config.Priority,
+		Stream:      config.Stream,
+		segments:    segments,
+		used:        map[string]bool{},
+	}
+	if route.MaxBodySize < 0 {
+		return nil, fmt.Errorf("route %s has a negative max_body_size", config.Path)
+	}
+	switch route.Priority {
+	case "":
+		route.Priority = PriorityNormal
+	case PriorityCritical, PriorityNormal, PriorityLow:
+	default:
+		return nil, fmt.Errorf("route %s has the unknown priority %q", config.Path, config.Priority)
+	}
+	if route.Subject == "" {
+		route.Subject = "/api/v1/" + route.Service
+	}
+	for _, template := range []string{route.Service, route.Subject, route.Rewrite} {
+		for _, name := range templateParams(template) {
+			if !params[name] {
+				return nil, fmt.Errorf("route %s uses the unknown parameter {%s}", config.Path, name)
+			}
+			route.used[name] = true
+		}
+	}
+	if route.Cache != nil {
+		for _, tag := range route.Cache.Tags {
+			for _, name := range templateParams(tag) {
+				if !params[name] {
+					return nil, fmt.Errorf("route %s uses the unknown parameter {%s} in a cache tag", config.Path, name)
+				}
+			}
+		}
+	}
+	return route, nil
+}
+
+// parsePattern splits a path pattern into its segments and returns the names
+// of its parameters.
+func parsePattern(path string) ([]string, map[string]bool, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, nil, fmt.Errorf("route path %q must start with /", path)
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	params := map[string]bool{}
+	for _, segment := range segments {
+		if name, ok := paramName(segment); ok {
+			if name == "" || params[name] {
+				return nil, nil, fmt.Errorf("route %s has an empty or repeated parameter", path)
+			}
+			params[name] = true
+		} else if strings.ContainsAny(segment, "{}") {
+			return nil, nil, fmt.Errorf("route %s has an invalid segment %q", path, segment)
+		}
+	}
+	return segments, params, nil
+}
+
+func paramName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+func templateParams(template string) []string {
+	names := []string{}
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			return names
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return names
+		}
+		names = append(names, template[start+1:start+end])
+		template = template[start+end+1:]
+	}
+}
+
+func expandTemplate(template string, params map[string]string) string {
+	for name, value := range params {
+		template = strings.ReplaceAll(template, "{"+name+"}", value)
+	}
+	return template
+}
+
+// match returns the parameters of path when it fits the pattern of the route.
+func (route *Route) match(path string) (map[string]string, bool) {
+	return matchSegments(route.segments, path)
+}
+
+// matchSegments matches path against the segments of a pattern, where a
+// {name} segment matches any one segment.
+func matchSegments(pattern []string, path string) (map[string]string, bool) {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(segments) != len(pattern) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range pattern {
+		if name, ok := paramName(segment); ok {
+			// a parameter may end up in a nats subject, where these would
+			// act as wildcards or break the subject
+			if segments[i] == "" || strings.ContainsAny(segments[i], " \t\r\n*>") {
+				return nil, false
+			}
+			params[name] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Match finds the route of a request. It answers 404 when no route has the
+// path and 405 when the path is routed for other methods only.
+func (t *RouteTable) Match(method, path string) (*RouteMatch, error) {
+	allowed := []string{}
+	var routes []*Route
+	if t != nil {
+		routes = t.routes
+	}
+	for _, route := range routes {
+		params, ok := route.match(path)
+		if !ok {
+			continue
+		}
+		if route.Method != "" && route.Method != method {
+			allowed = append(allowed, route.Method)
+			continue
+		}
+		target := custom_nats.Target{
+			ServiceName: expandTemplate(route.Service, params),
+			Subject:     expandTemplate(route.Subject, params),
+			Path:        path,
+		}
+		if route.Rewrite == "" {
+			// the service gets the path as it is, parameters included
+			return &RouteMatch{Route: route, Params: params, Target: target}, nil
+		}
+		target.Path = expandTemplate(route.Rewrite, params)
+		for name, value := range params {
+			if route.used[name] {
+				continue
+			}
+			if target.Params == nil {
+				target.Params = map[string]string{}
+			}
+			target.Params[name] = value
+		}
+		return &RouteMatch{Route: route, Params: params, Target: target}, nil
+	}
+	if len(allowed) > 0 {
+		slices.Sort(allowed)
+		return nil, shared.NewMethodNotAllowedError(fmt.Sprintf("method %s is not allowed on %s", method, path)).
+			WithDetails(map[string]any{"allow": slices.Compact(allowed)})
+	}
+	return nil, shared.NewNotFoundError(fmt.Sprintf("no route for %s %s", method, path))
+}
+
+// RouteMiddleware matches every request against table and keeps the match in
+// the request context. Unknown routes are answered here.
+func RouteMiddleware(table *RouteTable) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			match, err := table.Match(r.Method, r.URL.Path)
+			if err != nil {
+				appErr := shared.ToAppError(err)
+				if allow, ok := appErr.Details["allow"].([]string); ok {
+					w.Header().Set("Allow", strings.Join(allow, ", "))
+				}
+				writeAppError(w, appErr)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeContextKey{}, match)))
+		})
+	}
+}
+
+func RouteFromContext(ctx context.Context) (*RouteMatch, bool) {
+	match, ok := ctx.Value(routeContextKey{}).(*RouteMatch)
+	return match, ok
+}
@@ -0,0 +1,256 @@
+package api_gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/circuitbreaker"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	ratelimiter "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/rate_limiter"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryAPIKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]apigateway.APIKey
+}
+
+func (s *memoryAPIKeyStore) Get(ctx context.Context, id string) (*apigateway.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, apigateway.ErrAPIKeyNotFound
+	}
+	return &key, nil
+}
+
+func (s *memoryAPIKeyStore) Save(ctx context.Context, key *apigateway.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.Id] = *key
+	return nil
+}
+
+// countLimiter allows limit requests per key, the configured default when
+// the key sets none.
+type countLimiter struct {
+	limit  int
+	counts map[string]int
+}
+
+func (l *countLimiter) Allow(ctx context.Context, key *apigateway.APIKey) (ratelimiter.Result, error) {
+	limit := key.RateLimit
+	if limit <= 0 {
+		limit = l.limit
+	}
+	l.counts[key.Id]++
+	allowed := l.counts[key.Id] <= limit
+	result := ratelimiter.Result{Allowed: allowed, Limit: limit, Remaining: max(limit-l.counts[key.Id], 0), Window: time.Minute}
+	if !allowed {
+		result.RetryAfter = time.Minute
+	}
+	return result, nil
+}
+
+func newTestAPIKeys() *apigateway.APIKeys {
+	return apigateway.NewAPIKeys(
+		&memoryAPIKeyStore{keys: map[string]apigateway.APIKey{}},
+		&countLimiter{limit: 100, counts: map[string]int{}},
+		configs.APIKeysConfig{RotationGrace: time.Hour, AdminRole: "admin"},
+	)
+}
+
+func Test_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestAPIKeys()
+
+	t.Run("Test_Create_And_Authenticate", func(t *testing.T) {
+		key, plain, err := keys.Create(ctx, apigateway.CreateAPIKeyRequest{Name: "partner", Services: []string{"order"}})
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(plain, "ak_"+key.Id+"_"))
+		require.NotContains(t, key.Hash, plain)
+
+		authenticated, err := keys.Authenticate(ctx, plain)
+		require.NoError(t, err)
+		require.Equal(t, key.Id, authenticated.Id)
+
+		for _, wrong := range []string{"", "garbage", "ak_" + key.Id + "_wrong", strings.TrimPrefix(plain, "ak_"), "ak_unknown_" + plain} {
+			_, err := keys.Authenticate(ctx, wrong)
+			require.Error(t, err, wrong)
+			require.Equal(t, http.StatusUnauthorized, shared.ToAppError(err).StatusCode)
+		}
+	})
+
+	t.Run("Test_Rotate_Keeps_Old_Secret_For_Grace_Period", func(t *testing.T) {
+		key, oldPlain, err := keys.Create(ctx, apigateway.CreateAPIKeyRequest{Name: "job", Services: []string{"*"}})
+		require.NoError(t, err)
+		rotated, newPlain, err := keys.Rotate(ctx, key.Id)
+		require.NoError(t, err)
+		require.Equal(t, key.Id, rotated.Id)
+		require.NotEqual(t, oldPlain, newPlain)
+
+		_, err = keys.Authenticate(ctx, newPlain)
+		require.NoError(t, err)
+		_, err = keys.Authenticate(ctx, oldPlain)
+		require.NoError(t, err)
+
+		// a second rotation ends the grace of the first secret
+		_, _, err = keys.Rotate(ctx, key.Id)
+		require.NoError(t, err)
+		_, err = keys.Authenticate(ctx, oldPlain)
+		require.Error(t, err)
+	})
+
+	t.Run("Test_Revoke", func(t *testing.T) {
+		key, plain, err := keys.Create(ctx, apigateway.CreateAPIKeyRequest{Name: "job", Services: []string{"order"}})
+		require.NoError(t, err)
+		require.NoError(t, keys.Revoke(ctx, key.Id))
+		_, err = keys.Authenticate(ctx, plain)
+		require.Error(t, err)
+		require.Equal(t, http.StatusUnauthorized, shared.ToAppError(err).StatusCode)
+
+		_, _, err = keys.Rotate(ctx, key.Id)
+		require.Equal(t, http.StatusConflict, shared.ToAppError(err).StatusCode)
+		require.Equal(t, http.StatusNotFound, shared.ToAppError(keys.Revoke(ctx, "unknown")).StatusCode)
+	})
+
+	t.Run("Test_Invalid_Create", func(t *testing.T) {
+		for _, req := range []apigateway.CreateAPIKeyRequest{
+			{Services: []string{"order"}},
+			{Name: "partner"},
+			{Name: "partner", Services: []string{"order"}, Routes: []string{"api/v1/order/{id}"}},
+			{Name: "partner", Services: []string{"order"}, RateLimit: -1},
+		} {
+			_, _, err := keys.Create(ctx, req)
+			require.Error(t, err)
+			require.Equal(t, http.StatusBadRequest, shared.ToAppError(err).StatusCode)
+		}
+	})
+}
+
+func Test_RedisAPIKeyLimiter(t *testing.T) {
+	breaker := circuitbreaker.NewBreaker[ratelimiter.Result](&circuitbreaker.Config{
+		Name: "api-key-rate-limit-test", MaxRequests: 1, Interval: 30, Timeout: 10, FailureThreshold: 1,
+	})
+	redisDown := func(algorithm ratelimiter.Algorithm, limit int, window time.Duration) ratelimiter.Limiter {
+		return &windowLimiter{limit: limit, window: window, err: errors.New("redis is down"), counts: map[string]int{}}
+	}
+	newLimiter, err := apigateway.FallbackLimiterFactory(redisDown, configs.RateLimitFallbackConfig{Mode: "local", Instances: 1}, breaker, nil)
+	require.NoError(t, err)
+	limiter, err := apigateway.NewRedisAPIKeyLimiter(newLimiter, configs.APIKeysConfig{DefaultRateLimit: 2, RateLimitWindow: time.Minute})
+	require.NoError(t, err)
+
+	// the key falls back to the local count instead of failing
+	key := &apigateway.APIKey{Id: "k1"}
+	for range 2 {
+		result, err := limiter.Allow(context.Background(), key)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+	}
+	result, err := limiter.Allow(context.Background(), key)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+}
+
+func Test_APIKeyMiddleware(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestAPIKeys()
+	routes, err := apigateway.LoadRouteTable()
+	require.NoError(t, err)
+	authenticator, signer := newTestAuthenticator(t, &fakeSessionStore{sessions: map[string]*identity.Identity{}})
+
+	var forwarded http.Header
+	handler := apigateway.MiddlewareChain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}), apigateway.RouteMiddleware(routes), apigateway.APIKeyMiddleware(keys), apigateway.AuthMiddleware(authenticator))
+	send := func(plain, path string) int {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set(apigateway.APIKeyHeader, plain)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	key, plain, err := keys.Create(ctx, apigateway.CreateAPIKeyRequest{
+		Name:      "partner",
+		Services:  []string{"order"},
+		Routes:    []string{"/api/v1/order/GetOrderById", "/api/v1/order/ListOrders"},
+		Roles:     []string{"partner"},
+		RateLimit: 2,
+	})
+	require.NoError(t, err)
+
+	t.Run("Forward the signed identity of the key", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send(plain, "/api/v1/order/GetOrderById"))
+		require.Empty(t, forwarded.Get(apigateway.APIKeyHeader))
+		id, err := signer.Verify(forwarded.Get(identity.Header))
+		require.NoError(t, err)
+		require.Equal(t, "api_key:"+key.Id, id.UserId)
+		require.Equal(t, []string{"partner"}, id.Roles)
+	})
+
+	t.Run("Forbidden outside the scope of the key", func(t *testing.T) {
+		require.Equal(t, http.StatusForbidden, send(plain, "/api/v1/order/CreateOrder"))
+		require.Equal(t, http.StatusForbidden, send(plain, "/api/v1/auth/GetMyProfile"))
+	})
+
+	t.Run("Too many requests over the limit of the key", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send(plain, "/api/v1/order/ListOrders"))
+		require.Equal(t, http.StatusTooManyRequests, send(plain, "/api/v1/order/ListOrders"))
+	})
+
+	t.Run("Unauthorized with a revoked key", func(t *testing.T) {
+		require.NoError(t, keys.Revoke(ctx, key.Id))
+		require.Equal(t, http.StatusUnauthorized, send(plain, "/api/v1/order/GetOrderById"))
+	})
+}
+
+func Test_APIKeyAdminHandler(t *testing.T) {
+	keys := newTestAPIKeys()
+	handler := apigateway.APIKeyAdminHandler(keys, "admin")
+	send := func(caller *identity.Identity, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if caller != nil {
+			req = req.WithContext(identity.NewContext(req.Context(), caller, "signed"))
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+	admin := &identity.Identity{UserId: "user-1", Roles: []string{"admin"}}
+
+	res := send(admin, "POST", "/admin/api-keys", `{"name":"partner","services":["order"],"rate_limit":10}`)
+	require.Equal(t, http.StatusCreated, res.Code)
+	var created apigateway.APIKeyResponse
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &created))
+	require.NotEmpty(t, created.Key)
+	require.NotContains(t, res.Body.String(), "hash")
+	_, err := keys.Authenticate(context.Background(), created.Key)
+	require.NoError(t, err)
+
+	res = send(admin, "POST", "/admin/api-keys/"+created.Id+"/rotate", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	var rotated apigateway.APIKeyResponse
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &rotated))
+	require.NotEqual(t, created.Key, rotated.Key)
+
+	require.Equal(t, http.StatusNoContent, send(admin, "DELETE", "/admin/api-keys/"+created.Id, "").Code)
+	_, err = keys.Authenticate(context.Background(), rotated.Key)
+	require.Error(t, err)
+
+	require.Equal(t, http.StatusBadRequest, send(admin, "POST", "/admin/api-keys", `{"name":"partner"}`).Code)
+	require.Equal(t, http.StatusForbidden, send(&identity.Identity{UserId: "user-2"}, "POST", "/admin/api-keys", `{}`).Code)
+	require.Equal(t, http.StatusUnauthorized, send(nil, "POST", "/admin/api-keys", `{}`).Code)
+}
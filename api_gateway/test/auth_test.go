@@ -0,0 +1,129 @@
+package api_gateway_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/stretchr/testify/require"
+	"github.com/zitadel/oidc/v3/pkg/crypto"
+)
+
+const (
+	testCookieName = "test-cookie"
+	// zitadel_configs.encrypt_key holds the 32 bytes aes-256 key
+	// "0123456789abcdef0123456789abcdef" base64 encoded
+	testEncryptKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+)
+
+type fakeSessionStore struct {
+	sessions map[string]*identity.Identity
+	calls    int
+}
+
+func (s *fakeSessionStore) GetSession(ctx context.Context, sessionId string) (*identity.Identity, error) {
+	s.calls++
+	session, ok := s.sessions[sessionId]
+	if !ok {
+		return nil, shared.NewUnauthorizedError("session not found")
+	}
+	return session, nil
+}
+
+type fakeTokenResolver struct {
+	tokens map[string]*identity.Identity
+}
+
+func (r *fakeTokenResolver) ResolveToken(ctx context.Context, token string) (*identity.Identity, error) {
+	id, ok := r.tokens[token]
+	if !ok {
+		return nil, shared.NewUnauthorizedError("invalid bearer token")
+	}
+	return id, nil
+}
+
+func newTestAuthenticator(t *testing.T, sessions *fakeSessionStore) (*apigateway.Authenticator, *identity.Signer) {
+	signer, err := identity.NewSigner("test-signing-key", time.Minute)
+	require.NoError(t, err)
+	tokens := &fakeTokenResolver{tokens: map[string]*identity.Identity{
+		"token-1": {UserId: "machine-1", Roles: []string{"service"}},
+	}}
+	authenticator, err := apigateway.NewAuthenticator(apigateway.AuthenticatorConfig{
+		CookieName: testCookieName,
+		EncryptKey: testEncryptKey,
+		CacheTTL:   time.Minute,
+	}, sessions, tokens, signer)
+	require.NoError(t, err)
+	return authenticator, signer
+}
+
+// sessionCookie encrypts the session id like the auth service does at login,
+// with the decoded key.
+func sessionCookie(t *testing.T, sessionId string) *http.Cookie {
+	key, err := base64.StdEncoding.DecodeString(testEncryptKey)
+	require.NoError(t, err)
+	value, err := crypto.EncryptAES(sessionId, string(key))
+	require.NoError(t, err)
+	return &http.Cookie{Name: testCookieName, Value: value}
+}
+
+func Test_Authenticator(t *testing.T) {
+	sessions := &fakeSessionStore{sessions: map[string]*identity.Identity{
+		"session-1": {UserId: "user-1", Roles: []string{"customer"}, SessionId: "session-1"},
+		"session-2": {UserId: "user-2", SessionId: "session-2", ExpiresAt: time.Now().Add(-time.Minute).Unix()},
+	}}
+	authenticator, _ := newTestAuthenticator(t, sessions)
+
+	t.Run("Test_Session_Cookie_Is_Cached", func(t *testing.T) {
+		for range 3 {
+			req := httptest.NewRequest("POST", "/api/v1/order/GetOrderById", nil)
+			req.AddCookie(sessionCookie(t, "session-1"))
+			id, err := authenticator.Authenticate(req)
+			require.NoError(t, err)
+			require.Equal(t, "user-1", id.UserId)
+			require.Equal(t, []string{"customer"}, id.Roles)
+		}
+		require.Equal(t, 1, sessions.calls)
+	})
+
+	t.Run("Test_Bearer_Token_Wins_Over_Cookie", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/order/GetOrderById", nil)
+		req.AddCookie(sessionCookie(t, "session-1"))
+		req.Header.Set("Authorization", "Bearer token-1")
+		id, err := authenticator.Authenticate(req)
+		require.NoError(t, err)
+		require.Equal(t, "machine-1", id.UserId)
+	})
+
+	t.Run("Test_Unauthorized", func(t *testing.T) {
+		noCredentials := httptest.NewRequest("POST", "/api/v1/order/GetOrderById", nil)
+		badCookie := httptest.NewRequest("POST", "/api/v1/order/GetOrderById", nil)
+		badCookie.AddCookie(&http.Cookie{Name: testCookieName, Value: "not-encrypted"})
+		unknownSession := httptest.NewRequest("POST", "/api/v1/order/GetOrderById", nil)
+		unknownSession.AddCookie(sessionCookie(t, "session-unknown"))
+		expiredSession := httptest.NewRequest("POST", "/api/v1/order/GetOrderById", nil)
+		expiredSession.AddCookie(sessionCookie(t, "session-2"))
+		badToken := httptest.NewRequest("POST", "/api/v1/order/GetOrderById", nil)
+		badToken.Header.Set("Authorization", "Bearer token-unknown")
+
+		for _, req := range []*http.Request{noCredentials, badCookie, unknownSession, expiredSession, badToken} {
+			_, err := authenticator.Authenticate(req)
+			require.Error(t, err)
+			require.Equal(t, http.StatusUnauthorized, shared.ToAppError(err).StatusCode)
+		}
+	})
+
+	t.Run("Test_Encrypt_Key_Must_Be_Base64", func(t *testing.T) {
+		_, err := apigateway.NewAuthenticator(apigateway.AuthenticatorConfig{
+			CookieName: testCookieName,
+			EncryptKey: "0123456789abcdef0123456789abcdef!",
+		}, sessions, &fakeTokenResolver{}, nil)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,144 @@
+package api_gateway_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PolicyEngine(t *testing.T) {
+	engine, err := apigateway.NewPolicyEngine([]configs.AuthorizationRule{
+		{Path: "/api/v1/product/CreateProduct", Roles: []string{"admin", "merchant"}, Scopes: []string{"products:write"}},
+		{Method: "GET", Path: "/api/v2/users/{userId}/orders", Owner: "user_id", OwnerExemptRoles: []string{"support"}},
+		{Path: "/api/v1/order/{method}", Roles: []string{"customer"}},
+	}, apigateway.DefaultOwnerChecks())
+	require.NoError(t, err)
+
+	customer := &identity.Identity{UserId: "user-1", Roles: []string{"customer"}}
+	merchant := &identity.Identity{UserId: "user-2", Roles: []string{"merchant"}, Scopes: []string{"products:write"}}
+	support := &identity.Identity{UserId: "user-3", Roles: []string{"support"}}
+
+	cases := []struct {
+		name    string
+		caller  *identity.Identity
+		method  string
+		target  string
+		allowed bool
+	}{
+		{"Test_Role_Allowed", customer, "POST", "/api/v1/order/CreateOrder", true},
+		{"Test_Role_Missing", merchant, "POST", "/api/v1/order/CreateOrder", false},
+		{"Test_Role_And_Scope", merchant, "POST", "/api/v1/product/CreateProduct", true},
+		{"Test_Scope_Missing", &identity.Identity{UserId: "user-4", Roles: []string{"admin"}}, "POST", "/api/v1/product/CreateProduct", false},
+		{"Test_Owner", customer, "GET", "/api/v2/users/user-1/orders", true},
+		{"Test_Not_Owner", customer, "GET", "/api/v2/users/user-2/orders", false},
+		{"Test_Owner_Exempt_Role", support, "GET", "/api/v2/users/user-1/orders", true},
+		{"Test_Rule_Of_Other_Method", customer, "POST", "/api/v2/users/user-2/orders", true},
+		{"Test_No_Rule", merchant, "POST", "/api/v1/auth/GetMyProfile", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := engine.Authorize(context.Background(), c.caller, httptest.NewRequest(c.method, c.target, nil))
+			if c.allowed {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Equal(t, http.StatusForbidden, shared.ToAppError(err).StatusCode)
+		})
+	}
+
+	t.Run("Test_Owner_Query_Param", func(t *testing.T) {
+		engine, err := apigateway.NewPolicyEngine([]configs.AuthorizationRule{
+			{Path: "/api/v1/order/GetOrdersOfUser", Owner: "user_id"},
+		}, apigateway.DefaultOwnerChecks())
+		require.NoError(t, err)
+		require.NoError(t, engine.Authorize(context.Background(), customer, httptest.NewRequest("GET", "/api/v1/order/GetOrdersOfUser?userId=user-1", nil)))
+		require.Error(t, engine.Authorize(context.Background(), customer, httptest.NewRequest("GET", "/api/v1/order/GetOrdersOfUser?userId=user-2", nil)))
+		require.Error(t, engine.Authorize(context.Background(), customer, httptest.NewRequest("GET", "/api/v1/order/GetOrdersOfUser", nil)))
+	})
+
+	t.Run("Test_Owner_Check_Callback", func(t *testing.T) {
+		orders := map[string]string{"order-1": "user-1"}
+		engine, err := apigateway.NewPolicyEngine([]configs.AuthorizationRule{
+			{Path: "/api/v2/orders/{id}", Owner: "order"},
+		}, map[string]apigateway.OwnerCheck{
+			"order": func(ctx context.Context, caller *identity.Identity, params map[string]string, r *http.Request) (bool, error) {
+				owner, ok := orders[params["id"]]
+				if !ok {
+					return false, shared.NewNotFoundError("order not found")
+				}
+				return owner == caller.UserId, nil
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, engine.Authorize(context.Background(), customer, httptest.NewRequest("GET", "/api/v2/orders/order-1", nil)))
+		require.Error(t, engine.Authorize(context.Background(), merchant, httptest.NewRequest("GET", "/api/v2/orders/order-1", nil)))
+
+		err = engine.Authorize(context.Background(), customer, httptest.NewRequest("GET", "/api/v2/orders/order-2", nil))
+		var appErr *shared.AppError
+		require.True(t, errors.As(err, &appErr))
+		require.Equal(t, http.StatusNotFound, appErr.StatusCode)
+	})
+
+	t.Run("Test_Invalid_Rules", func(t *testing.T) {
+		for _, rule := range []configs.AuthorizationRule{
+			{Path: "api/v1/order/CreateOrder"},
+			{Path: "/api/v1/order/{}"},
+			{Path: "/api/v1/order/CreateOrder", Owner: "unknown"},
+		} {
+			_, err := apigateway.NewPolicyEngine([]configs.AuthorizationRule{rule}, apigateway.DefaultOwnerChecks())
+			require.Error(t, err, rule.Path)
+		}
+	})
+}
+
+func Test_LoadPolicyEngine(t *testing.T) {
+	viper.Set("apigateway.authorization", []map[string]any{
+		{"path": "/api/v1/order/{method}", "roles": []string{"customer"}},
+	})
+	defer viper.Set("apigateway.authorization", nil)
+
+	engine, err := apigateway.LoadPolicyEngine(apigateway.DefaultOwnerChecks())
+	require.NoError(t, err)
+	require.NoError(t, engine.Authorize(context.Background(), &identity.Identity{UserId: "user-1", Roles: []string{"customer"}}, httptest.NewRequest("POST", "/api/v1/order/CreateOrder", nil)))
+	require.Error(t, engine.Authorize(context.Background(), &identity.Identity{UserId: "user-1"}, httptest.NewRequest("POST", "/api/v1/order/CreateOrder", nil)))
+}
+
+func Test_AuthorizeMiddleware(t *testing.T) {
+	engine, err := apigateway.NewPolicyEngine([]configs.AuthorizationRule{
+		{Path: "/api/v1/order/{method}", Roles: []string{"customer"}},
+	}, apigateway.DefaultOwnerChecks())
+	require.NoError(t, err)
+	handler := apigateway.AuthorizeMiddleware(engine)(mockHandler())
+
+	t.Run("Forbidden without the role", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/order/CreateOrder", nil)
+		req = req.WithContext(identity.NewContext(req.Context(), &identity.Identity{UserId: "user-1"}, "signed"))
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		require.Equal(t, http.StatusForbidden, res.Code)
+	})
+
+	t.Run("Allowed with the role", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/order/CreateOrder", nil)
+		req = req.WithContext(identity.NewContext(req.Context(), &identity.Identity{UserId: "user-1", Roles: []string{"customer"}}, "signed"))
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code)
+	})
+
+	t.Run("Unauthorized without identity", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("POST", "/api/v1/order/CreateOrder", nil))
+		require.Equal(t, http.StatusUnauthorized, res.Code)
+	})
+}
@@ -0,0 +1,59 @@
+package api_gateway_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BodyLimitMiddleware(t *testing.T) {
+	routes, err := apigateway.NewRouteTable([]configs.RouteConfig{
+		{Method: "POST", Path: "/api/v2/products/{id}/images", Service: "product", Rewrite: "/api/v1/product/UploadImage", MaxBodySize: 64},
+		{Path: "/api/{version}/{service}/{method}", Service: "{service}", Subject: "/api/{version}/{service}"},
+	})
+	require.NoError(t, err)
+	var readErr error
+	handler := apigateway.MiddlewareChain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}), apigateway.RouteMiddleware(routes), apigateway.BodyLimitMiddleware(16))
+	send := func(path string, body io.Reader, contentLength int64) int {
+		readErr = nil
+		req := httptest.NewRequest("POST", path, body)
+		req.ContentLength = contentLength
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	t.Run("Test_Within_Default_Limit", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send("/api/v1/order/CreateOrder", strings.NewReader(`{"id":"1"}`), 10))
+		require.NoError(t, readErr)
+	})
+
+	t.Run("Test_Content_Length_Rejected_Early", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/v1/order/CreateOrder", strings.NewReader(strings.Repeat("a", 32)))
+		handler.ServeHTTP(res, req)
+		require.Equal(t, http.StatusRequestEntityTooLarge, res.Code)
+		require.Contains(t, res.Body.String(), "Payload_Too_Large")
+	})
+
+	t.Run("Test_Unknown_Length_Stops_At_Limit", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send("/api/v1/order/CreateOrder", strings.NewReader(strings.Repeat("a", 32)), -1))
+		var maxBytesErr *http.MaxBytesError
+		require.ErrorAs(t, readErr, &maxBytesErr)
+	})
+
+	t.Run("Test_Route_Limit", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send("/api/v2/products/1/images", strings.NewReader(strings.Repeat("a", 32)), 32))
+		require.NoError(t, readErr)
+		require.Equal(t, http.StatusRequestEntityTooLarge, send("/api/v2/products/1/images", strings.NewReader(strings.Repeat("a", 128)), 128))
+	})
+}
@@ -0,0 +1,181 @@
+package api_gateway_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCompression(t *testing.T) *apigateway.Compression {
+	compression, err := apigateway.NewCompression(configs.CompressionConfig{
+		Enabled:             true,
+		Encodings:           []string{"zstd", "gzip"},
+		MinSize:             64,
+		ContentTypes:        []string{"application/json", "text/"},
+		MaxDecompressedSize: 1024,
+	})
+	require.NoError(t, err)
+	return compression
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func Test_Compression_Negotiate(t *testing.T) {
+	compression := newTestCompression(t)
+	cases := map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    "gzip",
+		"gzip, deflate, br, zstd": "zstd",
+		"gzip;q=1.0, zstd;q=0.5":  "gzip",
+		"zstd;q=0, gzip":          "gzip",
+		"*":                       "zstd",
+		"*, zstd;q=0":             "gzip",
+		"br":                      "",
+	}
+	for acceptEncoding, expected := range cases {
+		require.Equal(t, expected, compression.Negotiate(acceptEncoding), acceptEncoding)
+	}
+
+	_, err := apigateway.NewCompression(configs.CompressionConfig{Encodings: []string{"br"}})
+	require.Error(t, err)
+}
+
+func Test_CompressionMiddleware(t *testing.T) {
+	compression := newTestCompression(t)
+	large := `{"products":"` + strings.Repeat("a", 512) + `"}`
+	serve := func(contentType, body string, acceptEncoding string) *httptest.ResponseRecorder {
+		handler := apigateway.CompressionMiddleware(compression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("ETag", `"v1"`)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(body))
+		}))
+		req := httptest.NewRequest("GET", "/api/v1/product/ListProducts", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("Test_Gzip", func(t *testing.T) {
+		res := serve("application/json; charset=utf-8", large, "gzip")
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, "gzip", res.Header().Get("Content-Encoding"))
+		require.Equal(t, "Accept-Encoding", res.Header().Get("Vary"))
+		require.Equal(t, `W/"v1"`, res.Header().Get("ETag"))
+		reader, err := gzip.NewReader(res.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, large, string(body))
+	})
+
+	t.Run("Test_Zstd", func(t *testing.T) {
+		res := serve("application/json", large, "gzip, zstd")
+		require.Equal(t, "zstd", res.Header().Get("Content-Encoding"))
+		decoder, err := zstd.NewReader(res.Body)
+		require.NoError(t, err)
+		defer decoder.Close()
+		body, err := io.ReadAll(decoder)
+		require.NoError(t, err)
+		require.Equal(t, large, string(body))
+	})
+
+	t.Run("Test_Below_Min_Size", func(t *testing.T) {
+		res := serve("application/json", `{"id":"1"}`, "gzip")
+		require.Empty(t, res.Header().Get("Content-Encoding"))
+		require.Equal(t, "Accept-Encoding", res.Header().Get("Vary"))
+		require.Equal(t, `{"id":"1"}`, res.Body.String())
+	})
+
+	t.Run("Test_Content_Type_Not_Allowed", func(t *testing.T) {
+		res := serve("image/png", large, "gzip")
+		require.Empty(t, res.Header().Get("Content-Encoding"))
+		require.Empty(t, res.Header().Get("Vary"))
+		require.Equal(t, large, res.Body.String())
+	})
+
+	t.Run("Test_Not_Accepted", func(t *testing.T) {
+		res := serve("application/json", large, "")
+		require.Empty(t, res.Header().Get("Content-Encoding"))
+		require.Equal(t, large, res.Body.String())
+	})
+
+	t.Run("Test_Flushed_Stream", func(t *testing.T) {
+		handler := apigateway.CompressionMiddleware(compression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusOK)
+			controller := http.NewResponseController(w)
+			require.NoError(t, controller.Flush())
+			for range 3 {
+				_, _ = w.Write([]byte("chunk\n"))
+				require.NoError(t, controller.Flush())
+			}
+		}))
+		req := httptest.NewRequest("GET", "/api/v1/product/ExportProducts", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		require.True(t, res.Flushed)
+		require.Equal(t, "gzip", res.Header().Get("Content-Encoding"))
+		reader, err := gzip.NewReader(res.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, "chunk\nchunk\nchunk\n", string(body))
+	})
+}
+
+func Test_CompressionMiddleware_Request(t *testing.T) {
+	compression := newTestCompression(t)
+	var received []byte
+	var receivedHeader http.Header
+	handler := apigateway.CompressionMiddleware(compression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		receivedHeader = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(encoding string, body []byte) int {
+		req := httptest.NewRequest("POST", "/api/v1/order/CreateOrder", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", encoding)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	t.Run("Test_Gzip_Body_Decompressed", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, send("gzip", gzipBytes(t, []byte(`{"items":[1,2]}`))))
+		require.Equal(t, `{"items":[1,2]}`, string(received))
+		require.Empty(t, receivedHeader.Get("Content-Encoding"))
+		require.Equal(t, "15", receivedHeader.Get("Content-Length"))
+	})
+
+	t.Run("Test_Invalid_Gzip", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, send("gzip", []byte("not gzip")))
+	})
+
+	t.Run("Test_Too_Large_Once_Decompressed", func(t *testing.T) {
+		require.Equal(t, http.StatusRequestEntityTooLarge, send("gzip", gzipBytes(t, bytes.Repeat([]byte("a"), 2048))))
+	})
+
+	t.Run("Test_Unsupported_Encoding", func(t *testing.T) {
+		require.Equal(t, http.StatusUnsupportedMediaType, send("br", []byte("{}")))
+	})
+}
@@ -0,0 +1,112 @@
+package api_gateway_test
+
+import (
+	"testing"
+	"time"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func testConcurrencyConfig() configs.ConcurrencyConfig {
+	return configs.ConcurrencyConfig{
+		Enabled:          true,
+		InitialLimit:     10,
+		MinLimit:         2,
+		MaxLimit:         11,
+		LatencyThreshold: 100 * time.Millisecond,
+		Backoff:          0.5,
+		RetryAfter:       2 * time.Second,
+		Priorities:       map[string]float64{"critical": 1, "normal": 0.8, "low": 0.5},
+		Services:         []string{"order", "product"},
+	}
+}
+
+// fill admits requests of priority until one is shed.
+func fill(limits *apigateway.ConcurrencyLimits, service, priority string) []*apigateway.ConcurrencyPermit {
+	permits := []*apigateway.ConcurrencyPermit{}
+	for {
+		permit, ok := limits.Acquire(service, priority)
+		if !ok {
+			return permits
+		}
+		permits = append(permits, permit)
+	}
+}
+
+func Test_ConcurrencyLimits(t *testing.T) {
+	t.Run("Test_Lower_Priorities_Shed_First", func(t *testing.T) {
+		limits, err := apigateway.NewConcurrencyLimits(testConcurrencyConfig(), prometheus.NewRegistry())
+		require.NoError(t, err)
+		require.Len(t, fill(limits, "product", apigateway.PriorityLow), 5)
+		require.Len(t, fill(limits, "product", apigateway.PriorityNormal), 3)
+		require.Len(t, fill(limits, "product", apigateway.PriorityCritical), 2)
+		require.Len(t, fill(limits, "order", apigateway.PriorityLow), 5, "services are limited apart")
+	})
+
+	t.Run("Test_Unknown_Services_Share_A_Limit", func(t *testing.T) {
+		limits, err := apigateway.NewConcurrencyLimits(testConcurrencyConfig(), prometheus.NewRegistry())
+		require.NoError(t, err)
+		require.Len(t, fill(limits, "made-up", apigateway.PriorityLow), 5)
+		require.Empty(t, fill(limits, "another-made-up", apigateway.PriorityLow))
+		require.Len(t, fill(limits, "order", apigateway.PriorityLow), 5)
+	})
+
+	t.Run("Test_Limit_Adapts_To_Latency", func(t *testing.T) {
+		limits, err := apigateway.NewConcurrencyLimits(testConcurrencyConfig(), nil)
+		require.NoError(t, err)
+		permits := fill(limits, "order", apigateway.PriorityCritical)
+		require.Len(t, permits, 10)
+
+		// a slow reply halves the limit
+		permits[0].Done(time.Second, false)
+		for _, permit := range permits[1:] {
+			permit.Release()
+		}
+		permits = fill(limits, "order", apigateway.PriorityCritical)
+		require.Len(t, permits, 5)
+
+		// a failure halves it down to the minimum
+		permits[0].Done(time.Millisecond, true)
+		permits[1].Done(time.Millisecond, true)
+		for _, permit := range permits[2:] {
+			permit.Release()
+		}
+		permits = fill(limits, "order", apigateway.PriorityCritical)
+		require.Len(t, permits, 2)
+
+		// quick replies of a busy service raise it by one per limit replies
+		for range 3 {
+			for _, permit := range permits {
+				permit.Done(time.Millisecond, false)
+			}
+			permits = fill(limits, "order", apigateway.PriorityCritical)
+		}
+		require.Len(t, permits, 3)
+	})
+
+	t.Run("Test_Disabled", func(t *testing.T) {
+		var limits *apigateway.ConcurrencyLimits
+		permit, ok := limits.Acquire("order", apigateway.PriorityLow)
+		require.True(t, ok)
+		permit.Done(time.Second, true)
+		permit.Release()
+	})
+
+	t.Run("Test_Invalid_Config", func(t *testing.T) {
+		for name, mutate := range map[string]func(*configs.ConcurrencyConfig){
+			"initial over max": func(c *configs.ConcurrencyConfig) { c.InitialLimit = 20 },
+			"zero min":         func(c *configs.ConcurrencyConfig) { c.MinLimit = 0 },
+			"backoff of 1":     func(c *configs.ConcurrencyConfig) { c.Backoff = 1 },
+			"no threshold":     func(c *configs.ConcurrencyConfig) { c.LatencyThreshold = 0 },
+			"share over 1":     func(c *configs.ConcurrencyConfig) { c.Priorities["low"] = 2 },
+		} {
+			config := testConcurrencyConfig()
+			mutate(&config)
+			_, err := apigateway.NewConcurrencyLimits(config, nil)
+			require.Error(t, err, name)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package api_gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/openapi"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Docs(t *testing.T) {
+	routes, err := apigateway.NewRouteTable([]configs.RouteConfig{
+		{Path: "/api/v1/auth/Login", Service: "auth", Auth: boolPtr(false)},
+		{Method: "GET", Path: "/api/v1/report/Export", Service: "report"},
+		{Path: "/api/v1/auth/{method}", Service: "auth"},
+		{Path: "/api/v1/order/{method}", Service: "order"},
+	})
+	require.NoError(t, err)
+	documents := []*openapi.Document{}
+	for _, spec := range []string{"../../apps/auth/api/auth/auth.openapi.json", "../../apps/order/api/order/order.openapi.json"} {
+		document, err := openapi.ReadFile(spec)
+		require.NoError(t, err)
+		documents = append(documents, document)
+	}
+	documents = append(documents, &openapi.Document{Paths: map[string]*openapi.PathItem{
+		"/api/v1/report/Export": {Post: &openapi.Operation{OperationId: "report.Export"}},
+	}})
+	merged, err := openapi.Merge(openapi.Info{Title: "Ecommerce API", Version: "v1"}, documents...)
+	require.NoError(t, err)
+	docs, err := apigateway.NewDocs("/docs", merged, routes, "ecommerce-cookie")
+	require.NoError(t, err)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		docs.ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+		return res
+	}
+
+	t.Run("Test_UI", func(t *testing.T) {
+		res := get("/docs")
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, "text/html; charset=utf-8", res.Header().Get("Content-Type"))
+		require.Contains(t, res.Body.String(), "SwaggerUIBundle")
+		require.Contains(t, res.Body.String(), `\/docs\/openapi.json`)
+		require.Equal(t, http.StatusOK, get("/docs/").Code)
+	})
+
+	t.Run("Test_Merged_Spec", func(t *testing.T) {
+		res := get("/docs/openapi.json")
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, "application/json", res.Header().Get("Content-Type"))
+		document := &openapi.Document{}
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), document))
+		require.Equal(t, "Ecommerce API", document.Info.Title)
+		require.Contains(t, document.Paths, "/api/v1/order/CreateOrder")
+		require.Equal(t, "ecommerce-cookie", document.Components.SecuritySchemes["session"].Name)
+
+		// public routes need no credential, the others any of the three
+		require.Empty(t, document.Paths["/api/v1/auth/Login"].Post.Security)
+		require.Len(t, document.Paths["/api/v1/order/CreateOrder"].Post.Security, 3)
+		// a method the gateway only routes for GET is left out
+		require.NotContains(t, document.Paths, "/api/v1/report/Export")
+	})
+
+	t.Run("Test_Unknown_Path_And_Method", func(t *testing.T) {
+		require.Equal(t, http.StatusNotFound, get("/docs/other.json").Code)
+		res := httptest.NewRecorder()
+		docs.ServeHTTP(res, httptest.NewRequest("POST", "/docs", nil))
+		require.Equal(t, http.StatusMethodNotAllowed, res.Code)
+	})
+}
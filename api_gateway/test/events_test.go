@@ -0,0 +1,153 @@
+package api_gateway_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// fakeEventSource hands every subscription the events sent to its user.
+type fakeEventSource struct {
+	mu            sync.Mutex
+	subscriptions map[string]chan apigateway.Event
+	lastEventIds  []string
+}
+
+func (s *fakeEventSource) Subscribe(ctx context.Context, userId, lastEventId string) (<-chan apigateway.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make(chan apigateway.Event, 8)
+	s.subscriptions[userId] = events
+	s.lastEventIds = append(s.lastEventIds, lastEventId)
+	return events, nil
+}
+
+func (s *fakeEventSource) send(t *testing.T, userId string, event apigateway.Event) {
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_, ok := s.subscriptions[userId]
+		return ok
+	}, time.Second, 10*time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[userId] <- event
+}
+
+func newTestEventBridge(t *testing.T) (*httptest.Server, *fakeEventSource) {
+	sessions := &fakeSessionStore{sessions: map[string]*identity.Identity{
+		"session-1": {UserId: "user-1", SessionId: "session-1"},
+	}}
+	authenticator, _ := newTestAuthenticator(t, sessions)
+	source := &fakeEventSource{subscriptions: map[string]chan apigateway.Event{}}
+	bridge := apigateway.NewEventBridge(source, authenticator, configs.EventsConfig{
+		HeartbeatInterval:     50 * time.Millisecond,
+		ReconnectDelay:        time.Second,
+		MaxConnectionsPerUser: 1,
+	}, "http://shop.local")
+	server := httptest.NewServer(bridge)
+	t.Cleanup(server.Close)
+	return server, source
+}
+
+func openSSE(t *testing.T, ctx context.Context, server *httptest.Server, lastEventId string) *http.Response {
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	require.NoError(t, err)
+	req.AddCookie(sessionCookie(t, "session-1"))
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return res
+}
+
+func Test_EventBridge_SSE(t *testing.T) {
+	server, source := newTestEventBridge(t)
+
+	t.Run("Test_Unauthorized_Without_Session", func(t *testing.T) {
+		res, err := http.Get(server.URL + "/events")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("Test_Stream_Events_And_Heartbeats", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		res := openSSE(t, ctx, server, "41")
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+		reader := bufio.NewReader(res.Body)
+		readFrame := func() string {
+			var frame strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				require.NoError(t, err)
+				if line == "\n" {
+					return frame.String()
+				}
+				frame.WriteString(line)
+			}
+		}
+		require.Equal(t, "retry: 1000\n", readFrame())
+		require.Equal(t, ": heartbeat\n", readFrame())
+
+		source.send(t, "user-1", apigateway.Event{Id: "42", Topic: "order.status", Data: []byte(`{"status":"paid"}`)})
+		frame := readFrame()
+		for frame == ": heartbeat\n" {
+			frame = readFrame()
+		}
+		require.Equal(t, "id: 42\nevent: order.status\ndata: {\"status\":\"paid\"}\n", frame)
+		require.Contains(t, source.lastEventIds, "41")
+
+		t.Run("Test_Connection_Limit", func(t *testing.T) {
+			res := openSSE(t, context.Background(), server, "")
+			defer res.Body.Close()
+			require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+		})
+	})
+}
+
+func Test_EventBridge_WebSocket(t *testing.T) {
+	server, source := newTestEventBridge(t)
+	dial := func(origin string) (*websocket.Conn, error) {
+		config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/events?last_event_id=7", origin)
+		require.NoError(t, err)
+		config.Header.Set("Cookie", sessionCookie(t, "session-1").String())
+		return websocket.DialConfig(config)
+	}
+
+	_, err := dial("http://evil.local")
+	require.Error(t, err)
+
+	conn, err := dial("http://shop.local")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	source.send(t, "user-1", apigateway.Event{Id: "8", Topic: "notification", Data: []byte(`{"text":"hi"}`)})
+	var message apigateway.EventMessage
+	for message.Type != "event" {
+		require.NoError(t, websocket.JSON.Receive(conn, &message))
+	}
+	require.Equal(t, "8", message.Id)
+	require.Equal(t, "notification", message.Topic)
+	require.JSONEq(t, `{"text":"hi"}`, string(message.Data))
+	require.Contains(t, source.lastEventIds, "7")
+
+	require.NoError(t, websocket.JSON.Receive(conn, &message))
+	require.Equal(t, "heartbeat", message.Type)
+}
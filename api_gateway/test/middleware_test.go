@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
 	"github.com/stretchr/testify/require"
 )
 
@@ -44,8 +45,15 @@ func Test_CorsMiddleware(t *testing.T) {
 }
 
 func Test_AuthMiddleware(t *testing.T) {
-	t.Run("Skip check auth when path in skip check path", func(t *testing.T) {
-		handler := apigateway.AuthMiddleware(mockHandler())
+	sessions := &fakeSessionStore{sessions: map[string]*identity.Identity{
+		"session-1": {UserId: "user-1", Roles: []string{"customer"}, SessionId: "session-1"},
+	}}
+	authenticator, signer := newTestAuthenticator(t, sessions)
+
+	t.Run("Skip check auth when route does not require auth", func(t *testing.T) {
+		routes, err := apigateway.LoadRouteTable()
+		require.NoError(t, err)
+		handler := apigateway.MiddlewareChain(mockHandler(), apigateway.RouteMiddleware(routes), apigateway.AuthMiddleware(authenticator))
 		request := httptest.NewRequest("GET", "/api/v1/auth/Login", nil)
 		response := httptest.NewRecorder()
 		handler.ServeHTTP(response, request)
@@ -54,13 +62,56 @@ func Test_AuthMiddleware(t *testing.T) {
 	})
 
 	t.Run("Unauthorize when access resource with no credentials", func(t *testing.T) {
-		handler := apigateway.AuthMiddleware(mockHandler())
+		handler := apigateway.AuthMiddleware(authenticator)(mockHandler())
 		req := httptest.NewRequest("POST", "/api/v1/order/GetOrderbyId", nil)
 		res := httptest.NewRecorder()
 		handler.ServeHTTP(res, req)
 
 		require.Equal(t, http.StatusUnauthorized, res.Code)
 	})
+
+	t.Run("Forward signed identity and drop forged headers", func(t *testing.T) {
+		var forwarded http.Header
+		handler := apigateway.AuthMiddleware(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			forwarded = r.Header.Clone()
+			id, ok := identity.FromContext(r.Context())
+			require.True(t, ok)
+			require.Equal(t, "user-1", id.UserId)
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest("POST", "/api/v1/order/GetOrderbyId", nil)
+		req.AddCookie(sessionCookie(t, "session-1"))
+		req.Header.Set(identity.Header, "forged")
+		req.Header.Set(identity.UserIdHeader, "admin")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Empty(t, forwarded.Get(identity.UserIdHeader))
+		id, err := signer.Verify(forwarded.Get(identity.Header))
+		require.NoError(t, err)
+		require.Equal(t, "user-1", id.UserId)
+		require.Equal(t, "session-1", id.SessionId)
+	})
+
+	t.Run("Drop forged headers on public routes", func(t *testing.T) {
+		routes, err := apigateway.LoadRouteTable()
+		require.NoError(t, err)
+		var forwarded http.Header
+		handler := apigateway.MiddlewareChain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			forwarded = r.Header.Clone()
+			w.WriteHeader(http.StatusOK)
+		}), apigateway.RouteMiddleware(routes), apigateway.AuthMiddleware(authenticator))
+		req := httptest.NewRequest("GET", "/api/v1/auth/Login", nil)
+		req.Header.Set(identity.Header, "forged")
+		req.Header.Set(identity.UserIdHeader, "admin")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Empty(t, forwarded.Get(identity.Header))
+		require.Empty(t, forwarded.Get(identity.UserIdHeader))
+	})
 }
 
 func Test_MiddlewareChain(t *testing.T) {
@@ -0,0 +1,105 @@
+package api_gateway_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func replyWithStatus(t *testing.T, statusCode int) *nats.Msg {
+	codec, err := custom_nats.GetEnvelopeCodec(custom_nats.EnvelopeJSON)
+	require.NoError(t, err)
+	data, err := codec.MarshalResponse(&custom_nats.Response{StatusCode: statusCode, Headers: http.Header{}})
+	require.NoError(t, err)
+	return &nats.Msg{Data: data, Header: nats.Header{}}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func Test_SendWithPolicy(t *testing.T) {
+	policy := configs.RequestPolicy{Idempotent: boolPtr(true), Retries: intPtr(2), Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+	t.Run("Test_Retry_Until_Available", func(t *testing.T) {
+		var calls atomic.Int32
+		reply, err := apigateway.SendWithPolicy(context.Background(), policy, func(ctx context.Context) (*nats.Msg, *custom_nats.ReplyStream, error) {
+			switch calls.Add(1) {
+			case 1:
+				return nil, nil, nats.ErrNoResponders
+			case 2:
+				return replyWithStatus(t, http.StatusServiceUnavailable), nil, nil
+			}
+			return replyWithStatus(t, http.StatusOK), nil, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, reply.Response.StatusCode)
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("Test_Give_Up_After_Retries", func(t *testing.T) {
+		var calls atomic.Int32
+		reply, err := apigateway.SendWithPolicy(context.Background(), policy, func(ctx context.Context) (*nats.Msg, *custom_nats.ReplyStream, error) {
+			calls.Add(1)
+			return replyWithStatus(t, http.StatusServiceUnavailable), nil, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, reply.Response.StatusCode)
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("Test_No_Retry_For_Non_Idempotent", func(t *testing.T) {
+		var calls atomic.Int32
+		unsafe := policy
+		unsafe.Idempotent = nil
+		_, err := apigateway.SendWithPolicy(context.Background(), unsafe, func(ctx context.Context) (*nats.Msg, *custom_nats.ReplyStream, error) {
+			calls.Add(1)
+			return nil, nil, nats.ErrNoResponders
+		})
+		require.ErrorIs(t, err, nats.ErrNoResponders)
+		require.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Test_No_Retry_On_Timeout", func(t *testing.T) {
+		var calls atomic.Int32
+		_, err := apigateway.SendWithPolicy(context.Background(), policy, func(ctx context.Context) (*nats.Msg, *custom_nats.ReplyStream, error) {
+			calls.Add(1)
+			return nil, nil, context.DeadlineExceeded
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Test_Streamed_Reply_Without_Stream", func(t *testing.T) {
+		_, err := apigateway.SendWithPolicy(context.Background(), policy, func(ctx context.Context) (*nats.Msg, *custom_nats.ReplyStream, error) {
+			head := replyWithStatus(t, http.StatusOK)
+			head.Header.Set(custom_nats.StreamFrameHeader, custom_nats.StreamFrameHead)
+			return head, nil, nil
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("Test_Hedge_Slow_Request", func(t *testing.T) {
+		hedged := configs.RequestPolicy{Idempotent: boolPtr(true), Retries: intPtr(0), HedgeDelay: 10 * time.Millisecond, MaxHedges: intPtr(1)}
+		var calls atomic.Int32
+		reply, err := apigateway.SendWithPolicy(context.Background(), hedged, func(ctx context.Context) (*nats.Msg, *custom_nats.ReplyStream, error) {
+			if calls.Add(1) == 1 {
+				// the first copy hangs until the hedge wins
+				<-ctx.Done()
+				return nil, nil, ctx.Err()
+			}
+			return replyWithStatus(t, http.StatusOK), nil, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, reply.Response.StatusCode)
+		require.Equal(t, int32(2), calls.Load())
+	})
+}
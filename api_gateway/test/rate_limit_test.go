@@ -0,0 +1,329 @@
+package api_gateway_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/circuitbreaker"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	ratelimiter "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/rate_limiter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// windowLimiter allows limit requests per key and records the keys it counted.
+// Peek neither counts nor records.
+type windowLimiter struct {
+	limit  int
+	window time.Duration
+	err    error
+	counts map[string]int
+	keys   []string
+}
+
+func (l *windowLimiter) Allow(ctx context.Context, key string) (ratelimiter.Result, error) {
+	l.keys = append(l.keys, key)
+	if l.err != nil {
+		return ratelimiter.Result{}, l.err
+	}
+	l.counts[key]++
+	allowed := l.counts[key] <= l.limit
+	result := ratelimiter.Result{
+		Allowed:   allowed,
+		Limit:     l.limit,
+		Remaining: max(l.limit-l.counts[key], 0),
+		ResetAt:   time.Now().Add(l.window),
+		Window:    l.window,
+	}
+	if !allowed {
+		result.RetryAfter = 300 * time.Millisecond
+	}
+	return result, nil
+}
+
+func (l *windowLimiter) Peek(ctx context.Context, key string) (ratelimiter.Result, error) {
+	if l.err != nil {
+		return ratelimiter.Result{}, l.err
+	}
+	allowed := l.counts[key] < l.limit
+	result := ratelimiter.Result{
+		Allowed:   allowed,
+		Limit:     l.limit,
+		Remaining: max(l.limit-l.counts[key]-1, 0),
+		ResetAt:   time.Now().Add(l.window),
+		Window:    l.window,
+	}
+	if !allowed {
+		result.RetryAfter = 300 * time.Millisecond
+	}
+	return result, nil
+}
+
+// testLimiters builds windowLimiters, kept by their limit.
+type testLimiters map[int]*windowLimiter
+
+func (l testLimiters) factory(algorithm ratelimiter.Algorithm, limit int, window time.Duration) ratelimiter.Limiter {
+	limiter := &windowLimiter{limit: limit, window: window, counts: map[string]int{}}
+	l[limit] = limiter
+	return limiter
+}
+
+func testRateLimitConfig() configs.RateLimitConfig {
+	return configs.RateLimitConfig{
+		TrustedProxies: []string{"10.0.0.0/8"},
+		PlanRolePrefix: "plan:",
+		DefaultPlan:    "free",
+		PreAuth:        []configs.RateLimitRule{{Limit: 4, Window: time.Minute}},
+		Plans: map[string][]configs.RateLimitRule{
+			"free": {{Limit: 2, Window: time.Minute}, {Limit: 100, Window: 24 * time.Hour}},
+			"pro":  {{Limit: 5, Window: time.Second}},
+		},
+		Policies: []configs.RateLimitPolicy{
+			{Method: "post", Path: "/api/v1/auth/Login", Key: "ip", Limits: []configs.RateLimitRule{{Limit: 1, Window: time.Minute}}},
+			{Path: "/api/v1/order/*", Key: "tenant"},
+			{Path: "/api/v1/public/{name}", Key: "api_key", Limits: []configs.RateLimitRule{{Limit: 3, Window: time.Minute}}},
+			{Path: "/api/v1/*", Key: "user"},
+		},
+	}
+}
+
+func rateLimitRequest(method, path string, caller *identity.Identity) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = "192.168.1.7:4000"
+	if caller != nil {
+		req = req.WithContext(identity.NewContext(req.Context(), caller, ""))
+	}
+	return req
+}
+
+func Test_RateLimitPolicies(t *testing.T) {
+	t.Run("Test_Client_Address", func(t *testing.T) {
+		policies, err := apigateway.NewRateLimitPolicies(testRateLimitConfig(), testLimiters{}.factory)
+		require.NoError(t, err)
+
+		req := rateLimitRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Forwarded-For", "1.2.3.4")
+		require.Equal(t, "192.168.1.7", policies.ClientAddress(req), "an untrusted peer cannot forward")
+
+		req.RemoteAddr = "10.0.0.2:4000"
+		req.Header.Set("X-Forwarded-For", "6.6.6.6, 1.2.3.4, 10.0.0.9")
+		require.Equal(t, "1.2.3.4", policies.ClientAddress(req), "the first untrusted hop from the right is the client")
+
+		req.Header.Set("X-Forwarded-For", "10.0.0.9")
+		require.Equal(t, "10.0.0.9", policies.ClientAddress(req))
+
+		req.Header.Del("X-Forwarded-For")
+		require.Equal(t, "10.0.0.2", policies.ClientAddress(req))
+	})
+
+	t.Run("Test_Keys_Per_Policy", func(t *testing.T) {
+		limiters := testLimiters{}
+		policies, err := apigateway.NewRateLimitPolicies(testRateLimitConfig(), limiters.factory)
+		require.NoError(t, err)
+
+		_, limited, err := policies.Allow(rateLimitRequest(http.MethodPost, "/api/v1/auth/Login", nil))
+		require.NoError(t, err)
+		require.True(t, limited)
+		_, _, err = policies.Allow(rateLimitRequest(http.MethodPost, "/api/v1/order/CreateOrder", &identity.Identity{UserId: "u1", TenantId: "acme"}))
+		require.NoError(t, err)
+		_, _, err = policies.Allow(rateLimitRequest(http.MethodPost, "/api/v1/public/Search", &identity.Identity{UserId: "api_key:k1"}))
+		require.NoError(t, err)
+		_, _, err = policies.Allow(rateLimitRequest(http.MethodPost, "/api/v1/public/Search", &identity.Identity{UserId: "u1"}))
+		require.NoError(t, err)
+		_, _, err = policies.Allow(rateLimitRequest(http.MethodPost, "/api/v1/cart/Get", &identity.Identity{UserId: "u1", Roles: []string{"plan:pro"}}))
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"POST /api/v1/auth/Login|ip:192.168.1.7"}, limiters[1].keys)
+		require.Equal(t, []string{"/api/v1/order/* plan:free|tenant:acme"}, limiters[2].keys)
+		require.Equal(t, []string{"/api/v1/public/{name}|api_key:k1", "/api/v1/public/{name}|ip:192.168.1.7"}, limiters[3].keys)
+		require.Equal(t, []string{"/api/v1/* plan:pro|user:u1"}, limiters[5].keys)
+	})
+
+	t.Run("Test_No_Policy_Matches", func(t *testing.T) {
+		limiters := testLimiters{}
+		policies, err := apigateway.NewRateLimitPolicies(testRateLimitConfig(), limiters.factory)
+		require.NoError(t, err)
+		_, limited, err := policies.Allow(rateLimitRequest(http.MethodGet, "/api/v1/auth/Login", nil))
+		require.NoError(t, err)
+		require.True(t, limited, "the catch-all policy applies to other methods")
+		_, limited, err = policies.Allow(rateLimitRequest(http.MethodGet, "/metrics", nil))
+		require.NoError(t, err)
+		require.False(t, limited)
+	})
+
+	t.Run("Test_Invalid_Config", func(t *testing.T) {
+		for name, mutate := range map[string]func(*configs.RateLimitConfig){
+			"unknown key":       func(c *configs.RateLimitConfig) { c.Policies[0].Key = "session" },
+			"zero limit":        func(c *configs.RateLimitConfig) { c.Policies[0].Limits[0].Limit = 0 },
+			"unknown algorithm": func(c *configs.RateLimitConfig) { c.Policies[0].Limits[0].Algorithm = "fixed_window" },
+			"bad pattern":       func(c *configs.RateLimitConfig) { c.Policies[0].Path = "api/v1" },
+			"no default plan":   func(c *configs.RateLimitConfig) { c.DefaultPlan = "gold" },
+			"bad proxy":         func(c *configs.RateLimitConfig) { c.TrustedProxies = []string{"proxy.local"} },
+		} {
+			config := testRateLimitConfig()
+			mutate(&config)
+			_, err := apigateway.NewRateLimitPolicies(config, testLimiters{}.factory)
+			require.Error(t, err, name)
+		}
+	})
+}
+
+func Test_RateLimitMiddleware(t *testing.T) {
+	send := func(handler http.Handler, caller *identity.Identity) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, rateLimitRequest(http.MethodPost, "/api/v1/cart/Get", caller))
+		return res
+	}
+
+	t.Run("Test_Allowed_With_Quota_Headers", func(t *testing.T) {
+		policies, err := apigateway.NewRateLimitPolicies(testRateLimitConfig(), testLimiters{}.factory)
+		require.NoError(t, err)
+		res := send(apigateway.RateLimitMiddleware(policies)(mockHandler()), &identity.Identity{UserId: "u1"})
+		require.Equal(t, http.StatusOK, res.Code)
+		// the per minute limit of the free plan has the least room left
+		require.Equal(t, "2", res.Header().Get("RateLimit-Limit"))
+		require.Equal(t, "1", res.Header().Get("RateLimit-Remaining"))
+		require.Equal(t, "60", res.Header().Get("RateLimit-Reset"))
+		require.Equal(t, "2;w=60", res.Header().Get("RateLimit-Policy"))
+		require.Empty(t, res.Header().Get("Retry-After"))
+	})
+
+	t.Run("Test_Stacked_Limit_Denies", func(t *testing.T) {
+		limiters := testLimiters{}
+		policies, err := apigateway.NewRateLimitPolicies(testRateLimitConfig(), limiters.factory)
+		require.NoError(t, err)
+		handler := apigateway.RateLimitMiddleware(policies)(mockHandler())
+		caller := &identity.Identity{UserId: "u1"}
+		send(handler, caller)
+		send(handler, caller)
+		res := send(handler, caller)
+		require.Equal(t, http.StatusTooManyRequests, res.Code)
+		require.Equal(t, "0", res.Header().Get("RateLimit-Remaining"))
+		require.Equal(t, "1", res.Header().Get("Retry-After"))
+		require.Contains(t, res.Body.String(), "Too_Many_Requests")
+		require.Len(t, limiters[100].keys, 2, "the daily limit is not counted once the minute one denies")
+
+		res = send(handler, &identity.Identity{UserId: "u2"})
+		require.Equal(t, http.StatusOK, res.Code, "users are counted apart")
+	})
+
+	t.Run("Test_Denied_Request_Not_Counted_By_Other_Limits", func(t *testing.T) {
+		limiters := testLimiters{}
+		config := testRateLimitConfig()
+		// the limit that denies comes last
+		config.Plans["free"] = []configs.RateLimitRule{{Limit: 100, Window: 24 * time.Hour}, {Limit: 2, Window: time.Minute}}
+		policies, err := apigateway.NewRateLimitPolicies(config, limiters.factory)
+		require.NoError(t, err)
+		handler := apigateway.RateLimitMiddleware(policies)(mockHandler())
+		caller := &identity.Identity{UserId: "u1"}
+		for range 2 {
+			require.Equal(t, http.StatusOK, send(handler, caller).Code)
+		}
+		for range 3 {
+			require.Equal(t, http.StatusTooManyRequests, send(handler, caller).Code)
+		}
+		require.Len(t, limiters[100].keys, 2, "the daily limit only counts the allowed requests")
+	})
+
+	t.Run("Test_Pre_Auth_Limit_Per_Client", func(t *testing.T) {
+		limiters := testLimiters{}
+		policies, err := apigateway.NewRateLimitPolicies(testRateLimitConfig(), limiters.factory)
+		require.NoError(t, err)
+		// credentials are checked after the limit, and rejected
+		unauthorized := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+		handler := apigateway.ClientRateLimitMiddleware(policies)(unauthorized)
+		for range 4 {
+			require.Equal(t, http.StatusUnauthorized, send(handler, nil).Code)
+		}
+		res := send(handler, nil)
+		require.Equal(t, http.StatusTooManyRequests, res.Code)
+		require.Equal(t, "pre_auth|ip:192.168.1.7", limiters[4].keys[0])
+	})
+
+	t.Run("Test_Limiter_Error", func(t *testing.T) {
+		limiters := testLimiters{}
+		policies, err := apigateway.NewRateLimitPolicies(testRateLimitConfig(), limiters.factory)
+		require.NoError(t, err)
+		limiters[2].err = errors.New("redis is down")
+		res := send(apigateway.RateLimitMiddleware(policies)(mockHandler()), &identity.Identity{UserId: "u1"})
+		require.Equal(t, http.StatusServiceUnavailable, res.Code)
+		require.Empty(t, res.Header().Get("RateLimit-Limit"))
+	})
+}
+
+func Test_FallbackLimiterFactory(t *testing.T) {
+	newBreaker := func() *circuitbreaker.Breaker[ratelimiter.Result] {
+		return circuitbreaker.NewBreaker[ratelimiter.Result](&circuitbreaker.Config{
+			Name: "rate-limit-test", MaxRequests: 1, Interval: 30, Timeout: 10, FailureThreshold: 1,
+		})
+	}
+	redisDown := func(algorithm ratelimiter.Algorithm, limit int, window time.Duration) ratelimiter.Limiter {
+		return &windowLimiter{limit: limit, window: window, err: errors.New("redis is down"), counts: map[string]int{}}
+	}
+	gathered := func(registry *prometheus.Registry) map[string]float64 {
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		values := map[string]float64{}
+		for _, family := range families {
+			for _, m := range family.GetMetric() {
+				name := family.GetName()
+				for _, label := range m.GetLabel() {
+					name += "," + label.GetValue()
+				}
+				if m.GetCounter() != nil {
+					values[name] = m.GetCounter().GetValue()
+				} else {
+					values[name] = m.GetGauge().GetValue()
+				}
+			}
+		}
+		return values
+	}
+
+	t.Run("Test_Local_Share_Of_Instances", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		breaker := newBreaker()
+		factory, err := apigateway.FallbackLimiterFactory(redisDown, configs.RateLimitFallbackConfig{Mode: "local", Instances: 3}, breaker, apigateway.NewRateLimitMetrics(registry, breaker))
+		require.NoError(t, err)
+		limiter := factory(ratelimiter.SlidingWindow, 7, time.Hour)
+		for range 3 {
+			result, err := limiter.Allow(context.Background(), "client")
+			require.NoError(t, err)
+			require.True(t, result.Allowed)
+		}
+		result, err := limiter.Allow(context.Background(), "client")
+		require.NoError(t, err)
+		require.False(t, result.Allowed, "each of 3 instances allows 3 of 7")
+
+		values := gathered(registry)
+		require.Equal(t, float64(1), values["rate_limit_degraded"])
+		require.Equal(t, float64(3), values["rate_limit_fallback_total,local,allowed"])
+		require.Equal(t, float64(1), values["rate_limit_fallback_total,local,denied"])
+	})
+
+	t.Run("Test_Fail_Closed", func(t *testing.T) {
+		breaker := newBreaker()
+		factory, err := apigateway.FallbackLimiterFactory(redisDown, configs.RateLimitFallbackConfig{Mode: "closed", Instances: 1}, breaker, apigateway.NewRateLimitMetrics(prometheus.NewRegistry(), breaker))
+		require.NoError(t, err)
+		policies, err := apigateway.NewRateLimitPolicies(testRateLimitConfig(), factory)
+		require.NoError(t, err)
+		res := httptest.NewRecorder()
+		apigateway.RateLimitMiddleware(policies)(mockHandler()).ServeHTTP(res, rateLimitRequest(http.MethodPost, "/api/v1/cart/Get", nil))
+		require.Equal(t, http.StatusServiceUnavailable, res.Code)
+	})
+
+	t.Run("Test_Invalid_Config", func(t *testing.T) {
+		_, err := apigateway.FallbackLimiterFactory(redisDown, configs.RateLimitFallbackConfig{Mode: "retry", Instances: 1}, newBreaker(), nil)
+		require.Error(t, err)
+		_, err = apigateway.FallbackLimiterFactory(redisDown, configs.RateLimitFallbackConfig{Mode: "local"}, newBreaker(), nil)
+		require.Error(t, err)
+	})
+}
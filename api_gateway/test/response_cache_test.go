@@ -0,0 +1,143 @@
+package api_gateway_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	cache_pkg "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/cache"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCachedRoutes(t *testing.T) *apigateway.RouteTable {
+	table, err := apigateway.NewRouteTable([]configs.RouteConfig{
+		{Method: "GET", Path: "/api/v2/products/{id}", Service: "product", Rewrite: "/api/v1/product/GetProduct", Auth: boolPtr(false), Cache: &configs.RouteCacheConfig{
+			TTL: time.Minute, VaryHeaders: []string{"Accept-Language"}, Tags: []string{"product:{id}"},
+		}},
+		{Method: "GET", Path: "/api/v2/orders/{id}", Service: "order", Rewrite: "/api/v1/order/GetOrderById", Cache: &configs.RouteCacheConfig{TTL: time.Minute}},
+		{Path: "/api/{version}/{service}/{method}", Service: "{service}", Subject: "/api/{version}/{service}"},
+	})
+	require.NoError(t, err)
+	return table
+}
+
+func Test_ResponseCache(t *testing.T) {
+	ctx := context.Background()
+	routes := newTestCachedRoutes(t)
+	cache := apigateway.NewResponseCache(cache_pkg.NewLocalCacheWithExpiration(time.Minute, time.Minute))
+	key := func(r *http.Request) (string, bool) {
+		match, err := routes.Match(r.Method, r.URL.Path)
+		require.NoError(t, err)
+		return cache.Key(r.Context(), r, match)
+	}
+	store := func(r *http.Request, response *custom_nats.Response) string {
+		match, err := routes.Match(r.Method, r.URL.Path)
+		require.NoError(t, err)
+		cacheKey, ok := cache.Key(r.Context(), r, match)
+		require.True(t, ok)
+		return cache.Store(ctx, cacheKey, match, response)
+	}
+	ok := func(body string) *custom_nats.Response {
+		return custom_nats.NewResponse(http.StatusOK, http.Header{}, []byte(body), "200 OK")
+	}
+
+	t.Run("Test_Store_And_Get", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v2/products/1?b=2&a=1", nil)
+		response := ok(`{"id":"1"}`)
+		etag := store(req, response)
+		require.NotEmpty(t, etag)
+		require.Equal(t, etag, response.Headers.Get("ETag"))
+
+		// the order of the query parameters does not matter
+		cacheKey, cacheable := key(httptest.NewRequest("GET", "/api/v2/products/1?a=1&b=2", nil))
+		require.True(t, cacheable)
+		cached, found := cache.Get(ctx, req, cacheKey)
+		require.True(t, found)
+		require.Equal(t, `{"id":"1"}`, string(cached.Body))
+		require.Equal(t, etag, cached.ETag)
+	})
+
+	t.Run("Test_Key_Varies", func(t *testing.T) {
+		base, _ := key(httptest.NewRequest("GET", "/api/v2/products/1", nil))
+		other, _ := key(httptest.NewRequest("GET", "/api/v2/products/2", nil))
+		require.NotEqual(t, base, other)
+
+		req := httptest.NewRequest("GET", "/api/v2/products/1", nil)
+		req.Header.Set("Accept-Language", "vi")
+		localized, _ := key(req)
+		require.NotEqual(t, base, localized)
+
+		// routes that need a session are cached per user
+		orderReq := func(userId string) *http.Request {
+			req := httptest.NewRequest("GET", "/api/v2/orders/1", nil)
+			return req.WithContext(identity.NewContext(req.Context(), &identity.Identity{UserId: userId}, "signed"))
+		}
+		first, ok := key(orderReq("user-1"))
+		require.True(t, ok)
+		second, _ := key(orderReq("user-2"))
+		require.NotEqual(t, first, second)
+		_, ok = key(httptest.NewRequest("GET", "/api/v2/orders/1", nil))
+		require.False(t, ok)
+	})
+
+	t.Run("Test_Not_Cacheable", func(t *testing.T) {
+		_, ok := key(httptest.NewRequest("GET", "/api/v1/product/GetProduct", nil))
+		require.False(t, ok)
+		req := httptest.NewRequest("GET", "/api/v2/products/1", nil)
+		req.Header.Set("Cache-Control", "no-store")
+		_, ok = key(req)
+		require.False(t, ok)
+
+		for _, response := range []*custom_nats.Response{
+			custom_nats.NewResponse(http.StatusNotFound, http.Header{}, nil, "404 Not Found"),
+			custom_nats.NewResponse(http.StatusOK, http.Header{"Cache-Control": {"no-store"}}, nil, "200 OK"),
+			custom_nats.NewResponse(http.StatusOK, http.Header{"Cache-Control": {"private, max-age=60"}}, nil, "200 OK"),
+			custom_nats.NewResponse(http.StatusOK, http.Header{"Cache-Control": {"max-age=0"}}, nil, "200 OK"),
+			custom_nats.NewResponse(http.StatusOK, http.Header{"Set-Cookie": {"session=1"}}, nil, "200 OK"),
+		} {
+			req := httptest.NewRequest("GET", "/api/v2/products/3", nil)
+			require.Empty(t, store(req, response))
+			cacheKey, _ := key(req)
+			_, found := cache.Get(ctx, req, cacheKey)
+			require.False(t, found)
+		}
+	})
+
+	t.Run("Test_No_Cache_Skips_Lookup", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v2/products/4", nil)
+		store(req, ok("{}"))
+		req.Header.Set("Cache-Control", "no-cache")
+		cacheKey, _ := key(req)
+		_, found := cache.Get(ctx, req, cacheKey)
+		require.False(t, found)
+	})
+
+	t.Run("Test_Invalidate_Tag", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v2/products/5", nil)
+		store(req, ok("{}"))
+		other := httptest.NewRequest("GET", "/api/v2/products/6", nil)
+		store(other, ok("{}"))
+
+		require.NoError(t, cache.Invalidate(ctx, "product:5"))
+		cacheKey, _ := key(req)
+		_, found := cache.Get(ctx, req, cacheKey)
+		require.False(t, found)
+		cacheKey, _ = key(other)
+		_, found = cache.Get(ctx, other, cacheKey)
+		require.True(t, found)
+	})
+}
+
+func Test_ResponseCache_Nil(t *testing.T) {
+	var cache *apigateway.ResponseCache
+	match, err := newTestCachedRoutes(t).Match("GET", "/api/v2/products/1")
+	require.NoError(t, err)
+	_, ok := cache.Key(context.Background(), httptest.NewRequest("GET", "/api/v2/products/1", nil), match)
+	require.False(t, ok)
+}
@@ -0,0 +1,104 @@
+package api_gateway_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apigateway "github.com/hoangdaochuz/ecommerce-microservice-golang/api_gateway"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/stretchr/testify/require"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func newTestRouteTable(t *testing.T) *apigateway.RouteTable {
+	table, err := apigateway.NewRouteTable([]configs.RouteConfig{
+		{Method: "GET", Path: "/callback", Service: "auth", Rewrite: "/api/v1/auth/Callback", Auth: boolPtr(false)},
+		{Method: "GET", Path: "/api/v2/orders/{id}", Service: "order", Rewrite: "/api/v1/order/GetOrderById"},
+		{Method: "GET", Path: "/api/v2/orders/{id}/export", Service: "order", Rewrite: "/api/v1/order/ExportOrder", Stream: true},
+		{Path: "/api/{version}/{service}/{method}", Service: "{service}", Subject: "/api/{version}/{service}"},
+	})
+	require.NoError(t, err)
+	return table
+}
+
+func Test_RouteTable_Match(t *testing.T) {
+	table := newTestRouteTable(t)
+
+	t.Run("Test_Rewrite_With_Path_Param", func(t *testing.T) {
+		match, err := table.Match("GET", "/api/v2/orders/42")
+		require.NoError(t, err)
+		require.Equal(t, "order", match.Target.ServiceName)
+		require.Equal(t, "/api/v1/order", match.Target.Subject)
+		require.Equal(t, "/api/v1/order/GetOrderById", match.Target.Path)
+		require.Equal(t, map[string]string{"id": "42"}, match.Target.Params)
+		require.True(t, match.Route.Auth)
+	})
+
+	t.Run("Test_Convention_Route", func(t *testing.T) {
+		match, err := table.Match("POST", "/api/v1/order/CreateOrder")
+		require.NoError(t, err)
+		require.Equal(t, "order", match.Target.ServiceName)
+		require.Equal(t, "/api/v1/order", match.Target.Subject)
+		require.Equal(t, "/api/v1/order/CreateOrder", match.Target.Path)
+		require.Empty(t, match.Target.Params)
+		require.Equal(t, apigateway.PriorityNormal, match.Route.Priority)
+		require.False(t, match.Route.Stream)
+	})
+
+	t.Run("Test_Streamed_Route", func(t *testing.T) {
+		match, err := table.Match("GET", "/api/v2/orders/42/export")
+		require.NoError(t, err)
+		require.True(t, match.Route.Stream)
+		require.Equal(t, "/api/v1/order/ExportOrder", match.Target.Path)
+	})
+
+	t.Run("Test_Route_Without_Auth", func(t *testing.T) {
+		match, err := table.Match("GET", "/callback")
+		require.NoError(t, err)
+		require.False(t, match.Route.Auth)
+		require.Equal(t, "/api/v1/auth/Callback", match.Target.Path)
+	})
+
+	for _, path := range []string{"/", "/api", "/api/v1/order", "/unknown/path/to/nothing", "/api/v1/order/*"} {
+		t.Run("Test_Not_Found_"+path, func(t *testing.T) {
+			_, err := table.Match("GET", path)
+			var appErr *shared.AppError
+			require.True(t, errors.As(err, &appErr))
+			require.Equal(t, http.StatusNotFound, appErr.StatusCode)
+		})
+	}
+
+	t.Run("Test_Method_Not_Allowed", func(t *testing.T) {
+		table, err := apigateway.NewRouteTable([]configs.RouteConfig{
+			{Method: "GET", Path: "/orders/{id}", Service: "order", Rewrite: "/api/v1/order/GetOrderById"},
+			{Method: "DELETE", Path: "/orders/{id}", Service: "order", Rewrite: "/api/v1/order/CancelOrder"},
+		})
+		require.NoError(t, err)
+		handler := apigateway.RouteMiddleware(table)(mockHandler())
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("PUT", "/orders/42", nil))
+		require.Equal(t, http.StatusMethodNotAllowed, res.Code)
+		require.Equal(t, "DELETE, GET", res.Header().Get("Allow"))
+	})
+}
+
+func Test_NewRouteTable_Invalid(t *testing.T) {
+	for _, route := range []configs.RouteConfig{
+		{Path: "orders", Service: "order"},
+		{Path: "/orders/{id}"},
+		{Path: "/orders/{id}/{id}", Service: "order"},
+		{Path: "/orders/{id", Service: "order"},
+		{Path: "/orders", Service: "order", Rewrite: "/api/v1/order/{id}"},
+		{Path: "/orders", Service: "order", MaxBodySize: -1},
+		{Path: "/orders", Service: "order", Priority: "urgent"},
+	} {
+		_, err := apigateway.NewRouteTable([]configs.RouteConfig{route})
+		require.Error(t, err, route.Path)
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"context"
 
 	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/nats-io/nats.go"
 	//
 )
 
 // NATS subject constants
 const (
+	SERVICE_NAME = "auth"
 	NATS_SUBJECT = "/api/v1/auth"
 
 	AUTH_LOGIN = NATS_SUBJECT + "/Login"
@@ -111,16 +113,55 @@ func NewAuthenticateServiceRouter(proxy *AuthenticateServiceProxy) *Authenticate
 }
 
 // Register registers all service methods with the NATS router
-func (r *AuthenticateServiceRouter) Register(natsRouter custom_nats.Router) {
+func (r *AuthenticateServiceRouter) Register(natsRouter *custom_nats.Router) {
 
-	natsRouter.RegisterRoute("POST", AUTH_LOGIN, r.proxy.Login)
+	custom_nats.Handle(natsRouter, "POST", AUTH_LOGIN, r.proxy.Login)
 
-	natsRouter.RegisterRoute("POST", AUTH_CALLBACK, r.proxy.Callback)
+	custom_nats.Handle(natsRouter, "POST", AUTH_CALLBACK, r.proxy.Callback)
 
-	natsRouter.RegisterRoute("POST", AUTH_VALIDATE_TOKEN, r.proxy.ValidateToken)
+	custom_nats.Handle(natsRouter, "POST", AUTH_VALIDATE_TOKEN, r.proxy.ValidateToken)
 
-	natsRouter.RegisterRoute("POST", AUTH_GET_MY_PROFILE, r.proxy.GetMyProfile)
+	custom_nats.Handle(natsRouter, "POST", AUTH_GET_MY_PROFILE, r.proxy.GetMyProfile)
 
-	natsRouter.RegisterRoute("POST", AUTH_LOGOUT, r.proxy.Logout)
+	custom_nats.Handle(natsRouter, "POST", AUTH_LOGOUT, r.proxy.Logout)
 
 }
+
+// AuthenticateServiceClient calls AuthenticateService of another service over NATS
+type AuthenticateServiceClient struct {
+	client *custom_nats.ServiceClient
+}
+
+var _ AuthenticateService = (*AuthenticateServiceClient)(nil)
+
+// NewAuthenticateServiceClient creates a new AuthenticateServiceClient instance
+func NewAuthenticateServiceClient(natsConn *nats.Conn, opts ...custom_nats.ServiceClientOption) *AuthenticateServiceClient {
+	return &AuthenticateServiceClient{
+		client: custom_nats.NewServiceClient(natsConn, SERVICE_NAME, NATS_SUBJECT, opts...),
+	}
+}
+
+// Login sends the request to AuthenticateService and waits for the reply
+func (c *AuthenticateServiceClient) Login(ctx context.Context, req *LoginRequest) (*RedirectResponse, error) {
+	return custom_nats.Call[LoginRequest, RedirectResponse](ctx, c.client, AUTH_LOGIN, req)
+}
+
+// Callback sends the request to AuthenticateService and waits for the reply
+func (c *AuthenticateServiceClient) Callback(ctx context.Context, req *CallbackRequest) (*custom_nats.Response, error) {
+	return custom_nats.Call[CallbackRequest, custom_nats.Response](ctx, c.client, AUTH_CALLBACK, req)
+}
+
+// ValidateToken sends the request to AuthenticateService and waits for the reply
+func (c *AuthenticateServiceClient) ValidateToken(ctx context.Context, req *ValidateTokenRequest) (*ValidateTokenResponse, error) {
+	return custom_nats.Call[ValidateTokenRequest, ValidateTokenResponse](ctx, c.client, AUTH_VALIDATE_TOKEN, req)
+}
+
+// GetMyProfile sends the request to AuthenticateService and waits for the reply
+func (c *AuthenticateServiceClient) GetMyProfile(ctx context.Context, req *EmptyRequest) (*GetMyProfileResponse, error) {
+	return custom_nats.Call[EmptyRequest, GetMyProfileResponse](ctx, c.client, AUTH_GET_MY_PROFILE, req)
+}
+
+// Logout sends the request to AuthenticateService and waits for the reply
+func (c *AuthenticateServiceClient) Logout(ctx context.Context, req *EmptyRequest) (*RedirectResponse, error) {
+	return custom_nats.Call[EmptyRequest, RedirectResponse](ctx, c.client, AUTH_LOGOUT, req)
+}
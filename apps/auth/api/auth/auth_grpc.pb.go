@@ -1,273 +0,0 @@
-// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
-// versions:
-// - protoc-gen-go-grpc v1.5.1
-// - protoc             v5.29.3
-// source: auth.proto
-
-package auth
-
-import (
-	context "context"
-	grpc "google.golang.org/grpc"
-	codes "google.golang.org/grpc/codes"
-	status "google.golang.org/grpc/status"
-)
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-// Requires gRPC-Go v1.64.0 or later.
-const _ = grpc.SupportPackageIsVersion9
-
-const (
-	AuthenticateService_Login_FullMethodName         = "/auth.AuthenticateService/Login"
-	AuthenticateService_Callback_FullMethodName      = "/auth.AuthenticateService/Callback"
-	AuthenticateService_ValidateToken_FullMethodName = "/auth.AuthenticateService/ValidateToken"
-	AuthenticateService_GetMyProfile_FullMethodName  = "/auth.AuthenticateService/GetMyProfile"
-	AuthenticateService_Logout_FullMethodName        = "/auth.AuthenticateService/Logout"
-)
-
-// AuthenticateServiceClient is the client API for AuthenticateService service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
-type AuthenticateServiceClient interface {
-	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*RedirectResponse, error)
-	Callback(ctx context.Context, in *CallbackRequest, opts ...grpc.CallOption) (*CallbackResponse, error)
-	ValidateToken(ctx context.Context, in *ValidateTokenRequest, opts ...grpc.CallOption) (*ValidateTokenResponse, error)
-	GetMyProfile(ctx context.Context, in *EmptyRequest, opts ...grpc.CallOption) (*GetMyProfileResponse, error)
-	Logout(ctx context.Context, in *EmptyRequest, opts ...grpc.CallOption) (*RedirectResponse, error)
-}
-
-type authenticateServiceClient struct {
-	cc grpc.ClientConnInterface
-}
-
-func NewAuthenticateServiceClient(cc grpc.ClientConnInterface) AuthenticateServiceClient {
-	return &authenticateServiceClient{cc}
-}
-
-func (c *authenticateServiceClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*RedirectResponse, error) {
-	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(RedirectResponse)
-	err := c.cc.Invoke(ctx, AuthenticateService_Login_FullMethodName, in, out, cOpts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *authenticateServiceClient) Callback(ctx context.Context, in *CallbackRequest, opts ...grpc.CallOption) (*CallbackResponse, error) {
-	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(CallbackResponse)
-	err := c.cc.Invoke(ctx, AuthenticateService_Callback_FullMethodName, in, out, cOpts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *authenticateServiceClient) ValidateToken(ctx context.Context, in *ValidateTokenRequest, opts ...grpc.CallOption) (*ValidateTokenResponse, error) {
-	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(ValidateTokenResponse)
-	err := c.cc.Invoke(ctx, AuthenticateService_ValidateToken_FullMethodName, in, out, cOpts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *authenticateServiceClient) GetMyProfile(ctx context.Context, in *EmptyRequest, opts ...grpc.CallOption) (*GetMyProfileResponse, error) {
-	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(GetMyProfileResponse)
-	err := c.cc.Invoke(ctx, AuthenticateService_GetMyProfile_FullMethodName, in, out, cOpts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *authenticateServiceClient) Logout(ctx context.Context, in *EmptyRequest, opts ...grpc.CallOption) (*RedirectResponse, error) {
-	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(RedirectResponse)
-	err := c.cc.Invoke(ctx, AuthenticateService_Logout_FullMethodName, in, out, cOpts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-// AuthenticateServiceServer is the server API for AuthenticateService service.
-// All implementations must embed UnimplementedAuthenticateServiceServer
-// for forward compatibility.
-type AuthenticateServiceServer interface {
-	Login(context.Context, *LoginRequest) (*RedirectResponse, error)
-	Callback(context.Context, *CallbackRequest) (*CallbackResponse, error)
-	ValidateToken(context.Context, *ValidateTokenRequest) (*ValidateTokenResponse, error)
-	GetMyProfile(context.Context, *EmptyRequest) (*GetMyProfileResponse, error)
-	Logout(context.Context, *EmptyRequest) (*RedirectResponse, error)
-	mustEmbedUnimplementedAuthenticateServiceServer()
-}
-
-// UnimplementedAuthenticateServiceServer must be embedded to have
-// forward compatible implementations.
-//
-// NOTE: this should be embedded by value instead of pointer to avoid a nil
-// pointer dereference when methods are called.
-type UnimplementedAuthenticateServiceServer struct{}
-
-func (UnimplementedAuthenticateServiceServer) Login(context.Context, *LoginRequest) (*RedirectResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
-}
-func (UnimplementedAuthenticateServiceServer) Callback(context.Context, *CallbackRequest) (*CallbackResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Callback not implemented")
-}
-func (UnimplementedAuthenticateServiceServer) ValidateToken(context.Context, *ValidateTokenRequest) (*ValidateTokenResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method ValidateToken not implemented")
-}
-func (UnimplementedAuthenticateServiceServer) GetMyProfile(context.Context, *EmptyRequest) (*GetMyProfileResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetMyProfile not implemented")
-}
-func (UnimplementedAuthenticateServiceServer) Logout(context.Context, *EmptyRequest) (*RedirectResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
-}
-func (UnimplementedAuthenticateServiceServer) mustEmbedUnimplementedAuthenticateServiceServer() {}
-func (UnimplementedAuthenticateServiceServer) testEmbeddedByValue()                             {}
-
-// UnsafeAuthenticateServiceServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to AuthenticateServiceServer will
-// result in compilation errors.
-type UnsafeAuthenticateServiceServer interface {
-	mustEmbedUnimplementedAuthenticateServiceServer()
-}
-
-func RegisterAuthenticateServiceServer(s grpc.ServiceRegistrar, srv AuthenticateServiceServer) {
-	// If the following call pancis, it indicates UnimplementedAuthenticateServiceServer was
-	// embedded by pointer and is nil.  This will cause panics if an
-	// unimplemented method is ever invoked, so we test this at initialization
-	// time to prevent it from happening at runtime later due to I/O.
-	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
-		t.testEmbeddedByValue()
-	}
-	s.RegisterService(&AuthenticateService_ServiceDesc, srv)
-}
-
-func _AuthenticateService_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(LoginRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(AuthenticateServiceServer).Login(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: AuthenticateService_Login_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AuthenticateServiceServer).Login(ctx, req.(*LoginRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _AuthenticateService_Callback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(CallbackRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(AuthenticateServiceServer).Callback(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: AuthenticateService_Callback_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AuthenticateServiceServer).Callback(ctx, req.(*CallbackRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _AuthenticateService_ValidateToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ValidateTokenRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(AuthenticateServiceServer).ValidateToken(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: AuthenticateService_ValidateToken_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AuthenticateServiceServer).ValidateToken(ctx, req.(*ValidateTokenRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _AuthenticateService_GetMyProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(EmptyRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(AuthenticateServiceServer).GetMyProfile(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: AuthenticateService_GetMyProfile_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AuthenticateServiceServer).GetMyProfile(ctx, req.(*EmptyRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _AuthenticateService_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(EmptyRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(AuthenticateServiceServer).Logout(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: AuthenticateService_Logout_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AuthenticateServiceServer).Logout(ctx, req.(*EmptyRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-// AuthenticateService_ServiceDesc is the grpc.ServiceDesc for AuthenticateService service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var AuthenticateService_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "auth.AuthenticateService",
-	HandlerType: (*AuthenticateServiceServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Login",
-			Handler:    _AuthenticateService_Login_Handler,
-		},
-		{
-			MethodName: "Callback",
-			Handler:    _AuthenticateService_Callback_Handler,
-		},
-		{
-			MethodName: "ValidateToken",
-			Handler:    _AuthenticateService_ValidateToken_Handler,
-		},
-		{
-			MethodName: "GetMyProfile",
-			Handler:    _AuthenticateService_GetMyProfile_Handler,
-		},
-		{
-			MethodName: "Logout",
-			Handler:    _AuthenticateService_Logout_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "auth.proto",
-}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
@@ -12,7 +13,9 @@ import (
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
 	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
 	di "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/dependency-injection"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
+	redis_pkg "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/redis"
 	"github.com/nats-io/nats.go"
 
 	// Import để trigger dependency registration
@@ -20,7 +23,6 @@ import (
 	_ "github.com/hoangdaochuz/ecommerce-microservice-golang/apps/auth/services/auth"
 	_ "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/cache"
 	_ "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/configs/redis"
-	_ "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/redis"
 )
 
 const (
@@ -48,8 +50,9 @@ func main() {
 
 	chiRouter := chi.NewRouter()
 	router := custom_nats.NewRouter(chiRouter)
+	router.Use(custom_nats.Logging())
 
-	var authServiceApp authService_api.AuthenticateService
+	var authServiceApp *auth_handler.AuthServiceApp
 
 	err = di.Resolve(func(authServiceAppImplement *auth_handler.AuthServiceApp) {
 		authServiceApp = authServiceAppImplement
@@ -57,6 +60,8 @@ func main() {
 	if err != nil {
 		log.Fatal("fail to get auth service app")
 	}
+	// not part of the proto api: only the gateway calls it, to resolve bearer tokens
+	custom_nats.Handle(router, "POST", identity.ResolveTokenPath, authServiceApp.ResolveIdentity)
 
 	authServiceProxy := authService_api.NewAuthenticateServiceProxy(authServiceApp)
 	authServiceClient := authService_api.NewAuthenticateServiceRouter(authServiceProxy)
@@ -66,16 +71,23 @@ func main() {
 		OtelEndpoint: config.GeneralConfig.OTLP_Endpoint,
 	})
 
+	_ = di.Resolve(func(redis *redis_pkg.Redis) {
+		server.RegisterCloser("redis", redis)
+	})
+
 	err = server.Start()
 	if err != nil {
-		_ = server.Stop()
+		_ = server.Shutdown(context.Background())
 		log.Fatal("fail to start auth service app")
 	}
 	shutdowSign := make(chan os.Signal, 1)
 	signal.Notify(shutdowSign, syscall.SIGINT, syscall.SIGTERM)
 	<-shutdowSign
 	logging.GetSugaredLogger().Infof("Shutting down authenticate service server")
-	err = server.Stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.NatsServer.ShutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	natsConn.Close()
 	if err != nil {
 		logging.GetSugaredLogger().Fatalf("fail to shut down authenticate service server: %v", err)
 	}
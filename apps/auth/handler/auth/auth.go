@@ -7,10 +7,10 @@ import (
 	auth_service "github.com/hoangdaochuz/ecommerce-microservice-golang/apps/auth/services/auth"
 	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
 	di "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/dependency-injection"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
 )
 
 type AuthServiceApp struct {
-	auth.UnimplementedAuthenticateServiceServer
 	authService auth_service.AuthServiceInterface
 	// other field
 }
@@ -36,6 +36,10 @@ func (a *AuthServiceApp) ValidateToken(ctx context.Context, req *auth.ValidateTo
 	return nil, nil
 }
 
+func (a *AuthServiceApp) ResolveIdentity(ctx context.Context, req *identity.ResolveTokenRequest) (*identity.Identity, error) {
+	return a.authService.ResolveIdentity(ctx, req)
+}
+
 func (a *AuthServiceApp) GetMyProfile(ctx context.Context, req *auth.EmptyRequest) (*auth.GetMyProfileResponse, error) {
 	return a.authService.GetMyProfile(ctx, req)
 }
@@ -3,6 +3,7 @@ package claims
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
@@ -18,6 +19,8 @@ type Claim struct {
 	Username       string   `json:"username"`
 	Email          string   `json:"email"`
 	Roles          []string `json:"roles"`
+	Scopes         []string `json:"scopes"`
+	TenantId       string   `json:"tenant_id"` // Zitadel organization of the user
 	AccessToken    string   `json:"access_token"`
 	RefreshToken   string   `json:"refresh_token"`
 	AuthSessionId  string   `json:"session_id"`
@@ -129,6 +132,8 @@ func (c *ClaimConverter) ConvertToInternalClaims(zitadelClaims *zitadel_pkg.Zita
 	return &Claim{
 		ExternalUserId: externalUserId,
 		Roles:          roles,
+		Scopes:         strings.Fields(zitadelClaims.Scope),
+		TenantId:       zitadelClaims.ResourceOwnerId,
 		Email:          zitadelClaims.Email,
 		// Username: zitadelClaims.PreferredUsername,
 		StandardClaims: jwt.StandardClaims{
@@ -153,11 +158,11 @@ func (c *ClaimConverter) ConvertToInternalClaims(zitadelClaims *zitadel_pkg.Zita
 
 type BearTokenGetter struct {
 	token      string
-	authorizer *zitadel_authorization.ZitadelAuthorizer
+	authorizer zitadel_authorization.Authorizer
 	ctx        context.Context
 }
 
-func NewBearTokenGetter(token string, authorizer *zitadel_authorization.ZitadelAuthorizer, ctx context.Context) *BearTokenGetter {
+func NewBearTokenGetter(token string, authorizer zitadel_authorization.Authorizer, ctx context.Context) *BearTokenGetter {
 	return &BearTokenGetter{
 		token:      token,
 		authorizer: authorizer,
@@ -16,6 +16,7 @@ import (
 	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
 	di "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/dependency-injection"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/httpclient"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
 	zitadel_pkg "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/zitadel"
 	zitadel_authentication "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/zitadel/authentication"
@@ -59,6 +60,8 @@ func getDefaultScopes() []zitadel_authentication.ScopeOps {
 		zitadel_authentication.WithAddressScope(),
 		zitadel_authentication.WithPhoneScope(),
 		zitadel_authentication.WithOfflineScope(),
+		// asks for the organization of the user, the tenant of the gateway
+		zitadel_authentication.WithScope("urn:zitadel:iam:user:resourceowner"),
 	}
 }
 
@@ -239,6 +242,26 @@ func (srv *AuthService) ValidateToken() (bool, error) {
 	return false, nil
 }
 
+// ResolveIdentity introspects a bearer token for the gateway.
+func (srv *AuthService) ResolveIdentity(ctx context.Context, req *identity.ResolveTokenRequest) (*identity.Identity, error) {
+	internalClaims, err := claims.NewBearTokenGetter(req.Token, srv.zitadelAuthorizer, ctx).Get()
+	if err != nil {
+		return nil, shared.NewUnauthorizedError("invalid bearer token").WithCause(err)
+	}
+	userId := internalClaims.UserId
+	if userId == "" {
+		userId = internalClaims.ExternalUserId
+	}
+	return &identity.Identity{
+		UserId:    userId,
+		Roles:     internalClaims.Roles,
+		Scopes:    internalClaims.Scopes,
+		SessionId: internalClaims.AuthSessionId,
+		TenantId:  internalClaims.TenantId,
+		ExpiresAt: internalClaims.ExpiresAt,
+	}, nil
+}
+
 func (srv *AuthService) GetMyProfile(ctx context.Context, req *auth.EmptyRequest) (*auth.GetMyProfileResponse, error) {
 	rCtx := ctx.Value(shared.HTTPRequest_ContextKey)
 	r := rCtx.(*http.Request)
@@ -293,6 +316,8 @@ type AuthServiceInterface interface {
 
 	ValidateToken() (bool, error)
 
+	ResolveIdentity(ctx context.Context, req *identity.ResolveTokenRequest) (*identity.Identity, error)
+
 	GetMyProfile(ctx context.Context, req *auth.EmptyRequest) (*auth.GetMyProfileResponse, error)
 
 	Logout(ctx context.Context, req *auth.EmptyRequest) (*auth.RedirectResponse, error)
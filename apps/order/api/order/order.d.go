@@ -6,11 +6,14 @@ import (
 	"context"
 
 	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/validator"
+	"github.com/nats-io/nats.go"
 	//
 )
 
 // NATS subject constants
 const (
+	SERVICE_NAME = "order"
 	NATS_SUBJECT = "/api/v1/order"
 	
 	
@@ -21,10 +24,6 @@ const (
 	
 )
 
-// Enum definitions
-
-
-
 // OrderService defines the service interface
 type OrderService interface {
 	
@@ -71,16 +70,53 @@ func NewOrderServiceRouter(proxy *OrderServiceProxy) *OrderServiceRouter {
 }
 
 // Register registers all service methods with the NATS router
-func (r *OrderServiceRouter) Register(natsRouter custom_nats.Router) {
+func (r *OrderServiceRouter) Register(natsRouter *custom_nats.Router) {
 	
-	natsRouter.RegisterRoute("POST", ORDER_CREATE_ORDER, r.proxy.CreateOrder)
+	custom_nats.Handle(natsRouter, "POST", ORDER_CREATE_ORDER, r.proxy.CreateOrder)
 	
-	natsRouter.RegisterRoute("POST", ORDER_GET_ORDER_BY_ID, r.proxy.GetOrderById)
+	custom_nats.Handle(natsRouter, "POST", ORDER_GET_ORDER_BY_ID, r.proxy.GetOrderById)
 	
 }
 
+// OrderServiceClient calls OrderService of another service over NATS
+type OrderServiceClient struct {
+	client *custom_nats.ServiceClient
+}
+
+var _ OrderService = (*OrderServiceClient)(nil)
+
+// NewOrderServiceClient creates a new OrderServiceClient instance
+func NewOrderServiceClient(natsConn *nats.Conn, opts ...custom_nats.ServiceClientOption) *OrderServiceClient {
+	return &OrderServiceClient{
+		client: custom_nats.NewServiceClient(natsConn, SERVICE_NAME, NATS_SUBJECT, opts...),
+	}
+}
+
+// CreateOrder sends the request to OrderService and waits for the reply
+func (c *OrderServiceClient) CreateOrder(ctx context.Context, req *CreateOrderRequest) (*CreateOrderResponse, error) {
+	return custom_nats.Call[CreateOrderRequest, CreateOrderResponse](ctx, c.client, ORDER_CREATE_ORDER, req)
+}
 
+// GetOrderById sends the request to OrderService and waits for the reply
+func (c *OrderServiceClient) GetOrderById(ctx context.Context, req *GetOrderByIdRequest) (*OrderResponse, error) {
+	return custom_nats.Call[GetOrderByIdRequest, OrderResponse](ctx, c.client, ORDER_GET_ORDER_BY_ID, req)
+}
 
 
 
+// Validate checks the field rules declared in the proto file
+func (x *GetOrderByIdRequest) Validate() error {
+	return validator.Collect(
+		validator.Required("Id", x.GetId()),
+		validator.UUID("Id", x.GetId()),
+	)
+}
+
+// Validate checks the field rules declared in the proto file
+func (x *CreateOrderRequest) Validate() error {
+	return validator.Collect(
+		validator.Required("customer_id", x.GetCustomerId()),
+		validator.MaxLen("customer_id", x.GetCustomerId(), 64),
+	)
+}
 
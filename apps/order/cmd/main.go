@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
@@ -13,6 +14,7 @@ import (
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
 	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
 	di "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/dependency-injection"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
 	"github.com/nats-io/nats.go"
 )
@@ -29,6 +31,13 @@ func main() {
 
 	chi := chi.NewRouter()
 	router := custom_nats.NewRouter(chi)
+	router.Use(custom_nats.Logging())
+	signer, err := identity.LoadSigner()
+	if err != nil {
+		log.Fatal("fail to create identity signer")
+	}
+	// every order method is called for a user the gateway authenticated
+	router.Use(custom_nats.Authenticate(signer))
 	var orderApp *order.OrderServiceApp
 	_ = di.Resolve(func(orderImplement *order.OrderServiceApp) {
 		logging.GetSugaredLogger().Infof("orderImplement: %v", orderImplement)
@@ -37,23 +46,28 @@ func main() {
 	orderAppProxy := order_api.NewOrderServiceProxy(orderApp)
 
 	orderRouterClient := order_api.NewOrderServiceRouter(orderAppProxy)
-	order_configs.NewOrderDatabase()
 
 	server := custom_nats.NewServer(natsConn, router, order_api.NATS_SUBJECT, orderRouterClient, &custom_nats.ServerConfig{
 		ServiceName:  "order",
 		OtelEndpoint: config.GeneralConfig.OTLP_Endpoint,
 	})
+	_ = di.Resolve(func(orderDb *order_configs.OrderDatabase) {
+		server.RegisterCloser("order database", orderDb)
+	})
 	err = server.Start()
 	if err != nil {
-		_ = server.Stop()
+		_ = server.Shutdown(context.Background())
 		log.Fatal("fail to start order server")
 	}
 	shutdownChan := make(chan os.Signal, 1)
 	signal.Notify(shutdownChan, syscall.SIGTERM, syscall.SIGINT)
 	<-shutdownChan
 	logging.GetSugaredLogger().Infof("Shutting down server peacefully")
-	err = server.Stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.NatsServer.ShutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	natsConn.Close()
 	if err != nil {
-		log.Fatal("fail to stop server")
+		logging.GetSugaredLogger().Errorf("fail to stop server: %v", err)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	di "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/dependency-injection"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/repo/postgres_gorm"
 	_ "github.com/lib/pq"
 )
@@ -12,6 +13,8 @@ type OrderDatabase struct {
 	Conn *postgres_gorm.PostgresGormConnection
 }
 
+var _ = di.Make[*OrderDatabase](NewOrderDatabase)
+
 func NewOrderDatabase() *OrderDatabase {
 	config, err := configs.Load()
 	if err != nil {
@@ -28,3 +31,7 @@ func NewOrderDatabase() *OrderDatabase {
 		Conn: conn,
 	}
 }
+
+func (o *OrderDatabase) Close() error {
+	return o.Conn.Close()
+}
@@ -11,10 +11,10 @@ import (
 	order_service "github.com/hoangdaochuz/ecommerce-microservice-golang/apps/order/services/order"
 	di "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/dependency-injection"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
 )
 
 type OrderServiceApp struct {
-	order.UnimplementedOrderServiceServer
 	service order_service.OrderServiceInterface
 	// order_service_layer here
 	// other service
@@ -31,11 +31,6 @@ var _ = di.Make[*OrderServiceApp](NewOrderServiceApp)
 func (o *OrderServiceApp) CreateOrder(ctx context.Context, req *order.CreateOrderRequest) (*order.CreateOrderResponse, error) {
 
 	customerId := req.GetCustomerId()
-
-	if customerId == "" {
-		return nil, fmt.Errorf("customer_id is required")
-	}
-
 	orderId := fmt.Sprintf("order_%s_%d", customerId, time.Now().Unix())
 
 	logging.GetSugaredLogger().Infof("Order created successfully for customer: %s, order_id: %s", customerId, orderId)
@@ -53,14 +48,20 @@ func (o *OrderServiceApp) toOrder(item order_repository.Order) *order.OrderRespo
 }
 
 func (o *OrderServiceApp) GetOrderById(ctx context.Context, req *order.GetOrderByIdRequest) (*order.OrderResponse, error) {
+	// req.Id is checked by the generated validator, parse again to stay safe
+	// when the handler is called directly
+	orderId, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, shared.NewBadRequestError("id must be a valid UUID").WithCause(err)
+	}
 	order, err := o.service.GetOrderById(ctx, &order_service.GetOrderByIdRequest{
-		Id: uuid.MustParse(req.Id),
+		Id: orderId,
 	})
 	if err != nil {
-		return nil, err
+		return nil, shared.NewInternalServerError("fail to get order").WithCause(err)
 	}
 	if order == nil {
-		return nil, nil
+		return nil, shared.NewNotFoundError(fmt.Sprintf("order %s not found", req.Id))
 	}
 	return o.toOrder(*order), nil
 }
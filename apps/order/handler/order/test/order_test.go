@@ -2,6 +2,7 @@ package order_test
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/google/uuid"
@@ -10,6 +11,7 @@ import (
 	order_repository "github.com/hoangdaochuz/ecommerce-microservice-golang/apps/order/repository"
 	order_service "github.com/hoangdaochuz/ecommerce-microservice-golang/apps/order/services/order"
 	orderServiceMock "github.com/hoangdaochuz/ecommerce-microservice-golang/mocks/order_service"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -35,4 +37,20 @@ func Test_OrderEndpointHandler(t *testing.T) {
 		require.Equal(t, orderId, res.Id)
 		require.Equal(t, orderExpect.Name, res.Name)
 	})
+
+	t.Run("Test_GetOrderById_Unknown_Id", func(t *testing.T) {
+		orderId := "9f2c7a3e-6b8d-4e5f-9a1c-3d4f8b2e6a91"
+		orderServiceMock.EXPECT().GetOrderById(mock.Anything, mock.MatchedBy(func(req *order_service.GetOrderByIdRequest) bool {
+			return req.Id == uuid.MustParse(orderId)
+		})).Return(nil, nil).Once()
+
+		orderApp := order.NewOrderServiceApp(orderServiceMock)
+		res, err := orderApp.GetOrderById(context.Background(), &order_api.GetOrderByIdRequest{
+			Id: orderId,
+		})
+		require.Nil(t, res)
+		var appErr *shared.AppError
+		require.ErrorAs(t, err, &appErr)
+		require.Equal(t, http.StatusNotFound, appErr.StatusCode)
+	})
 }
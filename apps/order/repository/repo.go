@@ -21,9 +21,7 @@ type OrderRepository struct {
 
 var OrderRepositoryMod = di.Make[OrderRepositoryInterface](NewOrderRepository)
 
-func NewOrderRepository() OrderRepositoryInterface {
-	orderDb := order_configs.NewOrderDatabase()
-
+func NewOrderRepository(orderDb *order_configs.OrderDatabase) OrderRepositoryInterface {
 	dbClient := postgres_gorm.NewPostgresGormClient(orderDb.Conn)
 	return &OrderRepository{
 		repo: repo_pkg.NewRepo[*Order](dbClient),
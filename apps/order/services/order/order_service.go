@@ -2,11 +2,12 @@ package order_service
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 
 	"github.com/google/uuid"
 	order_repository "github.com/hoangdaochuz/ecommerce-microservice-golang/apps/order/repository"
 	di "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/dependency-injection"
+	"gorm.io/gorm"
 )
 
 type OrderServiceInterface interface {
@@ -32,7 +33,8 @@ type GetOrderByIdRequest struct {
 func (o *OrderService) GetOrderById(ctx context.Context, req *GetOrderByIdRequest) (*order_repository.Order, error) {
 	entity, err := o.OrderRepo.FindOrderById(ctx, req.Id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		// gorm's First answers a missing row with ErrRecordNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
 		return nil, err
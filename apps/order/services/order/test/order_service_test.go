@@ -2,7 +2,6 @@ package order_service_test
 
 import (
 	"context"
-	"database/sql"
 	"testing"
 
 	"github.com/google/uuid"
@@ -11,6 +10,7 @@ import (
 	orderRepoMock "github.com/hoangdaochuz/ecommerce-microservice-golang/mocks/order_repository"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func Test_OrderService(t *testing.T) {
@@ -35,7 +35,7 @@ func Test_OrderService(t *testing.T) {
 
 	t.Run("Test_GetOrderById_NotFound", func(t *testing.T) {
 		orderId := uuid.MustParse("9f2c7a3e-6b8d-4e5f-9a1c-3d4f8b2e6a91")
-		orderRepo.EXPECT().FindOrderById(mock.Anything, orderId).Return(nil, sql.ErrNoRows)
+		orderRepo.EXPECT().FindOrderById(mock.Anything, orderId).Return(nil, gorm.ErrRecordNotFound)
 
 		orderService := order_service.NewOrderService(orderRepo)
 		res, err := orderService.GetOrderById(context.Background(), &order_service.GetOrderByIdRequest{
@@ -37,6 +37,14 @@ type NATSAuth struct {
 	IssuerPrivate      string    `mapstructure:"issuer_private"`
 }
 
+type NatsServer struct {
+	MaxInFlight     int           `mapstructure:"max_in_flight"`
+	MaxPending      int           `mapstructure:"max_pending"`
+	HealthAddr      string        `mapstructure:"health_addr"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	StreamChunkSize int           `mapstructure:"stream_chunk_size"`
+}
+
 type Redis struct {
 	Address string `mapstructure:"address"`
 	Port    string `mapstructure:"port"`
@@ -100,23 +108,336 @@ type CircuitBreaker struct {
 	ExternalAPIs CircuitBreakerExternalAPIs `mapstructure:"external_apis"`
 }
 
+// RequestPolicy tells the gateway how to send a request to a service. A zero
+// duration or a nil count or flag inherits the value of the less specific
+// policy.
+type RequestPolicy struct {
+	// Timeout bounds the whole request, retries and hedges included.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Idempotent marks requests the service handles the same when received
+	// twice; only those are retried or hedged.
+	Idempotent *bool `mapstructure:"idempotent"`
+	// Retries is how many times an idempotent request is sent again after
+	// the service was unavailable.
+	Retries    *int          `mapstructure:"retries"`
+	Backoff    time.Duration `mapstructure:"backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// An idempotent request still unanswered after HedgeDelay is sent once
+	// more, up to MaxHedges times, and the first reply wins.
+	HedgeDelay time.Duration `mapstructure:"hedge_delay"`
+	MaxHedges  *int          `mapstructure:"max_hedges"`
+}
+
+// RoutePolicy matches Path exactly, or every path under it when Path ends
+// with "/*".
+type RoutePolicy struct {
+	Path          string `mapstructure:"path"`
+	RequestPolicy `mapstructure:",squash"`
+}
+
+// RequestPolicies is the policy table of the gateway. A route policy wins
+// over the policy of its service, which wins over the defaults.
+type RequestPolicies struct {
+	Defaults RequestPolicy            `mapstructure:"defaults"`
+	Services map[string]RequestPolicy `mapstructure:"services"`
+	Routes   []RoutePolicy            `mapstructure:"routes"`
+}
+
+func (p RequestPolicy) IsIdempotent() bool {
+	return p.Idempotent != nil && *p.Idempotent
+}
+
+func (p RequestPolicy) GetRetries() int {
+	if p.Retries == nil {
+		return 0
+	}
+	return *p.Retries
+}
+
+func (p RequestPolicy) GetMaxHedges() int {
+	if p.MaxHedges == nil {
+		return 0
+	}
+	return *p.MaxHedges
+}
+
+// merge returns p with every field set in override replaced.
+func (p RequestPolicy) merge(override RequestPolicy) RequestPolicy {
+	if override.Timeout > 0 {
+		p.Timeout = override.Timeout
+	}
+	if override.Idempotent != nil {
+		p.Idempotent = override.Idempotent
+	}
+	if override.Retries != nil {
+		p.Retries = override.Retries
+	}
+	if override.Backoff > 0 {
+		p.Backoff = override.Backoff
+	}
+	if override.MaxBackoff > 0 {
+		p.MaxBackoff = override.MaxBackoff
+	}
+	if override.HedgeDelay > 0 {
+		p.HedgeDelay = override.HedgeDelay
+	}
+	if override.MaxHedges != nil {
+		p.MaxHedges = override.MaxHedges
+	}
+	return p
+}
+
 type Config struct {
 	ServiceRegistry ServiceRegistryConfig `mapstructure:"service_registry"`
 	Apigateway      ApigatewayConfig      `mapstructure:"apigateway"`
 	OrderDatabase   DatabaseConfig        `mapstructure:"order_database"`
 	MongoDatabase   DatabaseConfig        `mapstructure:"mongo_db"`
 	NatsAuth        NATSAuth              `mapstructure:"nats_auth"`
+	NatsServer      NatsServer            `mapstructure:"nats_server"`
 	Redis           Redis                 `mapstructure:"redis"`
 	ZitadelConfigs  ZitadelConfigs        `mapstructure:"zitadel_configs"`
 	AuthToken       AuthToken             `mapstructure:"auth_token"`
 	GeneralConfig   GeneralConfig         `mapstructure:"general_config"`
 	CircuitBreaker  CircuitBreaker        `mapstructure:"circuit_breaker"`
+	RequestPolicies RequestPolicies       `mapstructure:"request_policies"`
+	Identity        IdentityConfig        `mapstructure:"identity"`
 	// Database --> Later
 	// Log --> Later
 }
 
 type ApigatewayConfig struct {
-	Port string `mapstructure:"port"`
+	Port     string         `mapstructure:"port"`
+	Envelope EnvelopeConfig `mapstructure:"envelope"`
+	Routes   []RouteConfig  `mapstructure:"routes"`
+	Auth     AuthConfig     `mapstructure:"auth"`
+	// Authorization rules are tried in order and the first matching one
+	// applies. A request no rule matches only needs to be authenticated.
+	Authorization []AuthorizationRule `mapstructure:"authorization"`
+	APIKeys       APIKeysConfig       `mapstructure:"api_keys"`
+	ResponseCache ResponseCacheConfig `mapstructure:"response_cache"`
+	Compression   CompressionConfig   `mapstructure:"compression"`
+	// MaxBodySize bounds, in bytes, the request body of a route that sets no
+	// max_body_size.
+	MaxBodySize int64        `mapstructure:"max_body_size"`
+	Events      EventsConfig `mapstructure:"events"`
+	Docs        DocsConfig   `mapstructure:"docs"`
+	// RateLimit limits requests by route, caller and plan.
+	RateLimit   RateLimitConfig   `mapstructure:"rate_limit"`
+	Concurrency ConcurrencyConfig `mapstructure:"concurrency"`
+}
+
+// RateLimitConfig holds the rate limit policies of the gateway. The first
+// policy matching a request applies; a request none matches is not limited.
+type RateLimitConfig struct {
+	// Algorithm counts the limits that set none: sliding_log, sliding_window
+	// or token_bucket.
+	Algorithm string `mapstructure:"algorithm"`
+	// TrustedProxies are the addresses or CIDR ranges of the proxies in front
+	// of the gateway. X-Forwarded-For is only believed when sent by them.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// PlanRolePrefix marks the role naming the plan of a caller, e.g. plan:pro.
+	PlanRolePrefix string `mapstructure:"plan_role_prefix"`
+	// DefaultPlan is the plan of callers without a plan role, anonymous ones
+	// included.
+	DefaultPlan string                     `mapstructure:"default_plan"`
+	Plans       map[string][]RateLimitRule `mapstructure:"plans"`
+	Policies    []RateLimitPolicy          `mapstructure:"policies"`
+	Fallback    RateLimitFallbackConfig    `mapstructure:"fallback"`
+	// PreAuth limits every client address before its credentials are
+	// checked, which the policies keyed by caller cannot.
+	PreAuth []RateLimitRule `mapstructure:"pre_auth"`
+}
+
+// ConcurrencyConfig bounds the requests in flight to each service. The limit
+// of a service adapts to its latency: it grows by one per limit requests
+// answered within LatencyThreshold and is multiplied by Backoff on a slower
+// reply or a failure (AIMD).
+type ConcurrencyConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	InitialLimit     int           `mapstructure:"initial_limit"`
+	MinLimit         int           `mapstructure:"min_limit"`
+	MaxLimit         int           `mapstructure:"max_limit"`
+	LatencyThreshold time.Duration `mapstructure:"latency_threshold"`
+	Backoff          float64       `mapstructure:"backoff"`
+	// RetryAfter is sent to the clients of a shed request.
+	RetryAfter time.Duration `mapstructure:"retry_after"`
+	// Priorities is the share of the limit the requests of each route
+	// priority may fill, so lower priorities are shed first.
+	Priorities map[string]float64 `mapstructure:"priorities"`
+	// Services get a limit each, every other service shares one.
+	Services []string `mapstructure:"services"`
+}
+
+// RateLimitFallbackConfig decides requests while redis cannot count them.
+type RateLimitFallbackConfig struct {
+	// Mode is local to count in the memory of each instance, open to allow
+	// every request or closed to answer 503.
+	Mode string `mapstructure:"mode"`
+	// Instances is the number of gateway instances. Counting locally, each
+	// one allows its share of a limit.
+	Instances int `mapstructure:"instances"`
+}
+
+// RateLimitRule allows Limit requests per Window.
+type RateLimitRule struct {
+	Limit  int           `mapstructure:"limit"`
+	Window time.Duration `mapstructure:"window"`
+	// Algorithm overrides the one of RateLimitConfig.
+	Algorithm string `mapstructure:"algorithm"`
+}
+
+// RateLimitPolicy limits the requests matching Method and Path, a pattern like
+// the one of RouteConfig or a prefix ending with /*.
+type RateLimitPolicy struct {
+	// Method is the HTTP method of the policy, any method when empty.
+	Method string `mapstructure:"method"`
+	Path   string `mapstructure:"path"`
+	// Key counts the requests per ip, user, api_key or tenant. A request
+	// without one is counted per ip.
+	Key string `mapstructure:"key"`
+	// Limits all apply together, e.g. per second and per day. When empty the
+	// limits of the plan of the caller apply.
+	Limits []RateLimitRule `mapstructure:"limits"`
+}
+
+// DocsConfig names the OpenAPI documents the gateway merges and serves.
+type DocsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+	Title   string `mapstructure:"title"`
+	// Specs are the documents generated from the proto files of the services.
+	Specs []string `mapstructure:"specs"`
+}
+
+// EventsConfig tunes the WebSocket and SSE connections that forward user
+// events to clients.
+type EventsConfig struct {
+	Path string `mapstructure:"path"`
+	// Stream is the JetStream stream keeping the events for Retention, so a
+	// client reconnecting with the id of the last event it got misses none.
+	Stream    string        `mapstructure:"stream"`
+	Retention time.Duration `mapstructure:"retention"`
+	// HeartbeatInterval is how often an idle connection gets a heartbeat.
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+	// ReconnectDelay is the wait an SSE client is told to keep before it
+	// reconnects.
+	ReconnectDelay        time.Duration `mapstructure:"reconnect_delay"`
+	MaxConnectionsPerUser int           `mapstructure:"max_connections_per_user"`
+}
+
+// CompressionConfig tunes the compression of gateway responses.
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Encodings are the codings offered to clients, gzip and zstd, the
+	// preferred first.
+	Encodings []string `mapstructure:"encodings"`
+	// MinSize is the smallest body, in bytes, worth compressing.
+	MinSize int `mapstructure:"min_size"`
+	// ContentTypes are the media types compressed; one ending in / covers the
+	// whole type, e.g. text/.
+	ContentTypes []string `mapstructure:"content_types"`
+	// MaxDecompressedSize bounds a gzip request body once decompressed.
+	MaxDecompressedSize int64 `mapstructure:"max_decompressed_size"`
+}
+
+// APIKeysConfig tunes the api keys issued to machine clients.
+type APIKeysConfig struct {
+	// DefaultRateLimit is the number of requests per RateLimitWindow of a key
+	// that sets no limit.
+	DefaultRateLimit int           `mapstructure:"default_rate_limit"`
+	RateLimitWindow  time.Duration `mapstructure:"rate_limit_window"`
+	// RateLimitAlgorithm counts the requests of a key, see RateLimitConfig.
+	RateLimitAlgorithm string `mapstructure:"rate_limit_algorithm"`
+	// RotationGrace is how long the secret replaced by a rotation still works.
+	RotationGrace time.Duration `mapstructure:"rotation_grace"`
+	// AdminRole is the role needed to create, rotate and revoke keys.
+	AdminRole string `mapstructure:"admin_role"`
+}
+
+// AuthorizationRule limits who may call the routes matching Path, a pattern
+// like the one of RouteConfig.
+type AuthorizationRule struct {
+	// Method is the HTTP method of the rule, any method when empty.
+	Method string `mapstructure:"method"`
+	Path   string `mapstructure:"path"`
+	// Roles lets in a caller having any of them.
+	Roles []string `mapstructure:"roles"`
+	// Scopes lets in a caller having all of them.
+	Scopes []string `mapstructure:"scopes"`
+	// Owner names the check that the caller owns the resource of the request.
+	Owner string `mapstructure:"owner"`
+	// OwnerExemptRoles skip the owner check, e.g. for support staff.
+	OwnerExemptRoles []string `mapstructure:"owner_exempt_roles"`
+}
+
+// AuthConfig tunes how the gateway resolves sessions and bearer tokens.
+type AuthConfig struct {
+	// CacheTTL is how long a resolved identity is reused, 0 resolves every
+	// request again.
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+}
+
+// IdentityConfig signs the identity the gateway forwards to services. The
+// gateway and every service verifying the identity share the signing key.
+type IdentityConfig struct {
+	SigningKey string `mapstructure:"signing_key"`
+	// TTL is how long a signed identity is accepted after it was issued.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// RouteConfig maps requests to a service. Path is a pattern where a segment
+// such as {id} matches any one segment; Service, Subject and Rewrite may use
+// the parameters of Path. When the path is rewritten, the parameters Rewrite
+// and Subject do not use become fields of the request message.
+type RouteConfig struct {
+	// Method is the HTTP method of the route, any method when empty.
+	Method string `mapstructure:"method"`
+	Path   string `mapstructure:"path"`
+	// Service names the service for circuit breakers and request policies.
+	Service string `mapstructure:"service"`
+	// Subject is the nats subject of the service, /api/v1/<service> when empty.
+	Subject string `mapstructure:"subject"`
+	// Rewrite is the path sent to the service, the request path when empty.
+	Rewrite string `mapstructure:"rewrite"`
+	// Auth requires a session, true when not set.
+	Auth *bool `mapstructure:"auth"`
+	// Cache keeps the responses of GET requests, off when not set.
+	Cache *RouteCacheConfig `mapstructure:"cache"`
+	// MaxBodySize bounds the request body in bytes, apigateway.max_body_size
+	// when not set.
+	MaxBodySize int64 `mapstructure:"max_body_size"`
+	// Priority is critical, normal or low, normal when not set. Under load
+	// the requests of lower priorities are shed first.
+	Priority string `mapstructure:"priority"`
+	// Stream opts the route into streamed responses: its requests get a reply
+	// inbox of their own instead of the shared one of unary requests.
+	Stream bool `mapstructure:"stream"`
+}
+
+// RouteCacheConfig opts a route into the response cache of the gateway.
+type RouteCacheConfig struct {
+	// TTL is how long a response is kept when its Cache-Control sets no
+	// max-age.
+	TTL time.Duration `mapstructure:"ttl"`
+	// VaryHeaders are the request headers that are part of the cache key.
+	VaryHeaders []string `mapstructure:"vary_headers"`
+	// Tags name the cached responses for invalidation and may use the
+	// parameters of the route path, e.g. order:{id}.
+	Tags []string `mapstructure:"tags"`
+}
+
+// ResponseCacheConfig picks where the gateway keeps cached responses.
+type ResponseCacheConfig struct {
+	// Backend is redis, shared by the gateway instances, or local.
+	Backend string `mapstructure:"backend"`
+}
+
+// EnvelopeConfig picks the encoding of the nats envelope the gateway sends.
+// Services accept every encoding, so a service is switched here only once all
+// of its instances run a version that understands it.
+type EnvelopeConfig struct {
+	Encoding string            `mapstructure:"encoding"`
+	Services map[string]string `mapstructure:"services"`
 }
 
 func setDefaults() {
@@ -135,6 +456,76 @@ func setDefaults() {
 	viper.SetDefault("nats_auth.nats_apps.2.password", "auth")
 	viper.SetDefault("nats_auth.nats_apps.2.account", "AUTH")
 
+	viper.SetDefault("apigateway.envelope.encoding", "json")
+	viper.SetDefault("apigateway.routes", []map[string]any{
+		{"method": "GET", "path": "/callback", "service": "auth", "rewrite": "/api/v1/auth/Callback", "auth": false},
+		{"path": "/api/v1/auth/Login", "service": "auth", "auth": false},
+		{"path": "/api/v1/auth/Callback", "service": "auth", "auth": false},
+		{"path": "/api/v1/order/CreateOrder", "service": "order", "priority": "critical"},
+		{"path": "/api/{version}/{service}/{method}", "service": "{service}", "subject": "/api/{version}/{service}"},
+	})
+
+	viper.SetDefault("apigateway.auth.cache_ttl", 30*time.Second)
+	viper.SetDefault("apigateway.api_keys.default_rate_limit", 600)
+	viper.SetDefault("apigateway.api_keys.rate_limit_window", time.Minute)
+	viper.SetDefault("apigateway.api_keys.rate_limit_algorithm", "token_bucket")
+	viper.SetDefault("apigateway.api_keys.rotation_grace", 24*time.Hour)
+	viper.SetDefault("apigateway.api_keys.admin_role", "admin")
+	viper.SetDefault("apigateway.response_cache.backend", "redis")
+	viper.SetDefault("apigateway.max_body_size", 1<<20)
+	viper.SetDefault("apigateway.events.path", "/events")
+	viper.SetDefault("apigateway.events.stream", "USER_EVENTS")
+	viper.SetDefault("apigateway.events.retention", time.Hour)
+	viper.SetDefault("apigateway.events.heartbeat_interval", 15*time.Second)
+	viper.SetDefault("apigateway.events.reconnect_delay", 3*time.Second)
+	viper.SetDefault("apigateway.events.max_connections_per_user", 5)
+	viper.SetDefault("apigateway.rate_limit.algorithm", "sliding_window")
+	viper.SetDefault("apigateway.rate_limit.trusted_proxies", []string{})
+	viper.SetDefault("apigateway.rate_limit.plan_role_prefix", "plan:")
+	viper.SetDefault("apigateway.rate_limit.default_plan", "free")
+	viper.SetDefault("apigateway.rate_limit.plans", map[string]any{
+		"free": []map[string]any{{"limit": 50, "window": "1m"}, {"limit": 10000, "window": "24h"}},
+		"pro":  []map[string]any{{"limit": 20, "window": "1s"}, {"limit": 1000000, "window": "24h"}},
+	})
+	viper.SetDefault("apigateway.concurrency.enabled", true)
+	viper.SetDefault("apigateway.concurrency.initial_limit", 20)
+	viper.SetDefault("apigateway.concurrency.min_limit", 5)
+	viper.SetDefault("apigateway.concurrency.max_limit", 500)
+	viper.SetDefault("apigateway.concurrency.latency_threshold", time.Second)
+	viper.SetDefault("apigateway.concurrency.backoff", 0.9)
+	viper.SetDefault("apigateway.concurrency.retry_after", time.Second)
+	viper.SetDefault("apigateway.concurrency.priorities", map[string]any{"critical": 1, "normal": 0.8, "low": 0.5})
+	viper.SetDefault("apigateway.concurrency.services", []string{"auth", "order"})
+	viper.SetDefault("apigateway.rate_limit.pre_auth", []map[string]any{{"limit": 300, "window": "1m"}})
+	viper.SetDefault("apigateway.rate_limit.fallback.mode", "local")
+	viper.SetDefault("apigateway.rate_limit.fallback.instances", 1)
+	viper.SetDefault("apigateway.rate_limit.policies", []map[string]any{
+		{"path": "/api/v1/auth/Login", "key": "ip", "limits": []map[string]any{{"limit": 10, "window": "1m"}}},
+		{"path": "/*", "key": "user"},
+	})
+	viper.SetDefault("apigateway.docs.enabled", true)
+	viper.SetDefault("apigateway.docs.path", "/docs")
+	viper.SetDefault("apigateway.docs.title", "Ecommerce API")
+	viper.SetDefault("apigateway.docs.specs", []string{"apps/auth/api/auth/auth.openapi.json", "apps/order/api/order/order.openapi.json"})
+	viper.SetDefault("apigateway.compression.enabled", true)
+	viper.SetDefault("apigateway.compression.encodings", []string{"zstd", "gzip"})
+	viper.SetDefault("apigateway.compression.min_size", 1024)
+	viper.SetDefault("apigateway.compression.content_types", []string{"application/json", "application/javascript", "application/xml", "image/svg+xml", "text/"})
+	viper.SetDefault("apigateway.compression.max_decompressed_size", 10<<20)
+	viper.SetDefault("identity.signing_key", "YOUR_IDENTITY_SIGNING_KEY")
+	viper.SetDefault("identity.ttl", time.Minute)
+
+	viper.SetDefault("request_policies.defaults.idempotent", false)
+	viper.SetDefault("request_policies.defaults.retries", 0)
+	viper.SetDefault("request_policies.defaults.backoff", 50*time.Millisecond)
+	viper.SetDefault("request_policies.defaults.max_backoff", time.Second)
+	viper.SetDefault("request_policies.defaults.max_hedges", 0)
+
+	viper.SetDefault("nats_server.max_in_flight", 64)
+	viper.SetDefault("nats_server.max_pending", 512)
+	viper.SetDefault("nats_server.shutdown_timeout", 30*time.Second)
+	viper.SetDefault("nats_server.stream_chunk_size", 64*1024)
+
 	// viper.SetDefault("service_registry.nats_user", "nats_user")
 	// viper.SetDefault("service_registry.nats_password", "nats_pass")
 	viper.SetDefault("apigateway.port", "8080")
@@ -240,6 +631,7 @@ func Load() (*Config, error) {
 	_ = viper.BindEnv("zitadel_configs.redirect_uri", "ZITADEL_CONFIGS_REDIRECT_URI")
 	_ = viper.BindEnv("zitadel_configs.api_key_base64", "ZITADEL_CONFIGS_API_KEY_BASE64")
 	_ = viper.BindEnv("zitadel_configs.encrypt_key", "ZITADEL_CONFIGS_ENCRYPT_KEY")
+	_ = viper.BindEnv("identity.signing_key", "IDENTITY_SIGNING_KEY")
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
@@ -267,6 +659,17 @@ func LoadDefaultCircuitBreakerConfig() *CircuitBreakerCommon {
 	}
 }
 
+func LoadDatabaseCircuitBreakerConfig() *CircuitBreakerCommon {
+	return &CircuitBreakerCommon{
+		MaxRequest:           viper.GetInt("circuit_breaker.databases.max_requests"),
+		Interval:             viper.GetInt("circuit_breaker.databases.interval"),
+		Timeout:              viper.GetInt("circuit_breaker.databases.timeout"),
+		FailureThreshold:     viper.GetInt("circuit_breaker.databases.failure_threshold"),
+		FailureRateThreshold: viper.GetFloat64("circuit_breaker.databases.failure_rate_threshold"),
+		MinRequests:          viper.GetInt("circuit_breaker.databases.min_requests"),
+	}
+}
+
 func LoadNatsCircuitBreakerConfigByServiceName(serviceName string) *CircuitBreakerCommon {
 	result := &CircuitBreakerCommon{
 		MaxRequest:           viper.GetInt(fmt.Sprintf("circuit_breaker.nats.services.%s.max_requests", serviceName)),
@@ -279,6 +682,184 @@ func LoadNatsCircuitBreakerConfigByServiceName(serviceName string) *CircuitBreak
 	return result
 }
 
+func LoadEnvelopeEncodingByServiceName(serviceName string) string {
+	if encoding := viper.GetString(fmt.Sprintf("apigateway.envelope.services.%s", serviceName)); encoding != "" {
+		return encoding
+	}
+	return viper.GetString("apigateway.envelope.encoding")
+}
+
+// LoadRequestPolicies reads and checks the policy table once, for the gateway
+// to look up on every request. The default timeout falls back to
+// service_registry.request_timeout.
+func LoadRequestPolicies() (RequestPolicies, error) {
+	idempotent := viper.GetBool("request_policies.defaults.idempotent")
+	retries := viper.GetInt("request_policies.defaults.retries")
+	maxHedges := viper.GetInt("request_policies.defaults.max_hedges")
+	policies := RequestPolicies{
+		Defaults: RequestPolicy{
+			Timeout:    viper.GetDuration("service_registry.request_timeout"),
+			Idempotent: &idempotent,
+			Retries:    &retries,
+			Backoff:    viper.GetDuration("request_policies.defaults.backoff"),
+			MaxBackoff: viper.GetDuration("request_policies.defaults.max_backoff"),
+			HedgeDelay: viper.GetDuration("request_policies.defaults.hedge_delay"),
+			MaxHedges:  &maxHedges,
+		},
+	}
+	if timeout := viper.GetDuration("request_policies.defaults.timeout"); timeout > 0 {
+		policies.Defaults.Timeout = timeout
+	}
+	if err := viper.UnmarshalKey("request_policies.services", &policies.Services); err != nil {
+		return policies, fmt.Errorf("failed to unmarshal request_policies services: %w", err)
+	}
+	if err := viper.UnmarshalKey("request_policies.routes", &policies.Routes); err != nil {
+		return policies, fmt.Errorf("failed to unmarshal request_policies routes: %w", err)
+	}
+
+	if err := policies.Defaults.validate(); err != nil {
+		return policies, fmt.Errorf("invalid default request policy: %w", err)
+	}
+	for name, policy := range policies.Services {
+		if err := policy.validate(); err != nil {
+			return policies, fmt.Errorf("invalid request policy of service %s: %w", name, err)
+		}
+	}
+	for i, route := range policies.Routes {
+		if route.Path == "" {
+			return policies, fmt.Errorf("request policy route %d has no path", i)
+		}
+		if err := route.validate(); err != nil {
+			return policies, fmt.Errorf("invalid request policy of route %s: %w", route.Path, err)
+		}
+	}
+	return policies, nil
+}
+
+// Lookup returns the policy of a request to path on serviceName.
+func (p RequestPolicies) Lookup(serviceName, path string) RequestPolicy {
+	policy := p.Defaults
+	if servicePolicy, ok := p.Services[serviceName]; ok {
+		policy = policy.merge(servicePolicy)
+	}
+	for _, route := range p.Routes {
+		if matchRoutePath(route.Path, path) {
+			policy = policy.merge(route.RequestPolicy)
+			break
+		}
+	}
+	return policy
+}
+
+func (p RequestPolicy) validate() error {
+	if p.Timeout < 0 || p.Backoff < 0 || p.MaxBackoff < 0 || p.HedgeDelay < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+	if p.GetRetries() < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	if p.GetMaxHedges() < 0 {
+		return fmt.Errorf("max_hedges must not be negative")
+	}
+	return nil
+}
+
+func matchRoutePath(pattern, path string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(path, prefix+"/")
+	}
+	return pattern == path
+}
+
+func LoadAuthorizationRules() ([]AuthorizationRule, error) {
+	var rules []AuthorizationRule
+	if err := viper.UnmarshalKey("apigateway.authorization", &rules); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal apigateway authorization: %w", err)
+	}
+	return rules, nil
+}
+
+func LoadAPIKeysConfig() APIKeysConfig {
+	return APIKeysConfig{
+		DefaultRateLimit:   viper.GetInt("apigateway.api_keys.default_rate_limit"),
+		RateLimitWindow:    viper.GetDuration("apigateway.api_keys.rate_limit_window"),
+		RateLimitAlgorithm: viper.GetString("apigateway.api_keys.rate_limit_algorithm"),
+		RotationGrace:      viper.GetDuration("apigateway.api_keys.rotation_grace"),
+		AdminRole:          viper.GetString("apigateway.api_keys.admin_role"),
+	}
+}
+
+func LoadRateLimitConfig() (RateLimitConfig, error) {
+	var config RateLimitConfig
+	if err := viper.UnmarshalKey("apigateway.rate_limit", &config); err != nil {
+		return config, fmt.Errorf("failed to unmarshal apigateway rate_limit: %w", err)
+	}
+	return config, nil
+}
+
+func LoadConcurrencyConfig() (ConcurrencyConfig, error) {
+	var config ConcurrencyConfig
+	if err := viper.UnmarshalKey("apigateway.concurrency", &config); err != nil {
+		return config, fmt.Errorf("failed to unmarshal apigateway concurrency: %w", err)
+	}
+	return config, nil
+}
+
+func LoadResponseCacheConfig() ResponseCacheConfig {
+	return ResponseCacheConfig{
+		Backend: viper.GetString("apigateway.response_cache.backend"),
+	}
+}
+
+func LoadMaxBodySize() int64 {
+	return viper.GetInt64("apigateway.max_body_size")
+}
+
+func LoadEventsConfig() EventsConfig {
+	return EventsConfig{
+		Path:                  viper.GetString("apigateway.events.path"),
+		Stream:                viper.GetString("apigateway.events.stream"),
+		Retention:             viper.GetDuration("apigateway.events.retention"),
+		HeartbeatInterval:     viper.GetDuration("apigateway.events.heartbeat_interval"),
+		ReconnectDelay:        viper.GetDuration("apigateway.events.reconnect_delay"),
+		MaxConnectionsPerUser: viper.GetInt("apigateway.events.max_connections_per_user"),
+	}
+}
+
+func LoadDocsConfig() DocsConfig {
+	return DocsConfig{
+		Enabled: viper.GetBool("apigateway.docs.enabled"),
+		Path:    viper.GetString("apigateway.docs.path"),
+		Title:   viper.GetString("apigateway.docs.title"),
+		Specs:   viper.GetStringSlice("apigateway.docs.specs"),
+	}
+}
+
+func LoadCompressionConfig() CompressionConfig {
+	return CompressionConfig{
+		Enabled:             viper.GetBool("apigateway.compression.enabled"),
+		Encodings:           viper.GetStringSlice("apigateway.compression.encodings"),
+		MinSize:             viper.GetInt("apigateway.compression.min_size"),
+		ContentTypes:        viper.GetStringSlice("apigateway.compression.content_types"),
+		MaxDecompressedSize: viper.GetInt64("apigateway.compression.max_decompressed_size"),
+	}
+}
+
+func LoadIdentityConfig() IdentityConfig {
+	return IdentityConfig{
+		SigningKey: viper.GetString("identity.signing_key"),
+		TTL:        viper.GetDuration("identity.ttl"),
+	}
+}
+
+func LoadRoutes() ([]RouteConfig, error) {
+	var routes []RouteConfig
+	if err := viper.UnmarshalKey("apigateway.routes", &routes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal apigateway routes: %w", err)
+	}
+	return routes, nil
+}
+
 func LoadExternalApiCircuitBreakerConfigByApiProviderName(provider string) *CircuitBreakerCommon {
 	return &CircuitBreakerCommon{
 		MaxRequest:           viper.GetInt(fmt.Sprintf("circuit_breaker.external_apis.%s.max_requests", provider)),
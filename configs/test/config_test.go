@@ -0,0 +1,83 @@
+package configs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadRequestPolicies(t *testing.T) {
+	viper.Set("request_policies.services", map[string]any{
+		"order": map[string]any{"timeout": "10s", "idempotent": true, "retries": 2},
+	})
+	viper.Set("request_policies.routes", []map[string]any{
+		{"path": "/api/v1/order/CreateOrder", "timeout": "5s", "idempotent": false, "retries": 0},
+		{"path": "/api/v1/order/reports/*", "timeout": "2m", "hedge_delay": "100ms", "max_hedges": 1},
+	})
+	t.Cleanup(func() {
+		viper.Set("request_policies.services", map[string]any{})
+		viper.Set("request_policies.routes", []map[string]any{})
+	})
+
+	policies, err := configs.LoadRequestPolicies()
+	require.NoError(t, err)
+
+	t.Run("Test_Route_Wins_Over_Service", func(t *testing.T) {
+		policy := policies.Lookup("order", "/api/v1/order/CreateOrder")
+		require.Equal(t, 5*time.Second, policy.Timeout)
+		require.False(t, policy.IsIdempotent())
+		require.Equal(t, 0, policy.GetRetries())
+
+		policy = policies.Lookup("order", "/api/v1/order/reports/Monthly")
+		require.Equal(t, 2*time.Minute, policy.Timeout)
+		require.True(t, policy.IsIdempotent())
+		require.Equal(t, 2, policy.GetRetries())
+		require.Equal(t, 100*time.Millisecond, policy.HedgeDelay)
+		require.Equal(t, 1, policy.GetMaxHedges())
+	})
+
+	t.Run("Test_Service_Policy", func(t *testing.T) {
+		policy := policies.Lookup("order", "/api/v1/order/reports")
+		require.Equal(t, 10*time.Second, policy.Timeout)
+		require.Equal(t, 2, policy.GetRetries())
+		require.Equal(t, 50*time.Millisecond, policy.Backoff)
+	})
+
+	t.Run("Test_Fallback_To_Defaults", func(t *testing.T) {
+		policy := policies.Lookup("auth", "/api/v1/auth/Login")
+		require.Equal(t, 30*time.Second, policy.Timeout)
+		require.False(t, policy.IsIdempotent())
+		require.Equal(t, 0, policy.GetRetries())
+		require.Equal(t, time.Second, policy.MaxBackoff)
+	})
+
+	t.Run("Test_Invalid_Policy", func(t *testing.T) {
+		viper.Set("request_policies.routes", []map[string]any{{"path": "/api/v1/order/*", "retries": -1}})
+		_, err := configs.LoadRequestPolicies()
+		require.ErrorContains(t, err, "retries must not be negative")
+	})
+}
+
+func Test_LoadRateLimitConfig(t *testing.T) {
+	viper.Set("apigateway.rate_limit.plans", map[string]any{
+		"pro": []map[string]any{{"limit": 20, "window": "1s"}, {"limit": 1000, "window": "24h", "algorithm": "token_bucket"}},
+	})
+	viper.Set("apigateway.rate_limit.policies", []map[string]any{
+		{"method": "POST", "path": "/api/v1/order/*", "key": "tenant"},
+	})
+	t.Cleanup(func() {
+		viper.Set("apigateway.rate_limit.plans", map[string]any{})
+		viper.Set("apigateway.rate_limit.policies", []map[string]any{})
+	})
+
+	config, err := configs.LoadRateLimitConfig()
+	require.NoError(t, err)
+	require.Equal(t, []configs.RateLimitRule{
+		{Limit: 20, Window: time.Second},
+		{Limit: 1000, Window: 24 * time.Hour, Algorithm: "token_bucket"},
+	}, config.Plans["pro"])
+	require.Equal(t, []configs.RateLimitPolicy{{Method: "POST", Path: "/api/v1/order/*", Key: "tenant"}}, config.Policies)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/codegen/codegen-frontend"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/codegen/proto2dgo"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/codegen/proto2openapi"
 )
 
 // func getServiceRoutesFromGateway(serviceRoutes *map[string]apigateway.ServiceRoute) error {
@@ -25,6 +26,11 @@ func handleGenGoContractFile(protoFile, outputFile string) error {
 	return generater.GenerateProto2Dgo(protoFile, outputFile)
 }
 
+func handleGenOpenAPIFile(protoFile, outputFile string) error {
+	generater := proto2openapi.NewProto2OpenAPIGenerater()
+	return generater.GenerateOpenAPI(protoFile, outputFile)
+}
+
 func handleGenFECode(ourDir, baseURL, serviceName string) error {
 	frontendCodeGenerator := codegen.NewFrontendGenerator(ourDir, baseURL, serviceName)
 	return frontendCodeGenerator.GenerateAllFECode()
@@ -37,8 +43,9 @@ func main() {
 		// baseURL       = flag.String("baseurl", "http://localhost:8080", "Base URL for API endpoints")
 		// serviceName   = flag.String("service", "", "service name for generated code, if this field has not been set, it will generated frontend code for all service")
 		help          = flag.Bool("help", false, "Show help message")
-		genType       = flag.String("type", "", "Code gen type (ex. backend-contract, fronend)")
+		genType       = flag.String("type", "", "Code gen type (ex. backend-contract, openapi, fronend)")
 		dgoOutput     = flag.String("dgoOutput", "", "Generated file has name and place at")
+		openapiOutput = flag.String("openapiOutput", "", "Path of the generated OpenAPI document (JSON)")
 		protofilePath = flag.String("protofilePath", "", "Path to proto file need for generating contract go code")
 	)
 
@@ -46,7 +53,7 @@ func main() {
 	if *help {
 		fmt.Println("Go Contract Generator")
 		// fmt.Println("Useage: go run pkg/codegen/cli/main.go [options]")
-		fmt.Println("\nOptions:\n")
+		fmt.Println("\nOptions:")
 		flag.PrintDefaults()
 		os.Exit(0)
 	}
@@ -56,12 +63,19 @@ func main() {
 		if err != nil {
 			log.Fatal("failed while generating contract go code from proto file")
 		}
+		fmt.Println("🌈✅ Generated contract go code successfully")
+	} else if *genType == "openapi" {
+		err := handleGenOpenAPIFile(*protofilePath, *openapiOutput)
+		if err != nil {
+			log.Fatalf("failed while generating openapi document from proto file: %v", err)
+		}
+		fmt.Println("🌈✅ Generated openapi document successfully")
 	} else {
 		// Implement later
 		// err := handleGenFECode(*ourDir, *baseURL, *serviceName)
 		// if err != nil {
 		// 	log.Fatal("failed while generating fe code")
 		// }
+		fmt.Println("🌈✅ Generated frontend code successfully")
 	}
-	fmt.Println("🌈✅ Generated frontend code successfully")
 }
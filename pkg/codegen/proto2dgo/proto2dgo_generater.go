@@ -3,10 +3,10 @@ package proto2dgo
 import (
 	"embed"
 	"fmt"
-	"html/template"
 	"os"
 	"path/filepath"
 	"strings"
+	"text/template"
 )
 
 //go:embed templates/*.tmpl
@@ -21,6 +21,7 @@ type Proto2dgoTemplate struct {
 	NatsSubject string
 	ProtoModel  ProtoModel
 	GoPackage   string
+	Validators  []MessageValidatorModel
 }
 
 func NewProto2dgoGenerater() *Proto2dgoGenerater {
@@ -37,7 +38,10 @@ func (g *Proto2dgoGenerater) GenerateProto2Dgo(protoPath, outputPath string) err
 	if protoModel == nil {
 		return fmt.Errorf("model proto is nil")
 	}
-	template := g.prepareProto2dgoTemplate(protoModel)
+	template, err := g.prepareProto2dgoTemplate(protoModel)
+	if err != nil {
+		return err
+	}
 
 	return g.generateDgo(template, outputPath)
 }
@@ -63,7 +67,7 @@ func (g *Proto2dgoGenerater) generateDgo(data *Proto2dgoTemplate, outputPath str
 	return tmpl.Execute(file, data)
 }
 
-func (g *Proto2dgoGenerater) prepareProto2dgoTemplate(protoModel *ProtoModel) *Proto2dgoTemplate {
+func (g *Proto2dgoGenerater) prepareProto2dgoTemplate(protoModel *ProtoModel) (*Proto2dgoTemplate, error) {
 	importPath := []string{}
 	for _, path := range protoModel.ImportPaths {
 		importPath = append(importPath, path.Path)
@@ -71,11 +75,16 @@ func (g *Proto2dgoGenerater) prepareProto2dgoTemplate(protoModel *ProtoModel) *P
 	goPackage := protoModel.GoPackage
 	splits := strings.Split(goPackage, "/")
 
-	natsSubject := fmt.Sprintf("/api/v1/%s", splits[len(splits)-1])
+	natsSubject := protoModel.NatsSubject()
+	validators, err := buildMessageValidators(protoModel.Messages)
+	if err != nil {
+		return nil, fmt.Errorf("fail to build message validators: %w", err)
+	}
 	return &Proto2dgoTemplate{
 		NatsSubject: natsSubject,
 		ImportPath:  importPath,
 		ProtoModel:  *protoModel,
 		GoPackage:   splits[len(splits)-1],
-	}
+		Validators:  validators,
+	}, nil
 }
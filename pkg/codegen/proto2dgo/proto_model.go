@@ -1,5 +1,7 @@
 package proto2dgo
 
+import "strings"
+
 type ProtoModel struct {
 	Syntax       string
 	ProtoPackage string
@@ -10,6 +12,13 @@ type ProtoModel struct {
 	Enums        []EnumModel
 }
 
+// NatsSubject is the subject the service of the file listens on, which the
+// gateway derives from the path /api/v1/<service>/<method>.
+func (m *ProtoModel) NatsSubject() string {
+	splits := strings.Split(m.GoPackage, "/")
+	return "/api/v1/" + splits[len(splits)-1]
+}
+
 type ServiceModel struct {
 	Name    string
 	Methods []MethodModel
@@ -33,6 +42,8 @@ type FieldModel struct {
 	Name       string
 	Order      int
 	IsOptional bool
+	// Rules holds the validation rules from a trailing `// @validate` comment
+	Rules string
 }
 
 type EnumModel struct {
@@ -117,6 +117,12 @@ func (p *ProtoParser) ParseProtoFile(path string) (*ProtoModel, error) {
 			inMessage = true
 			messageName := p.extractMessageName(line)
 			messageItem.MessageName = messageName
+			if strings.Contains(line, "}") {
+				// an empty message closed on the same line, e.g. message Empty {}
+				inMessage = false
+				messageItem.Fields = []FieldModel{}
+				messageModels = append(messageModels, messageItem)
+			}
 			continue
 		}
 		if inMessage {
@@ -170,12 +176,21 @@ func (p *ProtoParser) getFieldOfMessage(line string) (*FieldModel, error) {
 			Type:       matches[2],
 			Name:       matches[3],
 			Order:      order,
+			Rules:      p.extractValidateRules(line),
 		}
 		return fieldInfo, nil
 	}
 	return nil, nil
 }
 
+// extractValidateRules reads rules written as a trailing comment on a field:
+//
+//	string customer_id = 1; // @validate required,max=64
+func (p *ProtoParser) extractValidateRules(line string) string {
+	regexPattern := `//\s*@validate\s+(\S+)`
+	return p.extractFirstSubstringCaptureMatch(line, regexPattern)
+}
+
 func (p *ProtoParser) extractMessageName(line string) string {
 	regex := `message\s+(\w+)\s*\{`
 	return p.extractFirstSubstringCaptureMatch(line, regex)
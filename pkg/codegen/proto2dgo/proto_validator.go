@@ -0,0 +1,134 @@
+package proto2dgo
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/validator"
+)
+
+// MessageValidatorModel holds the rendered rule checks of one message. Each
+// check is a Go expression returning *validator.FieldError.
+type MessageValidatorModel struct {
+	MessageName string
+	Checks      []string
+}
+
+var numericProtoTypes = map[string]bool{
+	"int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true, "float": true, "double": true,
+}
+
+// goFieldName mirrors the field naming of protoc-gen-go: the first letter is
+// upper cased and an underscore followed by a lower case letter is dropped in
+// favour of upper casing that letter.
+func goFieldName(protoName string) string {
+	var result strings.Builder
+	upperNext := true
+	for i, r := range protoName {
+		if r == '_' && i+1 < len(protoName) && unicode.IsLower(rune(protoName[i+1])) {
+			upperNext = true
+			continue
+		}
+		if upperNext {
+			r = unicode.ToUpper(r)
+			upperNext = false
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
+
+func buildMessageValidators(messages []MessageModel) ([]MessageValidatorModel, error) {
+	result := []MessageValidatorModel{}
+	for _, message := range messages {
+		checks := []string{}
+		for _, field := range message.Fields {
+			if field.Rules == "" {
+				continue
+			}
+			rules, err := validator.ParseRules(field.Rules)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", message.MessageName, field.Name, err)
+			}
+			for _, rule := range rules {
+				check, err := renderFieldCheck(field, rule)
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", message.MessageName, field.Name, err)
+				}
+				checks = append(checks, check)
+			}
+		}
+		if len(checks) > 0 {
+			result = append(result, MessageValidatorModel{
+				MessageName: message.MessageName,
+				Checks:      checks,
+			})
+		}
+	}
+	return result, nil
+}
+
+func renderFieldCheck(field FieldModel, rule validator.Rule) (string, error) {
+	goName := goFieldName(field.Name)
+	getter := fmt.Sprintf("x.Get%s()", goName)
+	isString := field.Type == "string"
+	isNumeric := numericProtoTypes[field.Type]
+
+	if field.IsRepeat {
+		switch rule.Name {
+		case "required":
+			return fmt.Sprintf("validator.MinItems(%q, len(%s), 1)", field.Name, getter), nil
+		case "min":
+			return fmt.Sprintf("validator.MinItems(%q, len(%s), %s)", field.Name, getter, rule.Arg), nil
+		case "max":
+			return fmt.Sprintf("validator.MaxItems(%q, len(%s), %s)", field.Name, getter, rule.Arg), nil
+		}
+		return "", fmt.Errorf("rule %q is not supported on repeated fields", rule.Name)
+	}
+
+	switch rule.Name {
+	case "required":
+		if field.IsOptional {
+			// optional scalars are pointers, so presence is a nil check
+			return fmt.Sprintf("validator.Required(%q, x.%s)", field.Name, goName), nil
+		}
+		return fmt.Sprintf("validator.Required(%q, %s)", field.Name, getter), nil
+	case "min", "max":
+		if isString {
+			return fmt.Sprintf("validator.%sLen(%q, %s, %s)", upperFirst(rule.Name), field.Name, getter, rule.Arg), nil
+		}
+		if isNumeric {
+			return fmt.Sprintf("validator.%s(%q, %s, %s)", upperFirst(rule.Name), field.Name, getter, rule.Arg), nil
+		}
+	case "uuid":
+		if isString {
+			return fmt.Sprintf("validator.UUID(%q, %s)", field.Name, getter), nil
+		}
+	case "email":
+		if isString {
+			return fmt.Sprintf("validator.Email(%q, %s)", field.Name, getter), nil
+		}
+	case "oneof":
+		if isString {
+			values := []string{}
+			for _, item := range strings.Split(rule.Arg, "|") {
+				values = append(values, fmt.Sprintf("%q", item))
+			}
+			return fmt.Sprintf("validator.OneOf(%q, %s, %s)", field.Name, getter, strings.Join(values, ", ")), nil
+		}
+		if isNumeric {
+			return fmt.Sprintf("validator.OneOf(%q, %s, %s)", field.Name, getter, strings.ReplaceAll(rule.Arg, "|", ", ")), nil
+		}
+	}
+	return "", fmt.Errorf("rule %q is not supported on %s fields", rule.Name, field.Type)
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
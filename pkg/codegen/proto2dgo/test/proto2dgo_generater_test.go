@@ -1,6 +1,7 @@
 package proto2dgo_test
 
 import (
+	"os"
 	"testing"
 
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/codegen/proto2dgo"
@@ -13,4 +14,11 @@ func TestGeneraterDGoFile(t *testing.T) {
 	generater := proto2dgo.NewProto2dgoGenerater()
 	err := generater.GenerateProto2Dgo(protoFilePath, output)
 	require.NoError(t, err)
+
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "func (x *CreateOrderRequest) Validate() error {")
+	require.Contains(t, string(content), `validator.MaxLen("customer_id", x.GetCustomerId(), 64)`)
+	require.Contains(t, string(content), "func NewOrderServiceClient(natsConn *nats.Conn, opts ...custom_nats.ServiceClientOption) *OrderServiceClient {")
+	require.Contains(t, string(content), "return custom_nats.Call[CreateOrderRequest, CreateOrderResponse](ctx, c.client, ORDER_CREATE_ORDER, req)")
 }
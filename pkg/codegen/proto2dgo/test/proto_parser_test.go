@@ -65,6 +65,10 @@ func TestGetHeaderOfProto(t *testing.T) {
 
 		require.Equal(t, protoModel.Messages[4].Fields[0].IsRepeat, true)
 
+		require.Equal(t, protoModel.Messages[2].Fields[0].Name, "customer_id")
+		require.Equal(t, protoModel.Messages[2].Fields[0].Rules, "required,max=64")
+		require.Equal(t, protoModel.Messages[0].Fields[0].Rules, "")
+
 		require.Equal(t, protoModel.Messages[4].MessageName, "TestMessage")
 		require.Equal(t, len(protoModel.Messages[4].Fields), 5)
 		require.Equal(t, protoModel.Messages[4].Fields[3].Name, "importFields")
@@ -0,0 +1,285 @@
+package proto2openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/codegen/proto2dgo"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/openapi"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/validator"
+)
+
+// ErrorSchemaName is the schema of the error body every method may answer,
+// shared.AppError as the gateway writes it.
+const ErrorSchemaName = "Error"
+
+// scalarSchemas maps proto scalars to their JSON form. Messages are encoded
+// with encoding/json, so 64 bit integers are numbers and bytes are base64.
+var scalarSchemas = map[string]openapi.Schema{
+	"string":   {Type: "string"},
+	"bool":     {Type: "boolean"},
+	"bytes":    {Type: "string", Format: "byte"},
+	"float":    {Type: "number", Format: "float"},
+	"double":   {Type: "number", Format: "double"},
+	"int32":    {Type: "integer", Format: "int32"},
+	"sint32":   {Type: "integer", Format: "int32"},
+	"sfixed32": {Type: "integer", Format: "int32"},
+	"int64":    {Type: "integer", Format: "int64"},
+	"sint64":   {Type: "integer", Format: "int64"},
+	"sfixed64": {Type: "integer", Format: "int64"},
+	"uint32":   {Type: "integer", Format: "int64", Minimum: new(float64)},
+	"fixed32":  {Type: "integer", Format: "int64", Minimum: new(float64)},
+	"uint64":   {Type: "integer", Format: "int64", Minimum: new(float64)},
+	"fixed64":  {Type: "integer", Format: "int64", Minimum: new(float64)},
+}
+
+type Proto2OpenAPIGenerater struct {
+	parser *proto2dgo.ProtoParser
+}
+
+func NewProto2OpenAPIGenerater() *Proto2OpenAPIGenerater {
+	return &Proto2OpenAPIGenerater{
+		parser: proto2dgo.NewProtoParser(),
+	}
+}
+
+// GenerateOpenAPI writes the OpenAPI document of the proto file to outputPath.
+func (g *Proto2OpenAPIGenerater) GenerateOpenAPI(protoPath, outputPath string) error {
+	protoModel, err := g.parser.ParseProtoFile(protoPath)
+	if err != nil {
+		return err
+	}
+	document, err := BuildDocument(protoModel)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return fmt.Errorf("fail to marshal openapi document: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("fail to create dir: %w", err)
+	}
+	if err := os.WriteFile(outputPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("fail to write openapi document: %w", err)
+	}
+	return nil
+}
+
+// BuildDocument describes every method of the file as the gateway exposes it:
+// POST /api/v1/<service>/<method> with the request message as JSON body.
+// Schemas are named <proto package>.<name>, so the documents of several
+// services merge without clashes.
+func BuildDocument(protoModel *proto2dgo.ProtoModel) (*openapi.Document, error) {
+	if protoModel == nil {
+		return nil, fmt.Errorf("model proto is nil")
+	}
+	builder := &documentBuilder{
+		model:    protoModel,
+		messages: map[string]bool{},
+		enums:    map[string]bool{},
+	}
+	for _, message := range protoModel.Messages {
+		builder.messages[message.MessageName] = true
+	}
+	for _, enum := range protoModel.Enums {
+		builder.enums[enum.Name] = true
+	}
+	return builder.build()
+}
+
+type documentBuilder struct {
+	model    *proto2dgo.ProtoModel
+	messages map[string]bool
+	enums    map[string]bool
+}
+
+func (b *documentBuilder) schemaName(name string) string {
+	return b.model.ProtoPackage + "." + name
+}
+
+func (b *documentBuilder) build() (*openapi.Document, error) {
+	document := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:   b.model.ProtoPackage,
+			Version: "v1",
+		},
+		Paths: map[string]*openapi.PathItem{},
+		Components: openapi.Components{
+			Schemas: map[string]*openapi.Schema{
+				ErrorSchemaName: errorSchema(),
+			},
+		},
+	}
+
+	subject := b.model.NatsSubject()
+	for _, service := range b.model.Services {
+		document.Tags = append(document.Tags, openapi.Tag{Name: service.Name})
+		for _, method := range service.Methods {
+			for _, messageType := range []string{method.RequestType, method.ResponseType} {
+				if !b.messages[messageType] {
+					return nil, fmt.Errorf("%s.%s: message %s is not defined in the file", service.Name, method.Name, messageType)
+				}
+			}
+			document.Paths[subject+"/"+method.Name] = &openapi.PathItem{
+				Post: &openapi.Operation{
+					OperationId: b.model.ProtoPackage + "." + method.Name,
+					Summary:     fmt.Sprintf("%s of %s", method.Name, service.Name),
+					Tags:        []string{service.Name},
+					RequestBody: &openapi.RequestBody{
+						Required: true,
+						Content:  jsonContent(openapi.SchemaRef(b.schemaName(method.RequestType))),
+					},
+					Responses: map[string]*openapi.Response{
+						"200": {
+							Description: method.ResponseType,
+							Content:     jsonContent(openapi.SchemaRef(b.schemaName(method.ResponseType))),
+						},
+						"default": {
+							Description: "error",
+							Content:     jsonContent(openapi.SchemaRef(ErrorSchemaName)),
+						},
+					},
+				},
+			}
+		}
+	}
+
+	for _, enum := range b.model.Enums {
+		schema := &openapi.Schema{Type: "integer", Format: "int32"}
+		for _, field := range enum.EnumFields {
+			schema.Enum = append(schema.Enum, field.Value)
+			schema.EnumNames = append(schema.EnumNames, field.Key)
+		}
+		// the gateway also binds the names from a query string
+		schema.Description = "one of " + strings.Join(schema.EnumNames, ", ")
+		document.Components.Schemas[b.schemaName(enum.Name)] = schema
+	}
+	for _, message := range b.model.Messages {
+		schema, err := b.messageSchema(message)
+		if err != nil {
+			return nil, err
+		}
+		document.Components.Schemas[b.schemaName(message.MessageName)] = schema
+	}
+	return document, nil
+}
+
+func (b *documentBuilder) messageSchema(message proto2dgo.MessageModel) (*openapi.Schema, error) {
+	schema := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{}}
+	for _, field := range message.Fields {
+		property := b.typeSchema(field.Type)
+		if field.IsRepeat {
+			property = &openapi.Schema{Type: "array", Items: property}
+		}
+		required, err := applyRules(property, field)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", message.MessageName, field.Name, err)
+		}
+		if required {
+			schema.Required = append(schema.Required, field.Name)
+		}
+		// protoc-gen-go tags the fields with their proto name
+		schema.Properties[field.Name] = property
+	}
+	return schema, nil
+}
+
+func (b *documentBuilder) typeSchema(protoType string) *openapi.Schema {
+	if scalar, ok := scalarSchemas[protoType]; ok {
+		return &scalar
+	}
+	name := strings.TrimPrefix(protoType, b.model.ProtoPackage+".")
+	if b.messages[name] || b.enums[name] {
+		return openapi.SchemaRef(b.schemaName(name))
+	}
+	// a type of an imported file, which this document does not describe
+	return &openapi.Schema{Type: "object", Description: protoType}
+}
+
+// applyRules turns the @validate rules of field into schema constraints and
+// tells whether the field is required.
+func applyRules(schema *openapi.Schema, field proto2dgo.FieldModel) (bool, error) {
+	if field.Rules == "" {
+		return false, nil
+	}
+	rules, err := validator.ParseRules(field.Rules)
+	if err != nil {
+		return false, err
+	}
+	required := false
+	for _, rule := range rules {
+		switch rule.Name {
+		case "required":
+			required = true
+			if field.IsRepeat {
+				schema.MinItems = intPtr(1)
+			} else if schema.Type == "string" && field.Type != "bytes" {
+				schema.MinLength = intPtr(1)
+			}
+		case "min", "max":
+			limit, _ := strconv.ParseFloat(rule.Arg, 64)
+			isMin := rule.Name == "min"
+			switch {
+			case field.IsRepeat:
+				setIntLimit(&schema.MinItems, &schema.MaxItems, isMin, limit)
+			case schema.Type == "string":
+				setIntLimit(&schema.MinLength, &schema.MaxLength, isMin, limit)
+			case isMin:
+				schema.Minimum = &limit
+			default:
+				schema.Maximum = &limit
+			}
+		case "uuid", "email":
+			schema.Format = rule.Name
+		case "oneof":
+			for _, item := range strings.Split(rule.Arg, "|") {
+				if schema.Type == "string" {
+					schema.Enum = append(schema.Enum, item)
+					continue
+				}
+				value, err := strconv.ParseFloat(item, 64)
+				if err != nil {
+					return false, fmt.Errorf("oneof value %q is not a number", item)
+				}
+				schema.Enum = append(schema.Enum, value)
+			}
+		}
+	}
+	return required, nil
+}
+
+func setIntLimit(min, max **int, isMin bool, limit float64) {
+	if isMin {
+		*min = intPtr(int(limit))
+		return
+	}
+	*max = intPtr(int(limit))
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func jsonContent(schema *openapi.Schema) map[string]*openapi.MediaType {
+	return map[string]*openapi.MediaType{"application/json": {Schema: schema}}
+}
+
+func errorSchema() *openapi.Schema {
+	return &openapi.Schema{
+		Type:     "object",
+		Required: []string{"code", "status_code", "error"},
+		Properties: map[string]*openapi.Schema{
+			"code":        {Type: "string"},
+			"status_code": {Type: "integer", Format: "int32"},
+			"error":       {Type: "string"},
+			"details":     {Type: "object", AdditionalProperties: &openapi.Schema{}},
+			"retryable":   {Type: "boolean"},
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package proto2openapi_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/codegen/proto2dgo"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/codegen/proto2openapi"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/openapi"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BuildDocument(t *testing.T) {
+	protoModel, err := proto2dgo.NewProtoParser().ParseProtoFile("./data/catalog.proto")
+	require.NoError(t, err)
+	document, err := proto2openapi.BuildDocument(protoModel)
+	require.NoError(t, err)
+	schemas := document.Components.Schemas
+
+	t.Run("Test_Paths_Follow_Gateway_Convention", func(t *testing.T) {
+		require.Len(t, document.Paths, 2)
+		operation := document.Paths["/api/v1/catalog/ListProducts"].Post
+		require.NotNil(t, operation)
+		require.Equal(t, "catalog.ListProducts", operation.OperationId)
+		require.Equal(t, []string{"CatalogService"}, operation.Tags)
+		require.Equal(t, "#/components/schemas/catalog.ListProductsRequest", operation.RequestBody.Content["application/json"].Schema.Ref)
+		require.Equal(t, "#/components/schemas/catalog.ListProductsResponse", operation.Responses["200"].Content["application/json"].Schema.Ref)
+		require.Equal(t, "#/components/schemas/"+proto2openapi.ErrorSchemaName, operation.Responses["default"].Content["application/json"].Schema.Ref)
+	})
+
+	t.Run("Test_Empty_Message", func(t *testing.T) {
+		require.Equal(t, &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{}}, schemas["catalog.Empty"])
+	})
+
+	t.Run("Test_Enum", func(t *testing.T) {
+		enum := schemas["catalog.ProductState"]
+		require.Equal(t, "integer", enum.Type)
+		require.Equal(t, []any{0, 1}, enum.Enum)
+		require.Equal(t, []string{"DRAFT", "PUBLISHED"}, enum.EnumNames)
+	})
+
+	t.Run("Test_Validation_Rules", func(t *testing.T) {
+		request := schemas["catalog.ListProductsRequest"]
+		require.Equal(t, []string{"owner_email"}, request.Required)
+		ids := request.Properties["ids"]
+		require.Equal(t, "array", ids.Type)
+		require.Equal(t, "string", ids.Items.Type)
+		require.Equal(t, 50, *ids.MaxItems)
+		limit := request.Properties["limit"]
+		require.Equal(t, "int64", limit.Format)
+		require.Equal(t, 1.0, *limit.Minimum)
+		require.Equal(t, 100.0, *limit.Maximum)
+		require.Equal(t, "email", request.Properties["owner_email"].Format)
+		require.Equal(t, []any{"name", "price"}, request.Properties["sort"].Enum)
+		require.Equal(t, "#/components/schemas/catalog.ProductState", request.Properties["state"].Ref)
+	})
+
+	t.Run("Test_Field_Types", func(t *testing.T) {
+		product := schemas["catalog.Product"]
+		require.Equal(t, "byte", product.Properties["image"].Format)
+		require.Equal(t, 0.0, *product.Properties["stock"].Minimum)
+		require.Equal(t, &openapi.Schema{Type: "object", Description: "common.Money"}, product.Properties["price"])
+		require.Equal(t, "#/components/schemas/catalog.ProductState", product.Properties["state"].Ref)
+		products := schemas["catalog.ListProductsResponse"].Properties["products"]
+		require.Equal(t, "#/components/schemas/catalog.Product", products.Items.Ref)
+	})
+}
+
+func Test_GenerateOpenAPI(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "catalog", "catalog.openapi.json")
+	require.NoError(t, proto2openapi.NewProto2OpenAPIGenerater().GenerateOpenAPI("./data/catalog.proto", output))
+	document, err := openapi.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, openapi.Version, document.OpenAPI)
+	require.Contains(t, document.Paths, "/api/v1/catalog/Ping")
+}
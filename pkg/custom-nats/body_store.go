@@ -0,0 +1,119 @@
+package custom_nats
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/spf13/viper"
+)
+
+// BodyObjectHeader names the object holding the body of a request too large
+// for a nats message. The envelope of such a request has no body.
+const BodyObjectHeader = "Nats-Body-Object"
+
+// defaultBodyRestoreTimeout bounds the fetch of a body for a request that
+// carries no deadline.
+const defaultBodyRestoreTimeout = 30 * time.Second
+
+// envelopeHeadroom is kept free in a message for the envelope around the body.
+const envelopeHeadroom = 64 * 1024
+
+// BodyStore keeps large request bodies in a JetStream object store. The
+// gateway puts the body and deletes it once the request is answered; the
+// bucket TTL removes what a crashed gateway left behind.
+type BodyStore struct {
+	natsConn *nats.Conn
+	bucket   string
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	store jetstream.ObjectStore
+}
+
+func NewBodyStore(natsConn *nats.Conn, bucket string, ttl time.Duration) *BodyStore {
+	return &BodyStore{natsConn: natsConn, bucket: bucket, ttl: ttl}
+}
+
+// LoadBodyStore builds the store of nats_server.body_store.
+func LoadBodyStore(natsConn *nats.Conn) *BodyStore {
+	return NewBodyStore(natsConn, viper.GetString(BodyStoreBucketKey), viper.GetDuration(BodyStoreTTLKey))
+}
+
+// objectStore opens the bucket on first use, so a service that never gets a
+// large body does not need JetStream.
+func (b *BodyStore) objectStore(ctx context.Context) (jetstream.ObjectStore, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.store != nil {
+		return b.store, nil
+	}
+	js, err := jetstream.New(b.natsConn)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create jetstream context: %w", err)
+	}
+	store, err := js.CreateOrUpdateObjectStore(ctx, jetstream.ObjectStoreConfig{
+		Bucket: b.bucket,
+		TTL:    b.ttl,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fail to open object store %s: %w", b.bucket, err)
+	}
+	b.store = store
+	return store, nil
+}
+
+// Offload moves the body of req to the store when req would not fit in a
+// message of maxPayload bytes, and returns the name of the object, "" when the
+// body stays inline.
+func (b *BodyStore) Offload(ctx context.Context, req *Request, maxPayload int64) (string, error) {
+	if int64(len(req.Body)) <= maxPayload-envelopeHeadroom {
+		return "", nil
+	}
+	store, err := b.objectStore(ctx)
+	if err != nil {
+		return "", err
+	}
+	name := uuid.NewString()
+	if _, err := store.PutBytes(ctx, name, req.Body); err != nil {
+		return "", fmt.Errorf("fail to put request body: %w", err)
+	}
+	req.Body = nil
+	req.SetHeader(BodyObjectHeader, name)
+	return name, nil
+}
+
+// Restore puts back the body of a request whose body was offloaded.
+func (b *BodyStore) Restore(ctx context.Context, req *Request) error {
+	values := req.Header[BodyObjectHeader]
+	if len(values) == 0 {
+		return nil
+	}
+	store, err := b.objectStore(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := store.GetBytes(ctx, values[0])
+	if err != nil {
+		return fmt.Errorf("fail to get request body %s: %w", values[0], err)
+	}
+	req.Body = body
+	delete(req.Header, BodyObjectHeader)
+	return nil
+}
+
+// Delete removes an offloaded body once its request is answered.
+func (b *BodyStore) Delete(ctx context.Context, name string) error {
+	store, err := b.objectStore(ctx)
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(ctx, name); err != nil {
+		return fmt.Errorf("fail to delete request body %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package custom_nats
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// CacheInvalidationSubject is where services tell the gateway which cached
+// responses are stale.
+const CacheInvalidationSubject = "gateway.cache.invalidate"
+
+// CacheInvalidation is the message published on CacheInvalidationSubject.
+type CacheInvalidation struct {
+	Tags []string `json:"tags"`
+}
+
+// InvalidateCache drops the responses the gateway cached under any of tags,
+// e.g. after the resource they show changed.
+func InvalidateCache(natsConn *nats.Conn, tags ...string) error {
+	data, err := json.Marshal(CacheInvalidation{Tags: tags})
+	if err != nil {
+		return fmt.Errorf("fail to marshal cache invalidation: %w", err)
+	}
+	if err := natsConn.Publish(CacheInvalidationSubject, data); err != nil {
+		return fmt.Errorf("fail to publish cache invalidation: %w", err)
+	}
+	return nil
+}
@@ -1,5 +1,5 @@
 package custom_nats
 
 type Client interface {
-	Register(Router)
+	Register(*Router)
 }
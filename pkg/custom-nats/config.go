@@ -1,17 +1,32 @@
 package custom_nats
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 const (
-	NatsURLKey                = "nats_auth.nats_url"
-	NatsAppAccUserNameKey     = "nats_auth.nats_apps.0.username"
-	NatsAppAccountPasswordKey = "nats_auth.nats_apps.0.password"
+	NatsURLKey                   = "nats_auth.nats_url"
+	NatsAppAccUserNameKey        = "nats_auth.nats_apps.0.username"
+	NatsAppAccountPasswordKey    = "nats_auth.nats_apps.0.password"
+	NatsServerMaxInFlightKey     = "nats_server.max_in_flight"
+	NatsServerMaxPendingKey      = "nats_server.max_pending"
+	NatsServerHealthAddrKey      = "nats_server.health_addr"
+	NatsServerStreamChunkSizeKey = "nats_server.stream_chunk_size"
+	BodyStoreBucketKey           = "nats_server.body_store.bucket"
+	BodyStoreTTLKey              = "nats_server.body_store.ttl"
 )
 
 func init() {
 	viper.SetDefault(NatsURLKey, "nats://localhost:4222")
 	viper.SetDefault(NatsAppAccUserNameKey, "app")
 	viper.SetDefault(NatsAppAccountPasswordKey, "app")
+	viper.SetDefault(NatsServerMaxInFlightKey, 64)
+	viper.SetDefault(NatsServerMaxPendingKey, 512)
+	viper.SetDefault(NatsServerStreamChunkSizeKey, 64*1024)
+	viper.SetDefault(BodyStoreBucketKey, "request_bodies")
+	viper.SetDefault(BodyStoreTTLKey, 10*time.Minute)
 }
 
 type NatsConfig struct {
@@ -2,6 +2,7 @@ package custom_nats
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/circuitbreaker"
 	"github.com/nats-io/nats.go"
@@ -36,13 +37,73 @@ func NewNatsConnWithCircuitBreaker(conn *nats.Conn, breaker *circuitbreaker.Brea
 type NatsSendRequest struct {
 	Subject string
 	Content []byte
+	Header  nats.Header
 }
 
 func (ncc *NatsConnWithCircuitBreaker) SendRequest(ctx context.Context, req *NatsSendRequest) (*nats.Msg, error) {
 	res, err := ncc.breaker.Do(ctx, func() (*nats.Msg, error) {
-		return ncc.conn.RequestWithContext(ctx, req.Subject, req.Content)
+		return ncc.conn.RequestMsgWithContext(ctx, &nats.Msg{
+			Subject: req.Subject,
+			Data:    req.Content,
+			Header:  req.Header,
+		})
 	})
-	return *res, err
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+// ReplyStream reads the replies of one request from its own inbox, which lets
+// a service answer with a stream of frames instead of a single message.
+type ReplyStream struct {
+	sub *nats.Subscription
+}
+
+func (rs *ReplyStream) Next(ctx context.Context) (*nats.Msg, error) {
+	return rs.sub.NextMsgWithContext(ctx)
+}
+
+func (rs *ReplyStream) Close() error {
+	return rs.sub.Unsubscribe()
+}
+
+// SendStreamRequest publishes the request with a dedicated reply inbox and
+// waits for the first reply. Only that first reply counts for the circuit
+// breaker; the caller reads any further frame from the returned ReplyStream
+// and must close it. A request that is never streamed is cheaper with
+// SendRequest, which needs no subscription of its own.
+func (ncc *NatsConnWithCircuitBreaker) SendStreamRequest(ctx context.Context, req *NatsSendRequest) (*nats.Msg, *ReplyStream, error) {
+	sub, err := ncc.conn.SubscribeSync(ncc.conn.NewRespInbox())
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to subcribe reply inbox: %w", err)
+	}
+	res, err := ncc.breaker.Do(ctx, func() (*nats.Msg, error) {
+		err := ncc.conn.PublishMsg(&nats.Msg{
+			Subject: req.Subject,
+			Reply:   sub.Subject,
+			Data:    req.Content,
+			Header:  req.Header,
+		})
+		if err != nil {
+			return nil, err
+		}
+		reply, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// the nats server answers a request nobody listens to with an empty
+		// 503 status message
+		if len(reply.Data) == 0 && reply.Header.Get("Status") == "503" {
+			return nil, nats.ErrNoResponders
+		}
+		return reply, nil
+	})
+	if err != nil {
+		_ = sub.Unsubscribe()
+		return nil, nil, err
+	}
+	return *res, &ReplyStream{sub: sub}, nil
 }
 
 func (ncc *NatsConnWithCircuitBreaker) GetNatsConn() *nats.Conn {
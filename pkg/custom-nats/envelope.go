@@ -0,0 +1,352 @@
+package custom_nats
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// EnvelopeEncodingHeader is the nats header naming the encoding of the
+// envelope carried in the message data. A message without it is JSON, which
+// keeps gateways and services of different versions talking to each other.
+const (
+	EnvelopeEncodingHeader = "Nats-Envelope-Encoding"
+	EnvelopeJSON           = "json"
+	EnvelopeProto          = "proto"
+)
+
+// EnvelopeCodec encodes the Request and Response travelling over nats.
+type EnvelopeCodec interface {
+	Name() string
+	MarshalRequest(req *Request) ([]byte, error)
+	UnmarshalRequest(data []byte, req *Request) error
+	MarshalResponse(res *Response) ([]byte, error)
+	UnmarshalResponse(data []byte, res *Response) error
+}
+
+var envelopeCodecs = map[string]EnvelopeCodec{
+	EnvelopeJSON:  jsonEnvelopeCodec{},
+	EnvelopeProto: protoEnvelopeCodec{},
+}
+
+func GetEnvelopeCodec(name string) (EnvelopeCodec, error) {
+	if name == "" {
+		return jsonEnvelopeCodec{}, nil
+	}
+	codec, ok := envelopeCodecs[name]
+	if !ok {
+		return nil, fmt.Errorf("envelope encoding %q is not supported", name)
+	}
+	return codec, nil
+}
+
+// EnvelopeCodecFromHeader picks the codec named by the message header and
+// falls back to JSON for messages of peers that do not set it.
+func EnvelopeCodecFromHeader(header nats.Header) (EnvelopeCodec, error) {
+	if header == nil {
+		return jsonEnvelopeCodec{}, nil
+	}
+	return GetEnvelopeCodec(header.Get(EnvelopeEncodingHeader))
+}
+
+// EnvelopeHeader announces the codec to the peer. JSON is left implicit so
+// the messages stay readable by peers that do not know the header.
+func EnvelopeHeader(codec EnvelopeCodec) nats.Header {
+	header := nats.Header{}
+	if codec.Name() != EnvelopeJSON {
+		header.Set(EnvelopeEncodingHeader, codec.Name())
+	}
+	return header
+}
+
+// NewEnvelopeMsg builds a nats message whose header announces the codec.
+func NewEnvelopeMsg(subject string, data []byte, codec EnvelopeCodec) *nats.Msg {
+	return &nats.Msg{
+		Subject: subject,
+		Data:    data,
+		Header:  EnvelopeHeader(codec),
+	}
+}
+
+type jsonEnvelopeCodec struct{}
+
+func (jsonEnvelopeCodec) Name() string {
+	return EnvelopeJSON
+}
+
+func (jsonEnvelopeCodec) MarshalRequest(req *Request) ([]byte, error) {
+	return json.Marshal(req)
+}
+
+func (jsonEnvelopeCodec) UnmarshalRequest(data []byte, req *Request) error {
+	return json.Unmarshal(data, req)
+}
+
+func (jsonEnvelopeCodec) MarshalResponse(res *Response) ([]byte, error) {
+	return json.Marshal(res)
+}
+
+func (jsonEnvelopeCodec) UnmarshalResponse(data []byte, res *Response) error {
+	return json.Unmarshal(data, res)
+}
+
+// protoEnvelopeCodec writes the envelope in the protobuf wire format. Bodies
+// are raw bytes instead of base64 strings. The layout is
+//
+//	message HeaderValues { repeated string values = 1; }
+//	message Request {
+//	  map<string, HeaderValues> header = 1;
+//	  string method = 2;
+//	  bytes body = 3;
+//	  string url = 4;
+//	  string subject = 5;
+//	  string service_name = 6;
+//	}
+//	message AppError {
+//	  string code = 1;
+//	  int64 status_code = 2;
+//	  string message = 3;
+//	  bytes details_json = 4;
+//	  bool retryable = 5;
+//	}
+//	message Response {
+//	  int64 status_code = 1;
+//	  string status = 2;
+//	  bytes body = 3;
+//	  map<string, HeaderValues> headers = 4;
+//	  AppError error = 5;
+//	}
+type protoEnvelopeCodec struct{}
+
+func (protoEnvelopeCodec) Name() string {
+	return EnvelopeProto
+}
+
+func (protoEnvelopeCodec) MarshalRequest(req *Request) ([]byte, error) {
+	var b []byte
+	b = appendHeaderMap(b, 1, req.Header)
+	b = appendString(b, 2, req.Method)
+	b = appendBytes(b, 3, req.Body)
+	b = appendString(b, 4, req.URL)
+	b = appendString(b, 5, req.Subject)
+	b = appendString(b, 6, req.ServiceName)
+	return b, nil
+}
+
+func (protoEnvelopeCodec) UnmarshalRequest(data []byte, req *Request) error {
+	return consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		switch num {
+		case 1:
+			if req.Header == nil {
+				req.Header = make(map[string][]string)
+			}
+			return consumeHeaderEntry(value, req.Header)
+		case 2:
+			req.Method = string(value)
+		case 3:
+			req.Body = append([]byte(nil), value...)
+		case 4:
+			req.URL = string(value)
+		case 5:
+			req.Subject = string(value)
+		case 6:
+			req.ServiceName = string(value)
+		}
+		return nil
+	})
+}
+
+func (protoEnvelopeCodec) MarshalResponse(res *Response) ([]byte, error) {
+	var b []byte
+	b = appendVarint(b, 1, uint64(res.StatusCode))
+	b = appendString(b, 2, res.Status)
+	b = appendBytes(b, 3, res.Body)
+	b = appendHeaderMap(b, 4, res.Headers)
+	if res.Error != nil {
+		appErr, err := marshalAppError(res.Error)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, appErr)
+	}
+	return b, nil
+}
+
+func (protoEnvelopeCodec) UnmarshalResponse(data []byte, res *Response) error {
+	return consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			res.StatusCode = int(varint)
+		case 2:
+			res.Status = string(value)
+		case 3:
+			res.Body = append([]byte(nil), value...)
+		case 4:
+			if res.Headers == nil {
+				res.Headers = http.Header{}
+			}
+			return consumeHeaderEntry(value, res.Headers)
+		case 5:
+			appErr, err := unmarshalAppError(value)
+			if err != nil {
+				return err
+			}
+			res.Error = appErr
+		}
+		return nil
+	})
+}
+
+func marshalAppError(appErr *shared.AppError) ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, string(appErr.Code))
+	b = appendVarint(b, 2, uint64(appErr.StatusCode))
+	b = appendString(b, 3, appErr.Message)
+	if len(appErr.Details) > 0 {
+		details, err := json.Marshal(appErr.Details)
+		if err != nil {
+			return nil, fmt.Errorf("fail to marshal error details: %w", err)
+		}
+		b = appendBytes(b, 4, details)
+	}
+	if appErr.Retryable {
+		b = appendVarint(b, 5, 1)
+	}
+	return b, nil
+}
+
+func unmarshalAppError(data []byte) (*shared.AppError, error) {
+	appErr := &shared.AppError{}
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			appErr.Code = shared.ErrorType(value)
+		case 2:
+			appErr.StatusCode = int(varint)
+		case 3:
+			appErr.Message = string(value)
+		case 4:
+			if err := json.Unmarshal(value, &appErr.Details); err != nil {
+				return fmt.Errorf("fail to unmarshal error details: %w", err)
+			}
+		case 5:
+			appErr.Retryable = varint != 0
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return appErr, nil
+}
+
+func appendString(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+func appendBytes(b []byte, num protowire.Number, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+func appendVarint(b []byte, num protowire.Number, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+// appendHeaderMap writes one map entry per key, sorted so the same header
+// always encodes to the same bytes.
+func appendHeaderMap(b []byte, num protowire.Number, header map[string][]string) []byte {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var values []byte
+		for _, value := range header[key] {
+			values = protowire.AppendTag(values, 1, protowire.BytesType)
+			values = protowire.AppendString(values, value)
+		}
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, key)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, values)
+
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+func consumeHeaderEntry(data []byte, header map[string][]string) error {
+	var key string
+	values := []string{}
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		switch num {
+		case 1:
+			key = string(value)
+		case 2:
+			return consumeFields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+				if num == 1 {
+					values = append(values, string(value))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	header[key] = append(header[key], values...)
+	return nil
+}
+
+// consumeFields walks the fields of one message. Length delimited values are
+// passed as value and varints as varint; unknown fields are skipped so newer
+// peers may add fields.
+func consumeFields(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("fail to decode envelope: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+		var value []byte
+		var varint uint64
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return fmt.Errorf("fail to decode envelope: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+		if err := fn(num, typ, value, varint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package custom_nats
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// UserEventsPrefix starts the subjects of the events sent to one user,
+// events.user.<user id>.<topic>, which the gateway forwards to the WebSocket
+// and SSE connections of that user.
+const UserEventsPrefix = "events.user."
+
+// UserEventSubject is the subject of the events of topic, e.g.
+// "order.status", for userId.
+func UserEventSubject(userId, topic string) (string, error) {
+	if !validSubjectToken(userId) {
+		return "", fmt.Errorf("user id %q cannot be used in a subject", userId)
+	}
+	for _, token := range strings.Split(topic, ".") {
+		if !validSubjectToken(token) {
+			return "", fmt.Errorf("event topic %q is not valid", topic)
+		}
+	}
+	return UserEventsPrefix + userId + "." + topic, nil
+}
+
+// UserEventsFilter matches every event of userId.
+func UserEventsFilter(userId string) (string, error) {
+	if !validSubjectToken(userId) {
+		return "", fmt.Errorf("user id %q cannot be used in a subject", userId)
+	}
+	return UserEventsPrefix + userId + ".>", nil
+}
+
+func validSubjectToken(token string) bool {
+	return token != "" && !strings.ContainsAny(token, ".*> \t\r\n")
+}
+
+// PublishUserEvent sends data, encoded as JSON, to the connected clients of
+// userId, e.g. after the status of one of their orders changed.
+func PublishUserEvent(natsConn *nats.Conn, userId, topic string, data any) error {
+	subject, err := UserEventSubject(userId, topic)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("fail to marshal user event: %w", err)
+	}
+	if err := natsConn.Publish(subject, payload); err != nil {
+		return fmt.Errorf("fail to publish user event: %w", err)
+	}
+	return nil
+}
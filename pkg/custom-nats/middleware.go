@@ -0,0 +1,109 @@
+package custom_nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+)
+
+// Middleware wraps an Endpoint. It runs around the decoded handler call, so it
+// sees the typed result and error of the handler.
+type Middleware func(next Endpoint) Endpoint
+
+// RouteInfo describes the route being served. Middlewares read it from the
+// context with RouteInfoFromContext.
+type RouteInfo struct {
+	Method string
+	Path   string
+}
+
+func RouteInfoFromContext(ctx context.Context) (RouteInfo, bool) {
+	route, ok := ctx.Value(shared.RouteInfo_ContextKey).(RouteInfo)
+	return route, ok
+}
+
+// chainMiddlewares wraps e so that the first middleware is the outermost one.
+func chainMiddlewares(e Endpoint, middlewares ...Middleware) Endpoint {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		e = middlewares[i](e)
+	}
+	return e
+}
+
+// Recovery turns a panic in the handler into a 500 AppError instead of
+// crashing the NATS subscription goroutine.
+func Recovery() Middleware {
+	return func(next Endpoint) Endpoint {
+		return func(ctx context.Context, body []byte) (res interface{}, err error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					route, _ := RouteInfoFromContext(ctx)
+					logging.GetSugaredLogger().Errorf("panic in handler %s %s: %v\n%s", route.Method, route.Path, recovered, debug.Stack())
+					res = nil
+					err = shared.NewInternalServerError("internal server error").WithCause(fmt.Errorf("panic: %v", recovered))
+				}
+			}()
+			return next(ctx, body)
+		}
+	}
+}
+
+// Timeout bounds the handler context. A handler that fails because the
+// deadline passed is reported as a retryable 504.
+func Timeout(timeout time.Duration) Middleware {
+	return func(next Endpoint) Endpoint {
+		return func(ctx context.Context, body []byte) (interface{}, error) {
+			timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			res, err := next(timeoutCtx, body)
+			if err != nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+				return nil, shared.NewGatewayTimeoutError("handler timed out").WithCause(err)
+			}
+			return res, err
+		}
+	}
+}
+
+// Logging logs every handled request with its duration and error code.
+func Logging() Middleware {
+	return func(next Endpoint) Endpoint {
+		return func(ctx context.Context, body []byte) (interface{}, error) {
+			start := time.Now()
+			res, err := next(ctx, body)
+			route, _ := RouteInfoFromContext(ctx)
+			if err != nil {
+				appErr := shared.ToAppError(err)
+				logging.GetSugaredLogger().Errorf("%s %s %v code: %s error: %v", route.Method, route.Path, time.Since(start), appErr.Code, err)
+				return res, err
+			}
+			logging.GetSugaredLogger().Infof("%s %s %v", route.Method, route.Path, time.Since(start))
+			return res, err
+		}
+	}
+}
+
+// Authenticate accepts a request only when it carries an identity signed by
+// the gateway, and puts the identity in the context for the handler.
+func Authenticate(signer *identity.Signer) Middleware {
+	return func(next Endpoint) Endpoint {
+		return func(ctx context.Context, body []byte) (interface{}, error) {
+			r, ok := ctx.Value(shared.HTTPRequest_ContextKey).(*http.Request)
+			if !ok || r.Header.Get(identity.Header) == "" {
+				return nil, shared.NewUnauthorizedError("request has no identity")
+			}
+			signed := r.Header.Get(identity.Header)
+			id, err := signer.Verify(signed)
+			if err != nil {
+				return nil, shared.NewUnauthorizedError("request identity is not valid").WithCause(err)
+			}
+			return next(identity.NewContext(ctx, id, signed), body)
+		}
+	}
+}
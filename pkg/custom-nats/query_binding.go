@@ -0,0 +1,339 @@
+package custom_nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// QueryBindingHeader marks a request whose body was built from a query
+// string. Every value in such a body is a string, so the service coerces them
+// to the types of the request message before decoding.
+const QueryBindingHeader = "X-Query-Binding"
+
+// QueryToJSON turns a raw query string into a JSON object. Values are
+// percent-decoded, repeated keys and keys ending in [] become arrays, and
+// "a.b" or "a[b]" build nested objects.
+func QueryToJSON(rawQuery string) ([]byte, error) {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, shared.NewBadRequestError(fmt.Sprintf("invalid query string: %v", err))
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	root := map[string]any{}
+	for _, key := range keys {
+		path, isArray, err := parseQueryKey(key)
+		if err != nil {
+			return nil, shared.NewBadRequestError(err.Error())
+		}
+		var value any
+		if isArray || len(values[key]) > 1 {
+			items := make([]any, 0, len(values[key]))
+			for _, item := range values[key] {
+				items = append(items, item)
+			}
+			value = items
+		} else {
+			value = values[key][0]
+		}
+		if err := setQueryValue(root, path, value); err != nil {
+			return nil, shared.NewBadRequestError(fmt.Sprintf("query parameter %q conflicts with another parameter", key))
+		}
+	}
+	return json.Marshal(root)
+}
+
+// parseQueryKey splits "a.b", "a[b]" and "a[b][]" into the path of nested
+// fields and reports whether the key asks for an array.
+func parseQueryKey(key string) ([]string, bool, error) {
+	invalid := fmt.Errorf("invalid query parameter %q", key)
+	isArray := strings.HasSuffix(key, "[]")
+	key = strings.TrimSuffix(key, "[]")
+
+	path := []string{}
+	for len(key) > 0 {
+		switch key[0] {
+		case '[':
+			end := strings.IndexByte(key, ']')
+			if end < 0 {
+				return nil, false, invalid
+			}
+			path = append(path, key[1:end])
+			key = key[end+1:]
+			continue
+		case '.':
+			if len(path) == 0 {
+				return nil, false, invalid
+			}
+			key = key[1:]
+		}
+		end := strings.IndexAny(key, ".[")
+		if end < 0 {
+			end = len(key)
+		}
+		path = append(path, key[:end])
+		key = key[end:]
+	}
+	if len(path) == 0 {
+		return nil, false, invalid
+	}
+	for _, segment := range path {
+		if segment == "" || strings.ContainsAny(segment, "[]") {
+			return nil, false, invalid
+		}
+	}
+	return path, isArray, nil
+}
+
+func setQueryValue(root map[string]any, path []string, value any) error {
+	node := root
+	for _, segment := range path[:len(path)-1] {
+		child, ok := node[segment]
+		if !ok {
+			next := map[string]any{}
+			node[segment] = next
+			node = next
+			continue
+		}
+		next, ok := child.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s is not an object", segment)
+		}
+		node = next
+	}
+	leaf := path[len(path)-1]
+	if _, ok := node[leaf]; ok {
+		return fmt.Errorf("%s is set twice", leaf)
+	}
+	node[leaf] = value
+	return nil
+}
+
+func isQueryBinding(ctx context.Context) bool {
+	r, ok := ctx.Value(shared.HTTPRequest_ContextKey).(*http.Request)
+	return ok && r.Header.Get(QueryBindingHeader) != ""
+}
+
+// bindQueryBody rewrites a body built by QueryToJSON so that it decodes into
+// target: strings become numbers, booleans and enum values, single values
+// become arrays where the field is repeated, and keys such as
+// "error_description" are matched to the field named "ErrorDescription".
+func bindQueryBody(ctx context.Context, body []byte, target reflect.Type) ([]byte, error) {
+	if !isQueryBinding(ctx) {
+		return body, nil
+	}
+	var values map[string]any
+	if err := json.Unmarshal(body, &values); err != nil {
+		return nil, shared.NewBadRequestError("invalid query string").WithCause(err)
+	}
+	bound, err := coerceQueryValue(values, target, "")
+	if err != nil {
+		return nil, shared.NewBadRequestError(err.Error())
+	}
+	return json.Marshal(bound)
+}
+
+var protoEnumType = reflect.TypeOf((*protoreflect.Enum)(nil)).Elem()
+
+func coerceQueryValue(value any, t reflect.Type, path string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Implements(protoEnumType) {
+		return coerceQueryEnum(value, t, path)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("query parameter %q must be an object", path)
+		}
+		return coerceQueryObject(object, t, path)
+	case reflect.Map:
+		object, ok := value.(map[string]any)
+		if !ok || t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("query parameter %q must be an object", path)
+		}
+		result := make(map[string]any, len(object))
+		for key, item := range object {
+			coerced, err := coerceQueryValue(item, t.Elem(), joinQueryPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			result[key] = coerced
+		}
+		return result, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return singleQueryValue(value, path)
+		}
+		items, ok := value.([]any)
+		if !ok {
+			items = []any{value}
+		}
+		result := make([]any, 0, len(items))
+		for _, item := range items {
+			coerced, err := coerceQueryValue(item, t.Elem(), path)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, coerced)
+		}
+		return result, nil
+	case reflect.Interface:
+		return value, nil
+	}
+
+	raw, err := singleQueryValue(value, path)
+	if err != nil {
+		return nil, err
+	}
+	str, ok := raw.(string)
+	if !ok {
+		return raw, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return nil, fmt.Errorf("query parameter %q must be a boolean", path)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(str, 10, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("query parameter %q must be an integer", path)
+		}
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(str, 10, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("query parameter %q must be a positive integer", path)
+		}
+		return n, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("query parameter %q must be a number", path)
+		}
+		return f, nil
+	}
+	return str, nil
+}
+
+func coerceQueryObject(object map[string]any, t reflect.Type, path string) (map[string]any, error) {
+	fields := queryFieldsOf(t)
+	result := make(map[string]any, len(object))
+	for key, item := range object {
+		field, ok := fields[key]
+		if !ok {
+			field, ok = fields[normalizeQueryKey(key)]
+		}
+		if !ok {
+			// unknown keys are left for the json decoder to ignore
+			result[key] = item
+			continue
+		}
+		coerced, err := coerceQueryValue(item, field.Type, joinQueryPath(path, key))
+		if err != nil {
+			return nil, err
+		}
+		result[field.Name] = coerced
+	}
+	return result, nil
+}
+
+type queryField struct {
+	Name string
+	Type reflect.Type
+}
+
+// queryFieldsOf indexes the exported fields of t by json name and by
+// normalized name.
+func queryFieldsOf(t reflect.Type) map[string]queryField {
+	fields := map[string]queryField{}
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		name := structField.Name
+		if tag, ok := structField.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		field := queryField{Name: name, Type: structField.Type}
+		fields[name] = field
+		if _, ok := fields[normalizeQueryKey(name)]; !ok {
+			fields[normalizeQueryKey(name)] = field
+		}
+	}
+	return fields
+}
+
+func coerceQueryEnum(value any, t reflect.Type, path string) (any, error) {
+	raw, err := singleQueryValue(value, path)
+	if err != nil {
+		return nil, err
+	}
+	str, ok := raw.(string)
+	if !ok {
+		return raw, nil
+	}
+	if n, err := strconv.ParseInt(str, 10, 32); err == nil {
+		return n, nil
+	}
+	enum := reflect.Zero(t).Interface().(protoreflect.Enum)
+	enumValue := enum.Descriptor().Values().ByName(protoreflect.Name(str))
+	if enumValue == nil {
+		enumValue = enum.Descriptor().Values().ByName(protoreflect.Name(strings.ToUpper(str)))
+	}
+	if enumValue == nil {
+		return nil, fmt.Errorf("query parameter %q has an unknown value %q", path, str)
+	}
+	return int64(enumValue.Number()), nil
+}
+
+func singleQueryValue(value any, path string) (any, error) {
+	items, ok := value.([]any)
+	if !ok {
+		return value, nil
+	}
+	if len(items) != 1 {
+		return nil, fmt.Errorf("query parameter %q expects a single value", path)
+	}
+	return items[0], nil
+}
+
+func normalizeQueryKey(key string) string {
+	key = strings.ReplaceAll(key, "_", "")
+	key = strings.ReplaceAll(key, "-", "")
+	return strings.ToLower(key)
+}
+
+func joinQueryPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
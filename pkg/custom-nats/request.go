@@ -3,24 +3,48 @@ package custom_nats
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
 	"github.com/spf13/viper"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 )
 
 const (
 	backend_endpont_key = "general_config.backend_endpoint"
+	// RequestTimeoutHeader carries the caller's remaining budget in
+	// milliseconds, so the service knows when the caller stops waiting.
+	RequestTimeoutHeader = "X-Request-Timeout"
 )
 
 func init() {
 	viper.SetDefault(backend_endpont_key, "http://localhost:8080")
 }
 
+// internalHeaders are set by the gateway alone. A client copy is dropped, or
+// it could point the service at another request body, stretch its deadline,
+// change how the body is decoded or pass for another user.
+var internalHeaders = []string{BodyObjectHeader, RequestTimeoutHeader, QueryBindingHeader, identity.UserIdHeader}
+
+// copyClientHeaders copies the headers of a client request but the internal
+// ones.
+func copyClientHeaders(header http.Header) map[string][]string {
+	headers := make(map[string][]string, len(header))
+	for key, values := range header {
+		if slices.Contains(internalHeaders, http.CanonicalHeaderKey(key)) {
+			continue
+		}
+		headers[key] = append(headers[key], values...)
+	}
+	return headers
+}
+
 type Request struct {
 	Header      map[string][]string
 	Method      string
@@ -30,10 +54,6 @@ type Request struct {
 	ServiceName string
 }
 
-var specialEnpointMap = map[string]string{
-	"callback": "/api/v1/auth/Callback",
-}
-
 func NewRequest(header map[string][]string, method, url, subject string, body []byte) *Request {
 	serviceName := strings.Split(subject, "/")[3]
 	return &Request{
@@ -55,20 +75,41 @@ func (r *Request) AddHeader(key, value string) {
 	}
 }
 
+// SetHeader replaces every value of key with value.
+func (r *Request) SetHeader(key, value string) {
+	if r.Header == nil {
+		r.Header = make(map[string][]string)
+	}
+	r.Header[key] = []string{value}
+}
+
 func (r *Request) GetServiceName() string {
 	return r.ServiceName
 }
 
-func buildNatsSubjectFromPath(path string) string {
-	splits := strings.Split(path, "/")
-	var subject string
-	var i = 1
-	for i < 3 {
-		subject += splits[i] + "/"
-		i++
+// Target is where a request goes: the service, the subject its server
+// listens on and the path its router serves.
+type Target struct {
+	ServiceName string
+	Subject     string
+	Path        string
+	// Params are path parameters passed to the service as fields of the
+	// request message.
+	Params map[string]string
+}
+
+// DefaultTarget follows the /api/<version>/<service>/<method> convention,
+// sending the request to the subject /api/<version>/<service>.
+func DefaultTarget(path string) (Target, error) {
+	splits := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(splits) < 4 || splits[0] != "api" || slices.Contains(splits[:4], "") {
+		return Target{}, shared.NewNotFoundError(fmt.Sprintf("no route for %s", path))
 	}
-	subject = "/" + subject + splits[i]
-	return subject
+	return Target{
+		ServiceName: splits[2],
+		Subject:     "/" + strings.Join(splits[:3], "/"),
+		Path:        path,
+	}, nil
 }
 
 func copyCookieFromHTTPRequest(cookies []*http.Cookie) string {
@@ -82,68 +123,67 @@ func copyCookieFromHTTPRequest(cookies []*http.Cookie) string {
 	return strings.Join(cookiesString, "; ")
 }
 
-func convertHttpGetRequestToNatsPostRequest(r http.Request) (*Request, error) {
+func convertHttpGetRequestToNatsPostRequest(r http.Request, target Target) (*Request, error) {
 	urlObject := r.URL
 	host := urlObject.Host
-	path := urlObject.Path
 	if host == "" {
 		host = viper.GetString(backend_endpont_key)
 	}
-	queryParams := urlObject.RawQuery
-	querySplits := strings.Split(queryParams, "&")
-	queryObj := make(map[string]string)
-	for _, query := range querySplits {
-		keyValuePair := strings.Split(query, "=")
-		queryObj[cases.Title(language.English).String(keyValuePair[0])] = keyValuePair[1]
+	rawQuery := urlObject.RawQuery
+	if len(target.Params) > 0 {
+		values, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return nil, shared.NewBadRequestError(fmt.Sprintf("invalid query string: %v", err))
+		}
+		// a path parameter wins over a query parameter of the same name
+		for key, value := range target.Params {
+			values.Set(key, value)
+		}
+		rawQuery = values.Encode()
 	}
-	body, err := json.Marshal(queryObj)
+	body, err := QueryToJSON(rawQuery)
 	if err != nil {
 		return nil, err
 	}
-	splitsPath := strings.Split(path, "/")
-	if len(splitsPath) == 2 {
-		v, ok := specialEnpointMap[splitsPath[1]]
-		if !ok {
-			return nil, fmt.Errorf("path is not valid")
-		}
-		path = v
-	}
-	subject := buildNatsSubjectFromPath(path)
-
-	serviceName := strings.Split(subject, "/")[3]
 
-	urlString := host + path
-	headers := make(map[string][]string)
-	for key, values := range r.Header {
-		headers[key] = append(headers[key], values...)
-	}
+	urlString := host + target.Path
+	headers := copyClientHeaders(r.Header)
 	cookie := copyCookieFromHTTPRequest(r.Cookies())
 	if cookie != "" {
 		headers["Cookie"] = []string{copyCookieFromHTTPRequest(r.Cookies())}
 	}
+	headers[QueryBindingHeader] = []string{"1"}
 
 	return &Request{
 		Method:      "POST",
 		Body:        body,
 		Header:      headers,
-		Subject:     subject,
+		Subject:     target.Subject,
 		URL:         urlString,
-		ServiceName: serviceName,
+		ServiceName: target.ServiceName,
 	}, nil
 }
 
+// HttpRequestToNatsRequest converts r for the service found by DefaultTarget.
 func HttpRequestToNatsRequest(r http.Request) (*Request, error) {
+	target, err := DefaultTarget(r.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	return HttpRequestToNatsRequestWithTarget(r, target)
+}
+
+// HttpRequestToNatsRequestWithTarget converts r for target. A GET becomes a
+// POST whose body is built from the query string; path parameters of target
+// join the query string of a GET or the JSON object of any other body.
+func HttpRequestToNatsRequestWithTarget(r http.Request, target Target) (*Request, error) {
 	method := r.Method
-	headers := make(map[string][]string)
 	if method == "GET" {
-		return convertHttpGetRequestToNatsPostRequest(r)
+		return convertHttpGetRequestToNatsPostRequest(r, target)
 	}
-	urlObject := r.URL
-	host := urlObject.Host
-	path := urlObject.Path
-	subject := buildNatsSubjectFromPath(path)
-	serviceName := strings.Split(subject, "/")[3]
-	if path[0] != '/' {
+	host := r.URL.Host
+	path := target.Path
+	if !strings.HasPrefix(path, "/") {
 		path = "/" + path
 	}
 	urlString := host + path
@@ -151,6 +191,11 @@ func HttpRequestToNatsRequest(r http.Request) (*Request, error) {
 	bodyReader := r.Body
 	body, err := io.ReadAll(bodyReader)
 	if err != nil {
+		// the gateway bounds the body with http.MaxBytesReader
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, shared.NewPayloadTooLargeError(fmt.Sprintf("request body is larger than %d bytes", maxBytesErr.Limit))
+		}
 		return nil, err
 	}
 	err = bodyReader.Close()
@@ -158,25 +203,49 @@ func HttpRequestToNatsRequest(r http.Request) (*Request, error) {
 		return nil, err
 	}
 
-	for headerKey, values := range r.Header {
-		headers[headerKey] = append(headers[headerKey], values...)
-	}
-
+	headers := copyClientHeaders(r.Header)
 	cookie := copyCookieFromHTTPRequest(r.Cookies())
 	if cookie != "" {
 		headers["Cookie"] = []string{cookie}
 	}
 
+	if len(target.Params) > 0 {
+		body, err = mergePathParams(body, target.Params)
+		if err != nil {
+			return nil, err
+		}
+		// path parameters are strings, let the service coerce them like
+		// query parameters
+		headers[QueryBindingHeader] = []string{"1"}
+	}
+
 	return &Request{
 		Method:      method,
 		URL:         urlString,
 		Header:      headers,
 		Body:        body,
-		Subject:     subject,
-		ServiceName: serviceName,
+		Subject:     target.Subject,
+		ServiceName: target.ServiceName,
 	}, nil
 }
 
+func mergePathParams(body []byte, params map[string]string) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, shared.NewBadRequestError("request body must be a JSON object").WithCause(err)
+		}
+	}
+	for key, value := range params {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = raw
+	}
+	return json.Marshal(fields)
+}
+
 func NatsRequestToHttpRequest(rq *Request) (*http.Request, error) {
 	method := rq.Method
 	url := rq.URL
@@ -1,6 +1,10 @@
 package custom_nats
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+)
 
 type ResponseInterface interface {
 	Header() http.Header
@@ -18,6 +22,7 @@ type Response struct {
 	Status     string
 	Body       []byte
 	Headers    http.Header
+	Error      *shared.AppError `json:",omitempty"`
 }
 
 func NewResponse(statusCode int, headers http.Header, body []byte, status string) *Response {
@@ -52,6 +57,14 @@ func (res *Response) SetBody(body []byte) {
 	res.Body = body
 }
 
+func (res *Response) GetError() *shared.AppError {
+	return res.Error
+}
+
+func (res *Response) SetError(err *shared.AppError) {
+	res.Error = err
+}
+
 func (res *Response) GetStatus() string {
 	return res.Status
 }
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/validator"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
@@ -28,15 +29,40 @@ var (
 
 type Router struct {
 	http.Handler
-	chi chi.Router
+	chi              chi.Router
+	middlewares      []Middleware
+	routeMiddlewares map[string][]Middleware
+	hasRoutes        bool
 }
 
+// NewRouter creates a router with panic recovery installed as the outermost
+// middleware.
 func NewRouter(chi chi.Router) *Router {
 	return &Router{
-		chi: chi,
+		chi:              chi,
+		middlewares:      []Middleware{Recovery()},
+		routeMiddlewares: make(map[string][]Middleware),
 	}
 }
 
+// Use appends middlewares applied to every route. Like chi, middlewares must
+// be declared before the routes are registered.
+func (router *Router) Use(middlewares ...Middleware) {
+	if router.hasRoutes {
+		panic("custom_nats: all middlewares must be defined before routes on a router")
+	}
+	router.middlewares = append(router.middlewares, middlewares...)
+}
+
+// UseRoute appends middlewares for a single path. It lets services attach
+// route specific behaviour to handlers registered by generated code.
+func (router *Router) UseRoute(path string, middlewares ...Middleware) {
+	if router.hasRoutes {
+		panic("custom_nats: all middlewares must be defined before routes on a router")
+	}
+	router.routeMiddlewares[path] = append(router.routeMiddlewares[path], middlewares...)
+}
+
 func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	router.chi.ServeHTTP(w, r)
 }
@@ -60,8 +86,37 @@ func decode(data []byte, vPtr any) error {
 	return nil
 }
 
-func (router *Router) doRequest(r *http.Request, h Handler, ctx context.Context) (interface{}, error) {
-	// reflect h to a func in go --> call it
+// Endpoint is the compiled form of a registered handler. It receives the raw
+// request body and returns the handler result, so the per-request path never
+// has to inspect the handler signature.
+type Endpoint func(ctx context.Context, body []byte) (interface{}, error)
+
+// Handle registers a typed handler on the router. The handler signature is
+// checked by the compiler and the request body is decoded straight into Req,
+// then validated before the handler runs.
+func Handle[Req any, Res any](router *Router, method, path string, h func(ctx context.Context, req *Req) (Res, error), middlewares ...Middleware) {
+	router.register(method, path, middlewares, func(ctx context.Context, body []byte) (interface{}, error) {
+		if len(body) == 0 {
+			return nil, shared.NewBadRequestError("body is empty")
+		}
+		body, err := bindQueryBody(ctx, body, reflect.TypeFor[Req]())
+		if err != nil {
+			return nil, err
+		}
+		req := new(Req)
+		if err := decode(body, req); err != nil {
+			return nil, shared.NewBadRequestError("invalid request body").WithCause(err)
+		}
+		if err := validator.Validate(req); err != nil {
+			return nil, err
+		}
+		return h(ctx, req)
+	})
+}
+
+// reflectEndpoint validates a legacy interface{} handler once at registration
+// time and returns an endpoint that calls it through reflection.
+func reflectEndpoint(h Handler) (Endpoint, error) {
 	if h == nil {
 		return nil, errors.New("NATS: handler is required")
 	}
@@ -91,66 +146,75 @@ func (router *Router) doRequest(r *http.Request, h Handler, ctx context.Context)
 	}
 
 	reqType := handlerType.In(numIn - 1)
-	if reqType == nil {
-		return nil, errors.New("handler must be have on request parameter")
-	}
-	// responseType := handlerType.Out(0)
-
 	handlerValue := reflect.ValueOf(h)
 
-	input := []reflect.Value{reflect.ValueOf(ctx)}
-	bodyReader := r.Body
-	defer bodyReader.Close()
+	return func(ctx context.Context, body []byte) (interface{}, error) {
+		input := []reflect.Value{reflect.ValueOf(ctx)}
+		if numIn == 2 {
+			if len(body) == 0 {
+				return nil, shared.NewBadRequestError("body is empty")
+			}
 
-	body, err := io.ReadAll(bodyReader)
-	if err != nil {
-		return nil, err
-	}
-	if numIn == 2 {
-		if len(body) == 0 {
-			return nil, errors.New("body is empty")
-		}
+			var req reflect.Value
+			if reqType.Kind() != reflect.Pointer {
+				req = reflect.New(reqType)
+			} else {
+				req = reflect.New(reqType.Elem())
+			}
 
-		var req reflect.Value
-		if reqType.Kind() != reflect.Pointer {
-			req = reflect.New(reqType)
-		} else {
-			req = reflect.New(reqType.Elem())
+			body, err := bindQueryBody(ctx, body, req.Type())
+			if err != nil {
+				return nil, err
+			}
+			if err := decode(body, req.Interface()); err != nil {
+				return nil, shared.NewBadRequestError("invalid request body").WithCause(err)
+			}
+			if err := validator.Validate(req.Interface()); err != nil {
+				return nil, err
+			}
+			if reqType.Kind() != reflect.Pointer {
+				req = req.Elem()
+			}
+			input = append(input, req)
 		}
 
-		if err := decode(body, req.Interface()); err != nil {
-			return nil, err
-		}
-		input = append(input, req)
-	}
+		res := handlerValue.Call(input)
 
-	res := handlerValue.Call(input)
+		var errorReturn error
+		if numOut == 2 {
+			ret := res[0].Interface()
+			if v := res[1].Interface(); v != nil {
+				errorReturn = v.(error)
+			}
 
-	var errorReturn error
-	if numOut == 2 {
-		ret := res[0].Interface()
-		if v := res[1].Interface(); v != nil {
-			errorReturn = v.(error)
+			return ret, errorReturn
 		}
-
-		return ret, errorReturn
-	} else { // numout = 1
+		// numout = 1
 		if v := res[0].Interface(); v != nil {
-			errorReturn := v.(error)
-			return nil, errorReturn
+			return nil, v.(error)
 		}
-	}
-	return nil, nil
+		return nil, nil
+	}, nil
 }
 
-func (router *Router) handlerRequest(r *http.Request, h Handler, ctx context.Context) (*Response, error) {
-	returnValue, errFromAPI := router.doRequest(r, h, ctx)
+func (router *Router) handlerRequest(r *http.Request, e Endpoint, ctx context.Context) (*Response, error) {
+	bodyReader := r.Body
+	defer bodyReader.Close()
+
+	body, err := io.ReadAll(bodyReader)
+	if err != nil {
+		return nil, err
+	}
+
+	returnValue, errFromAPI := e(ctx, body)
 	responseBuilder := NewResponseBuilder(http.StatusOK).BuildHeader(r.Header)
 	if errFromAPI != nil {
 		return nil, errFromAPI
 	}
 
 	switch returnType := returnValue.(type) {
+	case streamed:
+		return nil, errResponseStreamed
 	case string:
 		return responseBuilder.BuildBody([]byte(returnValue.(string))).Build(), nil
 	case int, int16, int32, int64, int8, float32, float64, bool:
@@ -168,36 +232,80 @@ func (router *Router) handlerRequest(r *http.Request, h Handler, ctx context.Con
 	}
 }
 
-func (router *Router) RegisterRoute(method, path string, h Handler) {
+// RegisterRoute registers an untyped handler. The handler shape is validated
+// once here; a malformed handler is logged and answers every request with the
+// validation error.
+//
+// Deprecated: use Handle, which checks the handler signature at compile time.
+func (router *Router) RegisterRoute(method, path string, h Handler, middlewares ...Middleware) {
+	e, err := reflectEndpoint(h)
+	if err != nil {
+		log.Default().Printf("invalid handler for %s %s: %v", method, path, err)
+		e = func(ctx context.Context, body []byte) (interface{}, error) {
+			return nil, err
+		}
+	}
+	router.register(method, path, middlewares, e)
+}
+
+// writeError sends err as a typed AppError. When the request comes through a
+// NATS server, the error is also attached to the envelope response so the
+// gateway can read it without parsing the body.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := shared.ToAppError(err)
+	if errors.Is(err, context.DeadlineExceeded) && !errors.As(err, new(*shared.AppError)) {
+		// the handler ran out of the caller's budget
+		appErr = shared.NewGatewayTimeoutError("request deadline exceeded").WithCause(err)
+	}
+	if natsResponse, ok := r.Context().Value(shared.NatsResponse_ContextKey).(*Response); ok {
+		natsResponse.Error = appErr
+	}
+	w.Header().Set("Content-type", "application/json; charset=utf-8")
+	w.WriteHeader(appErr.StatusCode)
+	if _, err := w.Write(appErr.ToJSON()); err != nil {
+		log.Default().Println("fail to send err response json")
+	}
+}
+
+func (router *Router) register(method, path string, middlewares []Middleware, e Endpoint) {
+	router.hasRoutes = true
+	// global middlewares run first, then the ones of the path, then the ones
+	// given at registration
+	chain := append([]Middleware{}, router.middlewares...)
+	chain = append(chain, router.routeMiddlewares[path]...)
+	chain = append(chain, middlewares...)
+	e = chainMiddlewares(e, chain...)
+	route := RouteInfo{Method: method, Path: path}
+
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.WithValue(r.Context(), shared.HTTPRequest_ContextKey, r)
+		ctx = context.WithValue(ctx, shared.RouteInfo_ContextKey, route)
 		ctx = context.WithValue(ctx, shared.HTTPResponse_ContextKey, w)
-		// additional info to context
-		// We will build context here
-		// 1: Get from header
-		userId := r.Header.Get("X-User-Id")
-		if userId != "" {
-			ctx = context.WithValue(ctx, shared.UserId_ContextKey, userId)
+		// a NATS server brings its own stream, plain HTTP writes to w
+		stream := streamFromContext(ctx)
+		if stream == nil {
+			stream = newHttpStream(w)
+			ctx = context.WithValue(ctx, streamContextKey{}, stream)
 		}
+		// the user id only comes from the signed identity, which Authenticate
+		// verifies and puts in the context
 
-		res, err := router.handlerRequest(r, h, ctx)
-		if err != nil {
-			w.Header().Set("Content-type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-
-			respJson := map[string]string{
-				"error": err.Error(),
-			}
-			jsonByte, err := json.Marshal(respJson)
-			if err != nil {
-				log.Default().Println("fail to marshal response ", err)
-			}
-
-			if _, err := w.Write(jsonByte); err != nil {
-				log.Default().Println("fail to send err response json")
+		res, err := router.handlerRequest(r, e, ctx)
+		if errors.Is(err, errResponseStreamed) {
+			return
+		}
+		if stream.Started() {
+			// the head is already out, so the failure ends the stream with an
+			// error frame instead of a second response
+			if closeErr := stream.close(err); closeErr != nil {
+				log.Default().Printf("fail to close stream of %s %s: %v", method, path, closeErr)
 			}
 			return
 		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
 		if res == nil {
 			w.WriteHeader(http.StatusInternalServerError)
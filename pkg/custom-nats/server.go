@@ -1,19 +1,41 @@
 package custom_nats
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
 	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/tracing"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
 	"github.com/nats-io/nats.go"
+	"github.com/spf13/viper"
 )
 
 type ServerConfig struct {
 	ServiceName  string
 	OtelEndpoint string
+	// MaxInFlight bounds the messages handled at the same time
+	MaxInFlight int
+	// MaxPending bounds the messages waiting for a free worker, beyond it the
+	// server answers with an overloaded response
+	MaxPending int
+	// HealthAddr is where /readyz and /livez are served, empty disables them
+	HealthAddr string
+	// StreamChunkSize bounds the body bytes of one frame of a streamed
+	// response, it must stay below the nats max payload
+	StreamChunkSize int
+}
+
+type namedCloser struct {
+	name   string
+	closer io.Closer
 }
 
 type Server struct {
@@ -24,15 +46,34 @@ type Server struct {
 	subcriptions    *nats.Subscription
 	ServerConfig    *ServerConfig
 	shutdownTracing func()
+	workerPool      *WorkerPool
+	state           atomic.Int32
+	healthServer    *http.Server
+	closersMu       sync.Mutex
+	closers         []namedCloser
+	bodies          *BodyStore
 }
 
 func NewServer(natsConn *nats.Conn, router *Router, natsSubject string, client Client, serverConfig *ServerConfig) *Server {
+	if serverConfig.MaxInFlight <= 0 {
+		serverConfig.MaxInFlight = viper.GetInt(NatsServerMaxInFlightKey)
+	}
+	if serverConfig.MaxPending <= 0 {
+		serverConfig.MaxPending = viper.GetInt(NatsServerMaxPendingKey)
+	}
+	if serverConfig.HealthAddr == "" {
+		serverConfig.HealthAddr = viper.GetString(NatsServerHealthAddrKey)
+	}
+	if serverConfig.StreamChunkSize <= 0 {
+		serverConfig.StreamChunkSize = viper.GetInt(NatsServerStreamChunkSizeKey)
+	}
 	return &Server{
 		natsConn:     natsConn,
 		router:       router,
 		natsSubject:  natsSubject,
 		client:       client,
 		ServerConfig: serverConfig,
+		bodies:       LoadBodyStore(natsConn),
 		// shutdownTracing: shutdownTracing,
 	}
 }
@@ -45,36 +86,20 @@ func (s *Server) setShutdownTracing(shutdownTracing func()) {
 	s.shutdownTracing = shutdownTracing
 }
 func (s *Server) subcribeNats() (*nats.Subscription, error) {
+	// the nats callback only hands the message to the pool, so a slow handler
+	// never blocks the delivery of the next message
 	handler := func(msg *nats.Msg) {
-		var natsRequest Request
-		if err := json.Unmarshal(msg.Data, &natsRequest); err != nil {
-			logging.GetSugaredLogger().Errorf("fail to unmarshal nats request: %v", err)
-			return
-		}
-		request, err := NatsRequestToHttpRequest(&natsRequest)
+		receivedAt := time.Now()
+		// answer in the encoding the caller used
+		codec, err := EnvelopeCodecFromHeader(msg.Header)
 		if err != nil {
-			logging.GetSugaredLogger().Errorf("fail to change nats request to http request: %v", err)
+			logging.GetSugaredLogger().Errorf("fail to pick envelope codec: %v", err)
+			s.respond(msg, jsonEnvelopeCodec{}, errorResponse(shared.NewBadRequestError(err.Error())))
 			return
 		}
-		// instrument the request
-		ctx := tracing.ExtractTraceFromHttpRequest(request)
-
-		response := &Response{
-			Headers: http.Header{},
-		}
-		s.router.ServeHTTP(response, request.WithContext(ctx))
-
-		responseByte, err := json.Marshal(response)
-		if err != nil {
-			logging.GetSugaredLogger().Errorf("fail to marshal response: %v", err)
-			return
-		}
-		if msg.Reply != "" {
-			err := msg.Respond(responseByte)
-			if err != nil {
-				logging.GetSugaredLogger().Errorf("fail to respond message: %v", err)
-				return
-			}
+		if !s.workerPool.Submit(func() { s.handleMessage(msg, codec, receivedAt) }) {
+			logging.GetSugaredLogger().Warnf("service %s is overloaded, reject message on %s", s.ServerConfig.ServiceName, msg.Subject)
+			s.respond(msg, codec, overloadedResponse())
 		}
 	}
 	subcriptions, err := s.natsConn.QueueSubscribe(s.natsSubject, "workers", handler)
@@ -85,6 +110,137 @@ func (s *Server) subcribeNats() (*nats.Subscription, error) {
 	return subcriptions, nil
 }
 
+func (s *Server) handleMessage(msg *nats.Msg, codec EnvelopeCodec, receivedAt time.Time) {
+	var natsRequest Request
+	// respond skips messages without a reply subject, anything else gets an
+	// answer rather than leaving the caller to its timeout
+	if err := codec.UnmarshalRequest(msg.Data, &natsRequest); err != nil {
+		logging.GetSugaredLogger().Errorf("fail to unmarshal nats request: %v", err)
+		s.respond(msg, codec, errorResponse(shared.NewBadRequestError("invalid nats request").WithCause(err)))
+		return
+	}
+	if err := s.restoreBody(&natsRequest, receivedAt); err != nil {
+		logging.GetSugaredLogger().Errorf("%v", err)
+		s.respond(msg, codec, errorResponse(shared.NewServiceUnavailableError("request body is not available").WithCause(err)))
+		return
+	}
+	request, err := NatsRequestToHttpRequest(&natsRequest)
+	if err != nil {
+		logging.GetSugaredLogger().Errorf("fail to change nats request to http request: %v", err)
+		s.respond(msg, codec, errorResponse(shared.NewBadRequestError("invalid nats request").WithCause(err)))
+		return
+	}
+	// instrument the request
+	ctx := tracing.ExtractTraceFromHttpRequest(request)
+
+	if deadline, ok := requestDeadline(&natsRequest, receivedAt); ok {
+		if !time.Now().Before(deadline) {
+			// the caller has stopped waiting while the message was queued, a
+			// caller that retries still learns why
+			logging.GetSugaredLogger().Warnf("drop expired message %s %s", natsRequest.Method, natsRequest.URL)
+			s.respond(msg, codec, errorResponse(shared.NewGatewayTimeoutError("request deadline expired before it was handled")))
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	response := &Response{
+		Headers: http.Header{},
+	}
+	ctx = context.WithValue(ctx, shared.NatsResponse_ContextKey, response)
+	stream := newNatsStream(s.natsConn, msg, codec, s.ServerConfig.StreamChunkSize)
+	ctx = context.WithValue(ctx, streamContextKey{}, stream)
+	s.router.ServeHTTP(response, request.WithContext(ctx))
+
+	// a streaming handler has already answered with its frames
+	if stream.Started() {
+		return
+	}
+	s.respond(msg, codec, response)
+}
+
+// restoreBody fetches an offloaded body within the budget of the request.
+func (s *Server) restoreBody(natsRequest *Request, receivedAt time.Time) error {
+	if len(natsRequest.Header[BodyObjectHeader]) == 0 {
+		return nil
+	}
+	deadline, ok := requestDeadline(natsRequest, receivedAt)
+	if !ok {
+		deadline = receivedAt.Add(defaultBodyRestoreTimeout)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	return s.bodies.Restore(ctx, natsRequest)
+}
+
+func (s *Server) respond(msg *nats.Msg, codec EnvelopeCodec, response *Response) {
+	if msg.Reply == "" {
+		return
+	}
+	responseByte, err := codec.MarshalResponse(response)
+	if err != nil {
+		logging.GetSugaredLogger().Errorf("fail to marshal response: %v", err)
+		return
+	}
+	if err := msg.RespondMsg(NewEnvelopeMsg(msg.Reply, responseByte, codec)); err != nil {
+		logging.GetSugaredLogger().Errorf("fail to respond message: %v", err)
+	}
+}
+
+// requestDeadline turns the caller's remaining budget into a deadline counted
+// from the moment the message was received.
+func requestDeadline(natsRequest *Request, receivedAt time.Time) (time.Time, bool) {
+	values := natsRequest.Header[RequestTimeoutHeader]
+	if len(values) == 0 {
+		return time.Time{}, false
+	}
+	timeoutMs, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || timeoutMs <= 0 {
+		return time.Time{}, false
+	}
+	return receivedAt.Add(time.Duration(timeoutMs) * time.Millisecond), true
+}
+
+func overloadedResponse() *Response {
+	response := errorResponse(shared.NewServiceUnavailableError("service overloaded"))
+	response.Headers.Set("Retry-After", "1")
+	return response
+}
+
+func errorResponse(appErr *shared.AppError) *Response {
+	headers := http.Header{}
+	headers.Set(ContentType, ApplicationJsonContentType)
+	return &Response{
+		StatusCode: appErr.StatusCode,
+		Headers:    headers,
+		Body:       appErr.ToJSON(),
+		Error:      appErr,
+	}
+}
+
+// RegisterCloser hands a resource such as a database or a redis client to the
+// server. Closers run in reverse order of registration at the end of
+// Shutdown, once no handler can use them anymore.
+func (s *Server) RegisterCloser(name string, closer io.Closer) {
+	s.closersMu.Lock()
+	defer s.closersMu.Unlock()
+	s.closers = append(s.closers, namedCloser{name: name, closer: closer})
+}
+
+func (s *Server) State() ServerState {
+	return ServerState(s.state.Load())
+}
+
+func (s *Server) Ready() bool {
+	return s.State() == ServerReady
+}
+
+func (s *Server) setState(state ServerState) {
+	s.state.Store(int32(state))
+}
+
 func (s *Server) Start() error {
 
 	shutdownTracing, err := tracing.InitializeTraceRegistry(&tracing.TracingConfig{
@@ -100,22 +256,106 @@ func (s *Server) Start() error {
 		return err
 	}
 	s.setShutdownTracing(shutdownTracing)
+	s.startHealthServer()
 
-	s.client.Register(*s.router)
+	s.client.Register(s.router)
+	s.workerPool = NewWorkerPool(s.ServerConfig.MaxInFlight, s.ServerConfig.MaxPending)
 	// subcribe subject
 	_, err = s.subcribeNats()
 	if err != nil {
 		return err
 	}
+	s.setState(ServerReady)
 	return nil
 }
 
-func (s *Server) Stop() error {
-	err := s.subcriptions.Drain()
-	if err != nil {
+func (s *Server) startHealthServer() {
+	if s.ServerConfig.HealthAddr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/readyz", s.ReadinessHandler())
+	mux.Handle("/livez", LivenessHandler())
+	s.healthServer = &http.Server{
+		Addr:              s.ServerConfig.HealthAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := s.healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.GetSugaredLogger().Errorf("fail to serve health endpoints: %v", err)
+		}
+	}()
+}
+
+// Shutdown stops taking new messages and waits for the in-flight handlers
+// until ctx is done. Tracing is flushed and the registered closers run even
+// when the wait is cut short, the returned error reports what went wrong.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.setState(ServerDraining)
+	logger := logging.GetSugaredLogger()
+	var errs []error
+
+	if err := s.drainSubscription(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("fail to drain subcription: %w", err))
+	}
+	if s.workerPool != nil {
+		if err := s.workerPool.Shutdown(ctx); err != nil {
+			logger.Warnf("service %s stopped waiting for %d in-flight handlers", s.ServerConfig.ServiceName, s.workerPool.InFlight())
+			errs = append(errs, fmt.Errorf("fail to wait for in-flight handlers: %w", err))
+		}
+	}
+
+	if s.shutdownTracing != nil {
+		s.shutdownTracing()
+		logger.Infof("Tracing has shut down for service %s", s.ServerConfig.ServiceName)
+	}
+
+	s.closersMu.Lock()
+	closers := s.closers
+	s.closers = nil
+	s.closersMu.Unlock()
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].closer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("fail to close %s: %w", closers[i].name, err))
+			continue
+		}
+		logger.Infof("%s has closed for service %s", closers[i].name, s.ServerConfig.ServiceName)
+	}
+
+	s.setState(ServerStopped)
+	if s.healthServer != nil {
+		if err := s.healthServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("fail to shut down health server: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// drainSubscription unsubscribes and waits until the messages nats has
+// already delivered to the client are handed to the worker pool.
+func (s *Server) drainSubscription(ctx context.Context) error {
+	if s.subcriptions == nil {
+		return nil
+	}
+	if err := s.subcriptions.Drain(); err != nil {
 		return err
 	}
-	s.shutdownTracing()
-	logging.GetSugaredLogger().Infof("Tracing has shut down for service %s", s.ServerConfig.ServiceName)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for s.subcriptions.IsValid() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
 	return nil
 }
+
+// Stop shuts the server down without a deadline.
+//
+// Deprecated: use Shutdown so the wait for in-flight handlers is bounded.
+func (s *Server) Stop() error {
+	return s.Shutdown(context.Background())
+}
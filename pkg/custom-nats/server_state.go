@@ -0,0 +1,66 @@
+package custom_nats
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ServerState is the lifecycle stage of a Server.
+type ServerState int32
+
+const (
+	ServerStarting ServerState = iota
+	ServerReady
+	ServerDraining
+	ServerStopped
+)
+
+func (s ServerState) String() string {
+	switch s {
+	case ServerStarting:
+		return "starting"
+	case ServerReady:
+		return "ready"
+	case ServerDraining:
+		return "draining"
+	case ServerStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+type readinessResponse struct {
+	Service  string `json:"service"`
+	State    string `json:"state"`
+	InFlight int64  `json:"in_flight"`
+}
+
+// ReadinessHandler answers 200 while the server takes messages and 503 while
+// it is starting, draining or stopped, so an orchestrator stops routing to an
+// instance as soon as it begins shutting down.
+func (s *Server) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := s.State()
+		body := readinessResponse{
+			Service: s.ServerConfig.ServiceName,
+			State:   state.String(),
+		}
+		if s.workerPool != nil {
+			body.InFlight = s.workerPool.InFlight()
+		}
+		statusCode := http.StatusOK
+		if state != ServerReady {
+			statusCode = http.StatusServiceUnavailable
+		}
+		w.Header().Set(ContentType, ApplicationJsonContentType)
+		w.WriteHeader(statusCode)
+		_ = json.NewEncoder(w).Encode(body)
+	})
+}
+
+// LivenessHandler answers 200 as long as the process is able to serve HTTP.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,173 @@
+package custom_nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/circuitbreaker"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/tracing"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/nats-io/nats.go"
+	"github.com/sony/gobreaker/v2"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ServiceClient sends typed requests to another service over nats. It is the
+// runtime behind the <Service>Client types generated by proto2dgo.
+type ServiceClient struct {
+	natsConn    *nats.Conn
+	serviceName string
+	subject     string
+	timeout     time.Duration
+	codec       EnvelopeCodec
+}
+
+type ServiceClientOption func(c *ServiceClient)
+
+// WithClientTimeout bounds calls whose context has no deadline.
+func WithClientTimeout(timeout time.Duration) ServiceClientOption {
+	return func(c *ServiceClient) {
+		c.timeout = timeout
+	}
+}
+
+func WithClientEnvelopeCodec(codec EnvelopeCodec) ServiceClientOption {
+	return func(c *ServiceClient) {
+		c.codec = codec
+	}
+}
+
+func NewServiceClient(natsConn *nats.Conn, serviceName, subject string, opts ...ServiceClientOption) *ServiceClient {
+	client := &ServiceClient{
+		natsConn:    natsConn,
+		serviceName: serviceName,
+		subject:     subject,
+		timeout:     viper.GetDuration("service_registry.request_timeout"),
+		codec:       jsonEnvelopeCodec{},
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	if client.timeout <= 0 {
+		client.timeout = 30 * time.Second
+	}
+	return client
+}
+
+// Call sends req to the method at path and decodes the reply into Res. A
+// failure answered by the service comes back as the *shared.AppError it
+// returned; transport failures are mapped to 503 or 504 errors.
+func Call[Req any, Res any](ctx context.Context, c *ServiceClient, path string, req *Req) (*Res, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	ctx, span := tracing.SpanContext(ctx, http.Header{}, fmt.Sprintf("nats call: %s", path))
+	defer span.End()
+
+	res, err := call[Req, Res](ctx, c, path, req)
+	if err != nil {
+		if appErr := shared.ToAppError(err); appErr.StatusCode >= http.StatusInternalServerError {
+			tracing.SetSpanError(span, err)
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func call[Req any, Res any](ctx context.Context, c *ServiceClient, path string, req *Req) (*Res, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to marshal request of %s: %w", path, err)
+	}
+	natsReq := &Request{
+		Header:      map[string][]string{ContentType: {ApplicationJsonContentType}},
+		Method:      http.MethodPost,
+		Body:        body,
+		URL:         path,
+		Subject:     c.subject,
+		ServiceName: c.serviceName,
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(natsReq.Header))
+	// the callee trusts the identity the gateway signed for the caller
+	if signed, ok := identity.SignedFromContext(ctx); ok {
+		natsReq.SetHeader(identity.Header, signed)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		natsReq.SetHeader(RequestTimeoutHeader, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+	data, err := c.codec.MarshalRequest(natsReq)
+	if err != nil {
+		return nil, fmt.Errorf("fail to marshal nats request: %w", err)
+	}
+
+	breaker, err := circuitbreaker.GetRegistry[*nats.Msg]().GetOrCreateBreaker(c.serviceName, circuitbreaker.ToCircuitBreakerConfig(c.serviceName, configs.LoadNatsCircuitBreakerConfigByServiceName(c.serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get or create a circuit breaker: %w", err)
+	}
+	msg, err := NewNatsConnWithCircuitBreaker(c.natsConn, breaker).SendRequest(ctx, &NatsSendRequest{
+		Subject: c.subject,
+		Content: data,
+		Header:  EnvelopeHeader(c.codec),
+	})
+	if err != nil {
+		return nil, TransportError(c.serviceName, err)
+	}
+
+	var response Response
+	codec, err := EnvelopeCodecFromHeader(msg.Header)
+	if err == nil {
+		err = codec.UnmarshalResponse(msg.Data, &response)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode response of %s: %w", path, err)
+	}
+	return decodeServiceResponse[Res](&response)
+}
+
+func decodeServiceResponse[Res any](response *Response) (*Res, error) {
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		appErr := &shared.AppError{}
+		if err := json.Unmarshal(response.Body, appErr); err != nil || appErr.StatusCode == 0 {
+			appErr = shared.NewAppError(shared.ErrorTypeFromStatusCode(response.StatusCode), string(response.Body))
+			appErr.StatusCode = response.StatusCode
+		}
+		return nil, appErr
+	}
+	res := new(Res)
+	if len(response.Body) == 0 {
+		return res, nil
+	}
+	if err := decode(response.Body, res); err != nil {
+		return nil, fmt.Errorf("fail to decode response body: %w", err)
+	}
+	return res, nil
+}
+
+// TransportError maps a failure to reach serviceName to a typed AppError:
+// 504 when it did not answer in time, 503 when no instance is listening or
+// its circuit breaker is open. Other errors are returned as they are.
+func TransportError(serviceName string, err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, nats.ErrTimeout):
+		return shared.NewGatewayTimeoutError(fmt.Sprintf("service %s did not answer in time", serviceName)).WithCause(err)
+	case errors.Is(err, nats.ErrNoResponders):
+		return shared.NewServiceUnavailableError(fmt.Sprintf("service %s is not available", serviceName)).WithCause(err)
+	case errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		return shared.NewServiceUnavailableError(fmt.Sprintf("circuit breaker of service %s is open", serviceName)).WithCause(err)
+	}
+	return err
+}
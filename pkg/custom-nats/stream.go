@@ -0,0 +1,240 @@
+package custom_nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/validator"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/nats-io/nats.go"
+)
+
+// A streamed response is a sequence of frames published to the reply inbox of
+// the request. The head frame carries the status and headers as a Response
+// without body, chunk frames carry the body in order and the stream ends with
+// exactly one end or error frame. A reply without StreamFrameHeader is a
+// plain single message Response.
+const (
+	StreamFrameHeader = "Nats-Stream-Frame"
+	StreamSeqHeader   = "Nats-Stream-Seq"
+
+	StreamFrameHead  = "head"
+	StreamFrameChunk = "chunk"
+	StreamFrameEnd   = "end"
+	StreamFrameError = "error"
+
+	defaultStreamChunkSize = 64 * 1024
+)
+
+type streamContextKey struct{}
+
+// frameSender delivers one frame of a stream to the caller.
+type frameSender func(kind string, seq int, data []byte) error
+
+// Stream writes a response in chunks. The status and headers are sent with
+// the first Write, or when the handler returns without writing.
+type Stream struct {
+	mu         sync.Mutex
+	headers    http.Header
+	statusCode int
+	chunkSize  int
+	seq        int
+	started    bool
+	closed     bool
+	send       frameSender
+	sendHead   func(statusCode int, headers http.Header) error
+}
+
+func newStream(chunkSize int, sendHead func(statusCode int, headers http.Header) error, send frameSender) *Stream {
+	if chunkSize <= 0 {
+		chunkSize = defaultStreamChunkSize
+	}
+	return &Stream{
+		headers:    http.Header{},
+		statusCode: http.StatusOK,
+		chunkSize:  chunkSize,
+		send:       send,
+		sendHead:   sendHead,
+	}
+}
+
+// newNatsStream publishes the frames to the reply subject of msg, the head
+// frame being encoded with codec like a plain Response.
+func newNatsStream(natsConn *nats.Conn, msg *nats.Msg, codec EnvelopeCodec, chunkSize int) *Stream {
+	publish := func(kind string, seq int, data []byte) error {
+		if msg.Reply == "" {
+			return nil
+		}
+		frame := NewEnvelopeMsg(msg.Reply, data, codec)
+		frame.Header.Set(StreamFrameHeader, kind)
+		frame.Header.Set(StreamSeqHeader, strconv.Itoa(seq))
+		return natsConn.PublishMsg(frame)
+	}
+	var stream *Stream
+	stream = newStream(chunkSize, func(statusCode int, headers http.Header) error {
+		head, err := codec.MarshalResponse(&Response{StatusCode: statusCode, Headers: headers})
+		if err != nil {
+			return fmt.Errorf("fail to marshal stream head: %w", err)
+		}
+		return publish(StreamFrameHead, stream.nextSeq(), head)
+	}, publish)
+	return stream
+}
+
+// newHttpStream writes the frames straight to an http.ResponseWriter, so a
+// streaming handler also works when the router is served over plain HTTP.
+func newHttpStream(w http.ResponseWriter) *Stream {
+	controller := http.NewResponseController(w)
+	return newStream(0, func(statusCode int, headers http.Header) error {
+		for key, values := range headers {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+		w.WriteHeader(statusCode)
+		return nil
+	}, func(kind string, seq int, data []byte) error {
+		if kind == StreamFrameError {
+			// plain HTTP has no error frame once the status is out, so cut the
+			// connection like the gateway does rather than end the body cleanly
+			panic(http.ErrAbortHandler)
+		}
+		if kind != StreamFrameChunk {
+			return nil
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	})
+}
+
+func streamFromContext(ctx context.Context) *Stream {
+	stream, _ := ctx.Value(streamContextKey{}).(*Stream)
+	return stream
+}
+
+func (s *Stream) nextSeq() int {
+	seq := s.seq
+	s.seq++
+	return seq
+}
+
+func (s *Stream) Header() http.Header {
+	return s.headers
+}
+
+// WriteHeader sets the status sent with the head frame. It has no effect once
+// the stream has started.
+func (s *Stream) WriteHeader(statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		s.statusCode = statusCode
+	}
+}
+
+// Write sends p as one or more chunk frames.
+func (s *Stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, errors.New("stream is closed")
+	}
+	if err := s.start(); err != nil {
+		return 0, err
+	}
+	written := 0
+	for written < len(p) {
+		end := min(written+s.chunkSize, len(p))
+		if err := s.send(StreamFrameChunk, s.nextSeq(), p[written:end]); err != nil {
+			return written, fmt.Errorf("fail to send stream chunk: %w", err)
+		}
+		written = end
+	}
+	return written, nil
+}
+
+func (s *Stream) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return s.sendHead(s.statusCode, s.headers)
+}
+
+// Started reports whether the head frame was sent. Until then a failing
+// handler can still answer with a plain error response.
+func (s *Stream) Started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
+}
+
+// close ends the stream with an end frame, or an error frame carrying the
+// AppError as JSON when err is not nil.
+func (s *Stream) close(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if startErr := s.start(); startErr != nil {
+		return startErr
+	}
+	if err != nil {
+		return s.send(StreamFrameError, s.nextSeq(), shared.ToAppError(err).ToJSON())
+	}
+	return s.send(StreamFrameEnd, s.nextSeq(), nil)
+}
+
+// streamed is returned by a streaming endpoint once its frames are sent, so
+// the router does not write a second response.
+type streamed struct{}
+
+var errResponseStreamed = errors.New("response is streamed")
+
+// HandleStream registers a handler that writes its response through a Stream.
+// An error returned before anything was written becomes a plain error
+// response; after that the router ends the stream with an error frame, which
+// also covers a panic turned into an error by Recovery.
+func HandleStream[Req any](router *Router, method, path string, h func(ctx context.Context, req *Req, stream *Stream) error, middlewares ...Middleware) {
+	router.register(method, path, middlewares, func(ctx context.Context, body []byte) (interface{}, error) {
+		req := new(Req)
+		if len(body) > 0 {
+			body, err := bindQueryBody(ctx, body, reflect.TypeFor[Req]())
+			if err != nil {
+				return nil, err
+			}
+			if err := decode(body, req); err != nil {
+				return nil, shared.NewBadRequestError("invalid request body").WithCause(err)
+			}
+		}
+		if err := validator.Validate(req); err != nil {
+			return nil, err
+		}
+
+		stream := streamFromContext(ctx)
+		if stream == nil {
+			return nil, errors.New("streaming response needs a stream")
+		}
+
+		if err := h(ctx, req, stream); err != nil {
+			return nil, err
+		}
+		if closeErr := stream.close(nil); closeErr != nil {
+			logging.GetSugaredLogger().Errorf("fail to close stream of %s %s: %v", method, path, closeErr)
+		}
+		return streamed{}, nil
+	})
+}
@@ -0,0 +1,29 @@
+package custom_nats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BodyStore(t *testing.T) {
+	// no nats connection: these cases must not reach JetStream
+	bodies := custom_nats.NewBodyStore(nil, "request_bodies", time.Minute)
+
+	t.Run("Test_Small_Body_Stays_Inline", func(t *testing.T) {
+		req := &custom_nats.Request{Body: []byte(`{"status":"paid"}`)}
+		name, err := bodies.Offload(context.Background(), req, 1024*1024)
+		require.NoError(t, err)
+		require.Empty(t, name)
+		require.Equal(t, `{"status":"paid"}`, string(req.Body))
+	})
+
+	t.Run("Test_Restore_Inline_Body", func(t *testing.T) {
+		req := &custom_nats.Request{Body: []byte(`{}`), Header: map[string][]string{}}
+		require.NoError(t, bodies.Restore(context.Background(), req))
+		require.Equal(t, `{}`, string(req.Body))
+	})
+}
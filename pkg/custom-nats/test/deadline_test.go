@@ -0,0 +1,40 @@
+package custom_nats_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/stretchr/testify/require"
+)
+
+type waitRequest struct{}
+
+func Test_Handler_Deadline(t *testing.T) {
+	router := custom_nats.NewRouter(chi.NewRouter())
+	cancelled := make(chan struct{})
+	custom_nats.Handle(router, "POST", "/api/v1/test/Wait", func(ctx context.Context, req *waitRequest) (*waitRequest, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("POST", "/api/v1/test/Wait", strings.NewReader(`{}`)).WithContext(ctx)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	require.Equal(t, http.StatusGatewayTimeout, res.Code)
+	require.Contains(t, res.Body.String(), "request deadline exceeded")
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("handler was not cancelled")
+	}
+}
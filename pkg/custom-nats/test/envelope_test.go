@@ -0,0 +1,96 @@
+package custom_nats_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EnvelopeCodec(t *testing.T) {
+	request := &custom_nats.Request{
+		Header: map[string][]string{
+			"Content-Type": {"application/json"},
+			"Accept":       {"text/html", "application/json"},
+		},
+		Method:      "POST",
+		Body:        bytes.Repeat([]byte{0xff, 0x00}, 512),
+		URL:         "http://localhost:8080/api/v1/order/GetOrderById",
+		Subject:     "/api/v1/order/GetOrderById",
+		ServiceName: "order",
+	}
+	response := &custom_nats.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       []byte(`{"error":"order not found"}`),
+		Headers:    http.Header{"Content-Type": {"application/json"}},
+		Error:      shared.NewNotFoundError("order not found").WithDetails(map[string]any{"id": "42"}),
+	}
+
+	for _, name := range []string{custom_nats.EnvelopeJSON, custom_nats.EnvelopeProto} {
+		t.Run("Test_Round_Trip_"+name, func(t *testing.T) {
+			codec, err := custom_nats.GetEnvelopeCodec(name)
+			require.NoError(t, err)
+
+			data, err := codec.MarshalRequest(request)
+			require.NoError(t, err)
+			var decodedRequest custom_nats.Request
+			require.NoError(t, codec.UnmarshalRequest(data, &decodedRequest))
+			require.Equal(t, *request, decodedRequest)
+
+			data, err = codec.MarshalResponse(response)
+			require.NoError(t, err)
+			var decodedResponse custom_nats.Response
+			require.NoError(t, codec.UnmarshalResponse(data, &decodedResponse))
+			require.Equal(t, response.StatusCode, decodedResponse.StatusCode)
+			require.Equal(t, response.Body, decodedResponse.Body)
+			require.Equal(t, response.Headers, decodedResponse.Headers)
+			require.Equal(t, response.Error.Code, decodedResponse.Error.Code)
+			require.Equal(t, response.Error.Message, decodedResponse.Error.Message)
+			require.Equal(t, "42", decodedResponse.Error.Details["id"])
+		})
+	}
+
+	t.Run("Test_Proto_Is_Smaller_For_Binary_Body", func(t *testing.T) {
+		jsonCodec, _ := custom_nats.GetEnvelopeCodec(custom_nats.EnvelopeJSON)
+		protoCodec, _ := custom_nats.GetEnvelopeCodec(custom_nats.EnvelopeProto)
+		jsonData, err := jsonCodec.MarshalRequest(request)
+		require.NoError(t, err)
+		protoData, err := protoCodec.MarshalRequest(request)
+		require.NoError(t, err)
+		require.Less(t, len(protoData), len(jsonData))
+	})
+
+	t.Run("Test_Malformed_Proto", func(t *testing.T) {
+		codec, _ := custom_nats.GetEnvelopeCodec(custom_nats.EnvelopeProto)
+		var decoded custom_nats.Request
+		require.Error(t, codec.UnmarshalRequest([]byte{0x12, 0x10, 'P'}, &decoded))
+	})
+}
+
+func Test_EnvelopeNegotiation(t *testing.T) {
+	t.Run("Test_Missing_Header_Is_JSON", func(t *testing.T) {
+		codec, err := custom_nats.EnvelopeCodecFromHeader(nil)
+		require.NoError(t, err)
+		require.Equal(t, custom_nats.EnvelopeJSON, codec.Name())
+		require.Empty(t, custom_nats.EnvelopeHeader(codec))
+	})
+
+	t.Run("Test_Header_Selects_Proto", func(t *testing.T) {
+		protoCodec, _ := custom_nats.GetEnvelopeCodec(custom_nats.EnvelopeProto)
+		msg := custom_nats.NewEnvelopeMsg("subject", nil, protoCodec)
+		codec, err := custom_nats.EnvelopeCodecFromHeader(msg.Header)
+		require.NoError(t, err)
+		require.Equal(t, custom_nats.EnvelopeProto, codec.Name())
+	})
+
+	t.Run("Test_Unknown_Encoding", func(t *testing.T) {
+		header := nats.Header{}
+		header.Set(custom_nats.EnvelopeEncodingHeader, "xml")
+		_, err := custom_nats.EnvelopeCodecFromHeader(header)
+		require.Error(t, err)
+	})
+}
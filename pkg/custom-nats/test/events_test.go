@@ -0,0 +1,19 @@
+package custom_nats_test
+
+import (
+	"testing"
+
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_UserEventSubject(t *testing.T) {
+	subject, err := custom_nats.UserEventSubject("user-1", "order.status")
+	require.NoError(t, err)
+	require.Equal(t, "events.user.user-1.order.status", subject)
+
+	for _, c := range [][2]string{{"user.1", "order"}, {"*", "order"}, {"user-1", "order.>"}, {"user-1", ""}, {"", "order"}} {
+		_, err := custom_nats.UserEventSubject(c[0], c[1])
+		require.Error(t, err, c)
+	}
+}
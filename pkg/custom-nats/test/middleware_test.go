@@ -0,0 +1,128 @@
+package custom_nats_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/stretchr/testify/require"
+)
+
+func recordMiddleware(order *[]string, name string) custom_nats.Middleware {
+	return func(next custom_nats.Endpoint) custom_nats.Endpoint {
+		return func(ctx context.Context, body []byte) (interface{}, error) {
+			*order = append(*order, "before-"+name)
+			res, err := next(ctx, body)
+			*order = append(*order, "after-"+name)
+			return res, err
+		}
+	}
+}
+
+func Test_Router_Middlewares(t *testing.T) {
+	t.Run("Test_Global_Route_And_Registration_Order", func(t *testing.T) {
+		order := []string{}
+		router := custom_nats.NewRouter(chi.NewRouter())
+		router.Use(recordMiddleware(&order, "global"))
+		router.UseRoute("/api/v1/test/Echo", recordMiddleware(&order, "route"))
+		custom_nats.Handle(router, "POST", "/api/v1/test/Echo", func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+			route, ok := custom_nats.RouteInfoFromContext(ctx)
+			require.True(t, ok)
+			require.Equal(t, "/api/v1/test/Echo", route.Path)
+			order = append(order, "handler")
+			return &echoResponse{}, nil
+		}, recordMiddleware(&order, "handle"))
+
+		req := httptest.NewRequest("POST", "/api/v1/test/Echo", strings.NewReader(`{}`))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, "before-global.before-route.before-handle.handler.after-handle.after-route.after-global", strings.Join(order, "."))
+	})
+
+	t.Run("Test_Use_After_Routes_Panics", func(t *testing.T) {
+		router := custom_nats.NewRouter(chi.NewRouter())
+		custom_nats.Handle(router, "POST", "/api/v1/test/Echo", func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+			return &echoResponse{}, nil
+		})
+		require.Panics(t, func() {
+			router.Use(custom_nats.Logging())
+		})
+	})
+}
+
+func Test_Recovery(t *testing.T) {
+	router := custom_nats.NewRouter(chi.NewRouter())
+	custom_nats.Handle(router, "POST", "/api/v1/test/Panic", func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+		panic("handler exploded")
+	})
+
+	req := httptest.NewRequest("POST", "/api/v1/test/Panic", strings.NewReader(`{}`))
+	res := httptest.NewRecorder()
+	require.NotPanics(t, func() {
+		router.ServeHTTP(res, req)
+	})
+	require.Equal(t, http.StatusInternalServerError, res.Code)
+	require.Contains(t, res.Body.String(), "internal server error")
+}
+
+func Test_Timeout(t *testing.T) {
+	router := custom_nats.NewRouter(chi.NewRouter())
+	custom_nats.Handle(router, "POST", "/api/v1/test/Slow", func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, custom_nats.Timeout(10*time.Millisecond))
+
+	req := httptest.NewRequest("POST", "/api/v1/test/Slow", strings.NewReader(`{}`))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusGatewayTimeout, res.Code)
+}
+
+func Test_Authenticate(t *testing.T) {
+	signer, err := identity.NewSigner("test-signing-key", time.Minute)
+	require.NoError(t, err)
+	router := custom_nats.NewRouter(chi.NewRouter())
+	custom_nats.Handle(router, "POST", "/api/v1/test/Me", func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+		id, ok := identity.FromContext(ctx)
+		require.True(t, ok)
+		require.Equal(t, "user-1", ctx.Value(shared.UserId_ContextKey))
+		return &echoResponse{Greeting: id.UserId}, nil
+	}, custom_nats.Authenticate(signer))
+
+	t.Run("Test_Signed_Identity", func(t *testing.T) {
+		signed, err := signer.Sign(identity.Identity{UserId: "user-1"})
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/api/v1/test/Me", strings.NewReader(`{}`))
+		req.Header.Set(identity.Header, signed)
+		// a plain user id header does not override the signed identity
+		req.Header.Set(identity.UserIdHeader, "someone-else")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Contains(t, res.Body.String(), "user-1")
+	})
+
+	t.Run("Test_Missing_Or_Forged_Identity", func(t *testing.T) {
+		forger, err := identity.NewSigner("another-key", time.Minute)
+		require.NoError(t, err)
+		forged, err := forger.Sign(identity.Identity{UserId: "user-1"})
+		require.NoError(t, err)
+		for _, header := range []string{"", forged} {
+			req := httptest.NewRequest("POST", "/api/v1/test/Me", strings.NewReader(`{}`))
+			if header != "" {
+				req.Header.Set(identity.Header, header)
+			}
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, req)
+			require.Equal(t, http.StatusUnauthorized, res.Code)
+		}
+	})
+}
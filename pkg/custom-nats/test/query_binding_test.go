@@ -0,0 +1,173 @@
+package custom_nats_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func Test_QueryToJSON(t *testing.T) {
+	t.Run("Test_Decode_Repeat_And_Nest", func(t *testing.T) {
+		body, err := custom_nats.QueryToJSON("name=caf%C3%A9+latte&tag=a&tag=b&ids[]=1&filter.status=paid&filter[page][size]=20&flag")
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"name": "café latte",
+			"tag": ["a", "b"],
+			"ids": ["1"],
+			"filter": {"status": "paid", "page": {"size": "20"}},
+			"flag": ""
+		}`, string(body))
+	})
+
+	t.Run("Test_Empty_Query", func(t *testing.T) {
+		body, err := custom_nats.QueryToJSON("")
+		require.NoError(t, err)
+		require.JSONEq(t, `{}`, string(body))
+	})
+
+	for _, query := range []string{"name=%zz", "filter=1&filter.status=paid", "filter[status=paid", ".status=paid", "a..b=1"} {
+		t.Run("Test_Malformed_"+query, func(t *testing.T) {
+			_, err := custom_nats.QueryToJSON(query)
+			var appErr *shared.AppError
+			require.True(t, errors.As(err, &appErr))
+			require.Equal(t, http.StatusBadRequest, appErr.StatusCode)
+		})
+	}
+}
+
+func Test_HttpGetRequestToNatsRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/v1/order/GetOrderById?id=42", nil)
+	natsReq, err := custom_nats.HttpRequestToNatsRequest(*req)
+	require.NoError(t, err)
+	require.Equal(t, "POST", natsReq.Method)
+	require.Equal(t, "order", natsReq.ServiceName)
+	require.JSONEq(t, `{"id":"42"}`, string(natsReq.Body))
+	require.NotEmpty(t, natsReq.Header[custom_nats.QueryBindingHeader])
+}
+
+type searchFilter struct {
+	Status   string `json:"status,omitempty"`
+	MinTotal int64  `json:"min_total,omitempty"`
+}
+
+type searchRequest struct {
+	Query     string             `json:"Query,omitempty"`
+	Page      int32              `json:"page,omitempty"`
+	Paid      bool               `json:"paid,omitempty"`
+	Ratio     float64            `json:"ratio,omitempty"`
+	Tags      []string           `json:"tags,omitempty"`
+	Ids       []int64            `json:"ids,omitempty"`
+	Filter    *searchFilter      `json:"filter,omitempty"`
+	NullValue structpb.NullValue `json:"NullValue,omitempty"`
+	Labels    map[string]uint32  `json:"labels,omitempty"`
+}
+
+func Test_QueryBinding(t *testing.T) {
+	router := custom_nats.NewRouter(chi.NewRouter())
+	custom_nats.Handle(router, "POST", "/api/v1/test/Search", func(ctx context.Context, req *searchRequest) (*searchRequest, error) {
+		return req, nil
+	})
+	serve := func(query string) *httptest.ResponseRecorder {
+		body, err := custom_nats.QueryToJSON(query)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/api/v1/test/Search", strings.NewReader(string(body)))
+		req.Header.Set(custom_nats.QueryBindingHeader, "1")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("Test_Coerce_To_Message_Types", func(t *testing.T) {
+		res := serve("query=shoes&page=2&paid=true&ratio=0.5&tags=red&ids=1&ids=2&filter.status=paid&filter.min_total=100&null_value=NULL_VALUE&labels[a]=7")
+		require.Equal(t, http.StatusOK, res.Code)
+		require.JSONEq(t, `{
+			"Query": "shoes",
+			"page": 2,
+			"paid": true,
+			"ratio": 0.5,
+			"tags": ["red"],
+			"ids": [1, 2],
+			"filter": {"status": "paid", "min_total": 100},
+			"labels": {"a": 7}
+		}`, res.Body.String())
+	})
+
+	for _, query := range []string{"page=two", "paid=maybe", "page=1&page=2", "null_value=SOMETHING", "filter=paid"} {
+		t.Run("Test_Reject_"+query, func(t *testing.T) {
+			res := serve(query)
+			require.Equal(t, http.StatusBadRequest, res.Code)
+		})
+	}
+}
+
+func Test_HttpRequestToNatsRequestWithTarget(t *testing.T) {
+	target := custom_nats.Target{
+		ServiceName: "order",
+		Subject:     "/api/v1/order",
+		Path:        "/api/v1/order/UpdateOrder",
+		Params:      map[string]string{"id": "42"},
+	}
+
+	t.Run("Test_Merge_Params_Into_Body", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/api/v2/orders/42", strings.NewReader(`{"status":"paid","id":"7"}`))
+		natsReq, err := custom_nats.HttpRequestToNatsRequestWithTarget(*req, target)
+		require.NoError(t, err)
+		require.Equal(t, "/api/v1/order", natsReq.Subject)
+		require.Equal(t, "/api/v1/order/UpdateOrder", natsReq.URL)
+		require.JSONEq(t, `{"status":"paid","id":"42"}`, string(natsReq.Body))
+	})
+
+	t.Run("Test_Merge_Params_Into_Query", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v2/orders/42?expand=items", nil)
+		natsReq, err := custom_nats.HttpRequestToNatsRequestWithTarget(*req, target)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"id":"42","expand":"items"}`, string(natsReq.Body))
+	})
+
+	t.Run("Test_Reject_Non_Object_Body", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/api/v2/orders/42", strings.NewReader(`[1,2]`))
+		_, err := custom_nats.HttpRequestToNatsRequestWithTarget(*req, target)
+		var appErr *shared.AppError
+		require.True(t, errors.As(err, &appErr))
+		require.Equal(t, http.StatusBadRequest, appErr.StatusCode)
+	})
+
+	t.Run("Test_Drop_Internal_Headers", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/order/CreateOrder", strings.NewReader(`{}`))
+		req.Header.Set(custom_nats.BodyObjectHeader, "someone-else-body")
+		req.Header.Set(custom_nats.RequestTimeoutHeader, "600000")
+		req.Header.Set(custom_nats.QueryBindingHeader, "1")
+		req.Header.Set(identity.UserIdHeader, "someone-else")
+		req.Header.Set("X-Trace", "abc")
+		natsReq, err := custom_nats.HttpRequestToNatsRequestWithTarget(*req, custom_nats.Target{
+			ServiceName: "order",
+			Subject:     "/api/v1/order",
+			Path:        "/api/v1/order/CreateOrder",
+		})
+		require.NoError(t, err)
+		require.NotContains(t, natsReq.Header, custom_nats.BodyObjectHeader)
+		require.NotContains(t, natsReq.Header, custom_nats.RequestTimeoutHeader)
+		require.NotContains(t, natsReq.Header, custom_nats.QueryBindingHeader)
+		require.NotContains(t, natsReq.Header, identity.UserIdHeader)
+		require.Equal(t, []string{"abc"}, natsReq.Header["X-Trace"])
+	})
+
+	t.Run("Test_Body_Over_Limit", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/api/v2/orders/42", strings.NewReader(`{"status":"paid"}`))
+		req.Body = http.MaxBytesReader(httptest.NewRecorder(), req.Body, 8)
+		_, err := custom_nats.HttpRequestToNatsRequestWithTarget(*req, target)
+		var appErr *shared.AppError
+		require.True(t, errors.As(err, &appErr))
+		require.Equal(t, http.StatusRequestEntityTooLarge, appErr.StatusCode)
+	})
+}
@@ -0,0 +1,122 @@
+package custom_nats_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/stretchr/testify/require"
+)
+
+type echoRequest struct {
+	Name string `json:"name" validate:"max=16"`
+}
+
+type echoResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func Test_Handle(t *testing.T) {
+	router := custom_nats.NewRouter(chi.NewRouter())
+	custom_nats.Handle(router, "POST", "/api/v1/test/Echo", func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+		return &echoResponse{Greeting: "hello " + req.Name}, nil
+	})
+	custom_nats.Handle(router, "POST", "/api/v1/test/Fail", func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+		return nil, errors.New("boom")
+	})
+	custom_nats.Handle(router, "POST", "/api/v1/test/Who", func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+		userId, _ := ctx.Value(shared.UserId_ContextKey).(string)
+		return &echoResponse{Greeting: "hello " + userId}, nil
+	})
+
+	t.Run("Test_Decode_Request_And_Encode_Response", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Echo", strings.NewReader(`{"name":"nats"}`))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code)
+		require.JSONEq(t, `{"greeting":"hello nats"}`, res.Body.String())
+	})
+
+	t.Run("Test_Validation_Failure", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Echo", strings.NewReader(`{"name":"a name that is far too long"}`))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusUnprocessableEntity, res.Code)
+		require.Contains(t, res.Body.String(), `"field":"name"`)
+	})
+
+	t.Run("Test_Malformed_Body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Echo", strings.NewReader(`{"name":`))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusBadRequest, res.Code)
+	})
+
+	t.Run("Test_Empty_Body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Echo", nil)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Contains(t, res.Body.String(), "body is empty")
+	})
+
+	t.Run("Test_Handler_Error", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Fail", strings.NewReader(`{}`))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusInternalServerError, res.Code)
+		require.Contains(t, res.Body.String(), "internal server error")
+		require.NotContains(t, res.Body.String(), "boom", "untyped errors stay out of the response")
+	})
+
+	t.Run("Test_Unsigned_User_Id_Ignored", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Who", strings.NewReader(`{}`))
+		req.Header.Set("X-User-Id", "admin")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code)
+		require.JSONEq(t, `{"greeting":"hello "}`, res.Body.String())
+	})
+}
+
+func Test_Handle_AppError(t *testing.T) {
+	router := custom_nats.NewRouter(chi.NewRouter())
+	custom_nats.Handle(router, "POST", "/api/v1/test/Missing", func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+		return nil, shared.NewNotFoundError("echo not found").WithDetails(map[string]any{"name": req.Name})
+	})
+
+	t.Run("Test_Status_Code_From_AppError", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Missing", strings.NewReader(`{"name":"nats"}`))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusNotFound, res.Code)
+		require.JSONEq(t, `{"code":"Not_Found","status_code":404,"error":"echo not found","details":{"name":"nats"},"retryable":false}`, res.Body.String())
+	})
+
+	t.Run("Test_AppError_Attached_To_Nats_Response", func(t *testing.T) {
+		natsResponse := &custom_nats.Response{Headers: http.Header{}}
+		req := httptest.NewRequest("POST", "/api/v1/test/Missing", strings.NewReader(`{"name":"nats"}`))
+		req = req.WithContext(context.WithValue(req.Context(), shared.NatsResponse_ContextKey, natsResponse))
+		router.ServeHTTP(natsResponse, req)
+		require.NotNil(t, natsResponse.Error)
+		require.Equal(t, shared.Not_Found, natsResponse.Error.Code)
+		require.Equal(t, http.StatusNotFound, natsResponse.StatusCode)
+	})
+}
+
+func Test_RegisterRoute_Invalid_Handler(t *testing.T) {
+	router := custom_nats.NewRouter(chi.NewRouter())
+	router.RegisterRoute("POST", "/api/v1/test/Invalid", "not a func")
+
+	req := httptest.NewRequest("POST", "/api/v1/test/Invalid", strings.NewReader(`{}`))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusInternalServerError, res.Code)
+	require.NotContains(t, res.Body.String(), "handler must be func")
+}
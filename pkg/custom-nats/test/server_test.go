@@ -0,0 +1,80 @@
+package custom_nats_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/stretchr/testify/require"
+)
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+func newTestServer() *custom_nats.Server {
+	return custom_nats.NewServer(nil, custom_nats.NewRouter(chi.NewRouter()), "test.>", nil, &custom_nats.ServerConfig{
+		ServiceName: "test",
+	})
+}
+
+func Test_Server_Shutdown(t *testing.T) {
+	t.Run("Test_Close_Resources_In_Reverse_Order", func(t *testing.T) {
+		server := newTestServer()
+		closed := []string{}
+		server.RegisterCloser("database", closerFunc(func() error {
+			closed = append(closed, "database")
+			return nil
+		}))
+		server.RegisterCloser("redis", closerFunc(func() error {
+			closed = append(closed, "redis")
+			return errors.New("connection reset")
+		}))
+
+		err := server.Shutdown(context.Background())
+		require.ErrorContains(t, err, "fail to close redis: connection reset")
+		require.Equal(t, []string{"redis", "database"}, closed)
+		require.Equal(t, custom_nats.ServerStopped, server.State())
+	})
+
+	t.Run("Test_Readiness_While_Not_Ready", func(t *testing.T) {
+		server := newTestServer()
+		require.False(t, server.Ready())
+
+		res := httptest.NewRecorder()
+		server.ReadinessHandler().ServeHTTP(res, httptest.NewRequest("GET", "/readyz", nil))
+		require.Equal(t, http.StatusServiceUnavailable, res.Code)
+		require.Contains(t, res.Body.String(), `"state":"starting"`)
+
+		require.NoError(t, server.Shutdown(context.Background()))
+		res = httptest.NewRecorder()
+		server.ReadinessHandler().ServeHTTP(res, httptest.NewRequest("GET", "/readyz", nil))
+		require.Equal(t, http.StatusServiceUnavailable, res.Code)
+		require.Contains(t, res.Body.String(), `"state":"stopped"`)
+	})
+}
+
+func Test_WorkerPool_Shutdown(t *testing.T) {
+	t.Run("Test_Wait_Until_Deadline", func(t *testing.T) {
+		pool := custom_nats.NewWorkerPool(1, 0)
+		release := make(chan struct{})
+		require.Eventually(t, func() bool {
+			return pool.Submit(func() { <-release })
+		}, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+		require.False(t, pool.Submit(func() {}))
+
+		close(release)
+		require.NoError(t, pool.Shutdown(context.Background()))
+	})
+}
@@ -0,0 +1,87 @@
+package custom_nats_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/stretchr/testify/require"
+)
+
+type exportRequest struct {
+	Rows int  `json:"rows" validate:"max=100"`
+	Fail bool `json:"fail"`
+}
+
+func Test_HandleStream(t *testing.T) {
+	router := custom_nats.NewRouter(chi.NewRouter())
+	custom_nats.HandleStream(router, "POST", "/api/v1/test/Export", func(ctx context.Context, req *exportRequest, stream *custom_nats.Stream) error {
+		if req.Rows == 0 {
+			return shared.NewNotFoundError("nothing to export")
+		}
+		stream.Header().Set("Content-Type", "text/csv")
+		stream.WriteHeader(http.StatusCreated)
+		for i := 0; i < req.Rows; i++ {
+			if _, err := stream.Write([]byte("row\n")); err != nil {
+				return err
+			}
+		}
+		if req.Fail {
+			return shared.NewInternalServerError("export broke")
+		}
+		return nil
+	})
+	custom_nats.HandleStream(router, "POST", "/api/v1/test/ExportPanic", func(ctx context.Context, req *exportRequest, stream *custom_nats.Stream) error {
+		if _, err := stream.Write([]byte("row\n")); err != nil {
+			return err
+		}
+		panic("export broke")
+	}, custom_nats.Recovery())
+
+	t.Run("Test_Write_Chunks", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Export", strings.NewReader(`{"rows":3}`))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, "text/csv", res.Header().Get("Content-Type"))
+		require.Equal(t, "row\nrow\nrow\n", res.Body.String())
+		require.True(t, res.Flushed)
+	})
+
+	t.Run("Test_Error_Before_Write", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Export", strings.NewReader(`{"rows":0}`))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusNotFound, res.Code)
+		require.Contains(t, res.Body.String(), "nothing to export")
+	})
+
+	t.Run("Test_Validation_Failure", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Export", strings.NewReader(`{"rows":1000}`))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	})
+
+	t.Run("Test_Error_After_Write", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/Export", strings.NewReader(`{"rows":2,"fail":true}`))
+		res := httptest.NewRecorder()
+		// the status is already sent, so the connection is cut instead of
+		// appending an error body to the rows
+		require.PanicsWithValue(t, http.ErrAbortHandler, func() { router.ServeHTTP(res, req) })
+		require.Equal(t, http.StatusCreated, res.Code)
+		require.Equal(t, "row\nrow\n", res.Body.String())
+	})
+
+	t.Run("Test_Panic_After_Write", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/test/ExportPanic", strings.NewReader(`{}`))
+		res := httptest.NewRecorder()
+		require.PanicsWithValue(t, http.ErrAbortHandler, func() { router.ServeHTTP(res, req) })
+		require.Equal(t, "row\n", res.Body.String())
+	})
+}
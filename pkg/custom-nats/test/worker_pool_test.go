@@ -0,0 +1,63 @@
+package custom_nats_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	custom_nats "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/custom-nats"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WorkerPool(t *testing.T) {
+	t.Run("Test_Reject_When_Saturated", func(t *testing.T) {
+		pool := custom_nats.NewWorkerPool(1, 1)
+		release := make(chan struct{})
+		started := make(chan struct{})
+
+		require.True(t, pool.Submit(func() {
+			close(started)
+			<-release
+		}))
+		<-started
+		require.True(t, pool.Submit(func() {}))
+		require.False(t, pool.Submit(func() {}))
+		require.Equal(t, int64(1), pool.InFlight())
+		require.Equal(t, 1, pool.Pending())
+
+		close(release)
+		pool.Stop()
+		require.Equal(t, int64(0), pool.InFlight())
+	})
+
+	t.Run("Test_Bounded_Concurrency", func(t *testing.T) {
+		pool := custom_nats.NewWorkerPool(2, 10)
+		var mu sync.Mutex
+		running, maxRunning := 0, 0
+		for i := 0; i < 10; i++ {
+			require.True(t, pool.Submit(func() {
+				mu.Lock()
+				running++
+				maxRunning = max(maxRunning, running)
+				mu.Unlock()
+				time.Sleep(5 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+			}))
+		}
+		pool.Stop()
+		require.LessOrEqual(t, maxRunning, 2)
+	})
+
+	t.Run("Test_Recover_From_Panic", func(t *testing.T) {
+		pool := custom_nats.NewWorkerPool(1, 1)
+		done := make(chan struct{})
+		require.True(t, pool.Submit(func() { panic("boom") }))
+		require.Eventually(t, func() bool {
+			return pool.Submit(func() { close(done) })
+		}, time.Second, time.Millisecond)
+		<-done
+		pool.Stop()
+	})
+}
@@ -0,0 +1,110 @@
+package custom_nats
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/logging"
+)
+
+// WorkerPool runs tasks on a fixed number of goroutines. Tasks wait in a
+// bounded queue; once the queue is full Submit refuses new work instead of
+// blocking the caller.
+type WorkerPool struct {
+	tasks    chan func()
+	inFlight atomic.Int64
+	wg       sync.WaitGroup
+	mu       sync.RWMutex
+	stopped  bool
+}
+
+func NewWorkerPool(maxInFlight, maxPending int) *WorkerPool {
+	if maxInFlight <= 0 {
+		maxInFlight = 1
+	}
+	if maxPending < 0 {
+		maxPending = 0
+	}
+	pool := &WorkerPool{
+		tasks: make(chan func(), maxPending),
+	}
+	pool.wg.Add(maxInFlight)
+	for i := 0; i < maxInFlight; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+	for task := range p.tasks {
+		p.run(task)
+	}
+}
+
+func (p *WorkerPool) run(task func()) {
+	p.inFlight.Add(1)
+	defer p.inFlight.Add(-1)
+	defer func() {
+		if rec := recover(); rec != nil {
+			logging.GetSugaredLogger().Errorf("worker recovered from panic: %v\n%s", rec, debug.Stack())
+		}
+	}()
+	task()
+}
+
+// Submit queues task and reports false when the pool is saturated or
+// stopped.
+func (p *WorkerPool) Submit(task func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return false
+	}
+	select {
+	case p.tasks <- task:
+		return true
+	default:
+		return false
+	}
+}
+
+// InFlight returns the number of tasks currently running.
+func (p *WorkerPool) InFlight() int64 {
+	return p.inFlight.Load()
+}
+
+// Pending returns the number of tasks waiting for a worker.
+func (p *WorkerPool) Pending() int {
+	return len(p.tasks)
+}
+
+// Stop lets the workers finish the queued tasks and waits for them.
+func (p *WorkerPool) Stop() {
+	_ = p.Shutdown(context.Background())
+}
+
+// Shutdown refuses new tasks and waits for the queued and running ones until
+// ctx is done.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
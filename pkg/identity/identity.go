@@ -0,0 +1,152 @@
+package identity
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/configs"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+)
+
+const (
+	// Header carries the identity the gateway resolved for a request, signed
+	// so that services can trust it without asking the auth service again.
+	Header = "X-Identity"
+	// UserIdHeader is the plain user id header of older services. It is not
+	// signed, so it is dropped from every request and never trusted.
+	UserIdHeader = "X-User-Id"
+	// ResolveTokenPath is the auth service method resolving a bearer token.
+	// It is outside /api so the gateway never routes clients to it.
+	ResolveTokenPath = "/internal/identity/ResolveToken"
+)
+
+var (
+	ErrInvalidIdentity = errors.New("invalid identity")
+	ErrExpiredIdentity = errors.New("identity expired")
+)
+
+// Identity is who a request is made for.
+type Identity struct {
+	UserId    string   `json:"user_id"`
+	Roles     []string `json:"roles,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	SessionId string   `json:"session_id,omitempty"`
+	// TenantId is the organization the caller belongs to.
+	TenantId string `json:"tenant_id,omitempty"`
+	// ExpiresAt is the unix time the session or token ends, 0 when unknown.
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+func (i *Identity) HasRole(role string) bool {
+	return slices.Contains(i.Roles, role)
+}
+
+func (i *Identity) HasScope(scope string) bool {
+	return slices.Contains(i.Scopes, scope)
+}
+
+// Expired reports whether the session or token behind the identity ended.
+func (i *Identity) Expired(now time.Time) bool {
+	return i.ExpiresAt > 0 && now.Unix() >= i.ExpiresAt
+}
+
+type ResolveTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// signedIdentity is the payload of the header.
+type signedIdentity struct {
+	Identity
+	IssuedAt int64 `json:"iat"`
+}
+
+// Signer signs identities with HMAC-SHA256 and verifies them. A signed
+// identity is accepted for ttl after it was issued.
+type Signer struct {
+	key []byte
+	ttl time.Duration
+}
+
+func NewSigner(key string, ttl time.Duration) (*Signer, error) {
+	if key == "" {
+		return nil, errors.New("identity signing key is empty")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("identity ttl must be positive, got %v", ttl)
+	}
+	return &Signer{key: []byte(key), ttl: ttl}, nil
+}
+
+// LoadSigner builds the signer from identity.signing_key and identity.ttl.
+func LoadSigner() (*Signer, error) {
+	config := configs.LoadIdentityConfig()
+	return NewSigner(config.SigningKey, config.TTL)
+}
+
+// Sign encodes identity as <payload>.<signature>, both base64url.
+func (s *Signer) Sign(identity Identity) (string, error) {
+	payload, err := json.Marshal(signedIdentity{Identity: identity, IssuedAt: time.Now().Unix()})
+	if err != nil {
+		return "", fmt.Errorf("fail to marshal identity: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// Verify checks the signature and the age of value and returns the identity
+// it carries.
+func (s *Signer) Verify(value string) (*Identity, error) {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, ErrInvalidIdentity
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return nil, ErrInvalidIdentity
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidIdentity
+	}
+	var signed signedIdentity
+	if err := json.Unmarshal(payload, &signed); err != nil || signed.UserId == "" {
+		return nil, ErrInvalidIdentity
+	}
+	now := time.Now()
+	if now.Sub(time.Unix(signed.IssuedAt, 0)) > s.ttl || signed.Expired(now) {
+		return nil, ErrExpiredIdentity
+	}
+	return &signed.Identity, nil
+}
+
+func (s *Signer) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// NewContext returns ctx carrying identity and the signed header it came in,
+// which ServiceClient forwards on calls to other services.
+func NewContext(ctx context.Context, identity *Identity, signed string) context.Context {
+	ctx = context.WithValue(ctx, shared.Identity_ContextKey, identity)
+	ctx = context.WithValue(ctx, shared.SignedIdentity_ContextKey, signed)
+	return context.WithValue(ctx, shared.UserId_ContextKey, identity.UserId)
+}
+
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(shared.Identity_ContextKey).(*Identity)
+	return identity, ok
+}
+
+func SignedFromContext(ctx context.Context) (string, bool) {
+	signed, ok := ctx.Value(shared.SignedIdentity_ContextKey).(string)
+	return signed, ok && signed != ""
+}
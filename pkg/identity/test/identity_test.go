@@ -0,0 +1,89 @@
+package identity_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/identity"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Signer(t *testing.T) {
+	signer, err := identity.NewSigner("test-signing-key", time.Minute)
+	require.NoError(t, err)
+
+	t.Run("Test_Sign_And_Verify", func(t *testing.T) {
+		signed, err := signer.Sign(identity.Identity{UserId: "user-1", Roles: []string{"admin"}, SessionId: "session-1"})
+		require.NoError(t, err)
+		id, err := signer.Verify(signed)
+		require.NoError(t, err)
+		require.Equal(t, "user-1", id.UserId)
+		require.Equal(t, "session-1", id.SessionId)
+		require.True(t, id.HasRole("admin"))
+		require.False(t, id.HasRole("customer"))
+	})
+
+	t.Run("Test_Tampered_Identity", func(t *testing.T) {
+		signed, err := signer.Sign(identity.Identity{UserId: "user-1"})
+		require.NoError(t, err)
+		other, err := signer.Sign(identity.Identity{UserId: "user-2"})
+		require.NoError(t, err)
+		payload, _, _ := strings.Cut(other, ".")
+		_, signature, _ := strings.Cut(signed, ".")
+
+		for _, value := range []string{"", "garbage", payload + "." + signature, signed + "x"} {
+			_, err := signer.Verify(value)
+			require.ErrorIs(t, err, identity.ErrInvalidIdentity)
+		}
+	})
+
+	t.Run("Test_Other_Key", func(t *testing.T) {
+		other, err := identity.NewSigner("another-key", time.Minute)
+		require.NoError(t, err)
+		signed, err := other.Sign(identity.Identity{UserId: "user-1"})
+		require.NoError(t, err)
+		_, err = signer.Verify(signed)
+		require.ErrorIs(t, err, identity.ErrInvalidIdentity)
+	})
+
+	t.Run("Test_Expired_Session", func(t *testing.T) {
+		signed, err := signer.Sign(identity.Identity{UserId: "user-1", ExpiresAt: time.Now().Add(-time.Second).Unix()})
+		require.NoError(t, err)
+		_, err = signer.Verify(signed)
+		require.ErrorIs(t, err, identity.ErrExpiredIdentity)
+	})
+
+	t.Run("Test_Old_Signature", func(t *testing.T) {
+		short, err := identity.NewSigner("test-signing-key", time.Nanosecond)
+		require.NoError(t, err)
+		signed, err := signer.Sign(identity.Identity{UserId: "user-1"})
+		require.NoError(t, err)
+		// signatures are issued with second precision
+		time.Sleep(1100 * time.Millisecond)
+		_, err = short.Verify(signed)
+		require.ErrorIs(t, err, identity.ErrExpiredIdentity)
+	})
+
+	t.Run("Test_Invalid_Config", func(t *testing.T) {
+		_, err := identity.NewSigner("", time.Minute)
+		require.Error(t, err)
+		_, err = identity.NewSigner("key", 0)
+		require.Error(t, err)
+	})
+}
+
+func Test_Context(t *testing.T) {
+	ctx := context.Background()
+	_, ok := identity.FromContext(ctx)
+	require.False(t, ok)
+
+	ctx = identity.NewContext(ctx, &identity.Identity{UserId: "user-1"}, "signed")
+	id, ok := identity.FromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, "user-1", id.UserId)
+	signed, ok := identity.SignedFromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, "signed", signed)
+}
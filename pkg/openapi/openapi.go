@@ -0,0 +1,154 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// Version is the OpenAPI version of the documents written here.
+const Version = "3.0.3"
+
+// Document is the subset of an OpenAPI 3 document the codegen writes and the
+// gateway serves.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path. Service methods are only
+// registered for POST.
+type PathItem struct {
+	Post *Operation `json:"post,omitempty"`
+}
+
+type Operation struct {
+	OperationId string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// SecurityRequirement names the schemes of which one must be satisfied.
+type SecurityRequirement map[string][]string
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	// EnumNames are the names of the values of an integer enum, in order
+	EnumNames            []string `json:"x-enum-varnames,omitempty"`
+	Minimum              *float64 `json:"minimum,omitempty"`
+	Maximum              *float64 `json:"maximum,omitempty"`
+	MinLength            *int     `json:"minLength,omitempty"`
+	MaxLength            *int     `json:"maxLength,omitempty"`
+	MinItems             *int     `json:"minItems,omitempty"`
+	MaxItems             *int     `json:"maxItems,omitempty"`
+	AdditionalProperties *Schema  `json:"additionalProperties,omitempty"`
+}
+
+// SchemaRef points at the schema name of the components.
+func SchemaRef(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// ReadFile reads a document written as JSON.
+func ReadFile(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read openapi document: %w", err)
+	}
+	document := &Document{}
+	if err := json.Unmarshal(data, document); err != nil {
+		return nil, fmt.Errorf("fail to decode openapi document %s: %w", path, err)
+	}
+	return document, nil
+}
+
+// Merge combines the documents of several services into one titled info.
+// Paths may only be defined once; a schema defined twice must be the same in
+// both documents.
+func Merge(info Info, documents ...*Document) (*Document, error) {
+	merged := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas:         map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{},
+		},
+	}
+	tags := map[string]bool{}
+	for _, document := range documents {
+		for _, tag := range document.Tags {
+			if !tags[tag.Name] {
+				tags[tag.Name] = true
+				merged.Tags = append(merged.Tags, tag)
+			}
+		}
+		for path, item := range document.Paths {
+			if _, ok := merged.Paths[path]; ok {
+				return nil, fmt.Errorf("path %s is defined by more than one document", path)
+			}
+			merged.Paths[path] = item
+		}
+		for name, schema := range document.Components.Schemas {
+			if existing, ok := merged.Components.Schemas[name]; ok && !reflect.DeepEqual(existing, schema) {
+				return nil, fmt.Errorf("schema %s is defined differently by more than one document", name)
+			}
+			merged.Components.Schemas[name] = schema
+		}
+		for name, scheme := range document.Components.SecuritySchemes {
+			merged.Components.SecuritySchemes[name] = scheme
+		}
+	}
+	return merged, nil
+}
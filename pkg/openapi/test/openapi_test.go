@@ -0,0 +1,51 @@
+package openapi_test
+
+import (
+	"testing"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/openapi"
+	"github.com/stretchr/testify/require"
+)
+
+func newDocument(path string, schemas map[string]*openapi.Schema) *openapi.Document {
+	return &openapi.Document{
+		OpenAPI: openapi.Version,
+		Tags:    []openapi.Tag{{Name: "Shared"}},
+		Paths:   map[string]*openapi.PathItem{path: {Post: &openapi.Operation{OperationId: path}}},
+		Components: openapi.Components{
+			Schemas: schemas,
+		},
+	}
+}
+
+func Test_Merge(t *testing.T) {
+	errorSchema := &openapi.Schema{Type: "object"}
+
+	t.Run("Test_Merge_Documents", func(t *testing.T) {
+		merged, err := openapi.Merge(openapi.Info{Title: "API", Version: "v1"},
+			newDocument("/api/v1/order/CreateOrder", map[string]*openapi.Schema{"Error": errorSchema, "order.CreateOrderRequest": {Type: "object"}}),
+			newDocument("/api/v1/auth/Login", map[string]*openapi.Schema{"Error": {Type: "object"}, "auth.LoginRequest": {Type: "object"}}),
+		)
+		require.NoError(t, err)
+		require.Equal(t, "API", merged.Info.Title)
+		require.Len(t, merged.Paths, 2)
+		require.Len(t, merged.Components.Schemas, 3)
+		require.Len(t, merged.Tags, 1)
+	})
+
+	t.Run("Test_Duplicate_Path", func(t *testing.T) {
+		_, err := openapi.Merge(openapi.Info{},
+			newDocument("/api/v1/order/CreateOrder", nil),
+			newDocument("/api/v1/order/CreateOrder", nil),
+		)
+		require.Error(t, err)
+	})
+
+	t.Run("Test_Conflicting_Schema", func(t *testing.T) {
+		_, err := openapi.Merge(openapi.Info{},
+			newDocument("/api/v1/order/CreateOrder", map[string]*openapi.Schema{"Error": errorSchema}),
+			newDocument("/api/v1/auth/Login", map[string]*openapi.Schema{"Error": {Type: "string"}}),
+		)
+		require.Error(t, err)
+	})
+}
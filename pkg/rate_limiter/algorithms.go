@@ -0,0 +1,155 @@
+package ratelimiter
+
+import (
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Algorithm names a way of counting requests. Each one is a Lua script run
+// atomically by redis, on the redis clock, so every gateway instance shares
+// the same count. The scripts take the limit, the window in milliseconds, a
+// unique id and 1 to decide without counting the request, and return
+// {allowed, remaining, reset at, retry after}, times in milliseconds.
+type Algorithm string
+
+const (
+	// SlidingLog keeps the time of every request of the window. It is exact
+	// but holds up to limit entries per key.
+	SlidingLog Algorithm = "sliding_log"
+	// SlidingWindow weighs the count of the previous fixed window by how much
+	// of it still overlaps the sliding one. It holds two counters per key.
+	SlidingWindow Algorithm = "sliding_window"
+	// TokenBucket is the generic cell rate algorithm: tokens come back one
+	// every window/limit and a full bucket allows a burst of limit requests.
+	// It holds one timestamp per key.
+	TokenBucket Algorithm = "token_bucket"
+)
+
+// DefaultAlgorithm is used when none is configured.
+const DefaultAlgorithm = SlidingWindow
+
+// redisNowMs reads the redis clock. Scripts are replicated by their effects,
+// so reading the time before writing is allowed.
+const redisNowMs = `
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+`
+
+// ARGV[3] makes the member of the request unique when two requests share a
+// millisecond.
+var slidingLogScript = redis.NewScript(redisNowMs + `
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local peek = ARGV[4] == "1"
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+local count = redis.call("ZCARD", key)
+local allowed = 0
+if count < limit then
+	if not peek then
+		redis.call("ZADD", key, now, now .. ":" .. ARGV[3])
+	end
+	count = count + 1
+	allowed = 1
+end
+redis.call("PEXPIRE", key, window)
+-- a slot frees up when the oldest request leaves the window
+local reset = now + window
+local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window
+end
+local retry = 0
+if allowed == 0 then
+	retry = reset - now
+end
+return {allowed, limit - count, reset, retry}
+`)
+
+var slidingWindowScript = redis.NewScript(redisNowMs + `
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local peek = ARGV[4] == "1"
+local index = math.floor(now / window)
+local stored = redis.call("HMGET", key, "index", "current", "previous")
+local current = tonumber(stored[2]) or 0
+local previous = tonumber(stored[3]) or 0
+local storedIndex = tonumber(stored[1])
+if storedIndex == index - 1 then
+	previous = current
+	current = 0
+elseif storedIndex ~= index then
+	previous = 0
+	current = 0
+end
+local windowEnd = (index + 1) * window
+-- share of the previous window still inside the sliding one
+local overlap = (windowEnd - now) / window
+local count = previous * overlap + current
+local allowed = 0
+if count + 1 <= limit then
+	current = current + 1
+	count = count + 1
+	allowed = 1
+	if not peek then
+		redis.call("HSET", key, "index", index, "current", current, "previous", previous)
+		redis.call("PEXPIRE", key, window * 2)
+	end
+end
+local retry = 0
+if allowed == 0 then
+	retry = windowEnd - now
+	local room = limit - current - 1
+	if room >= 0 and previous > 0 then
+		-- the previous window weighs less as time passes
+		retry = math.ceil(window - room * window / previous - (now - index * window))
+	end
+	if retry < 1 then
+		retry = 1
+	end
+end
+return {allowed, math.max(0, math.floor(limit - count)), windowEnd, retry}
+`)
+
+// The bucket is stored as the theoretical arrival time of the next request,
+// tat; it is full again at tat.
+var tokenBucketScript = redis.NewScript(redisNowMs + `
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local peek = ARGV[4] == "1"
+local interval = window / limit
+local tat = tonumber(redis.call("GET", key)) or now
+if tat < now then
+	tat = now
+end
+local newTat = tat + interval
+local allowAt = newTat - window
+if now < allowAt then
+	return {0, 0, math.ceil(tat), math.ceil(allowAt - now)}
+end
+if not peek then
+	redis.call("SET", key, string.format("%.3f", newTat), "PX", math.ceil(newTat - now))
+end
+return {1, math.floor((window - (newTat - now)) / interval), math.ceil(newTat), 0}
+`)
+
+var scripts = map[Algorithm]*redis.Script{
+	SlidingLog:    slidingLogScript,
+	SlidingWindow: slidingWindowScript,
+	TokenBucket:   tokenBucketScript,
+}
+
+// ParseAlgorithm checks name, DefaultAlgorithm when it is empty.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	if name == "" {
+		return DefaultAlgorithm, nil
+	}
+	algorithm := Algorithm(name)
+	if _, ok := scripts[algorithm]; !ok {
+		return "", fmt.Errorf("unknown rate limit algorithm %q", name)
+	}
+	return algorithm, nil
+}
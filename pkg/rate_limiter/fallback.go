@@ -0,0 +1,83 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/circuitbreaker"
+)
+
+// ErrUnavailable is returned by ClosedLimiter, when requests are not let
+// through without their count.
+var ErrUnavailable = errors.New("rate limiter is unavailable")
+
+// FallbackLimiter counts with primary and, when it fails, lets fallback decide.
+// Failures go through breaker, which the limiters of one redis share: once it
+// opens, requests no longer wait on redis and go straight to fallback until
+// the breaker lets a few through again.
+type FallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+	breaker  *circuitbreaker.Breaker[Result]
+}
+
+func NewFallbackLimiter(primary, fallback Limiter, breaker *circuitbreaker.Breaker[Result]) *FallbackLimiter {
+	return &FallbackLimiter{
+		primary:  primary,
+		fallback: fallback,
+		breaker:  breaker,
+	}
+}
+
+func (l *FallbackLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.decide(ctx, key, Limiter.Allow)
+}
+
+func (l *FallbackLimiter) Peek(ctx context.Context, key string) (Result, error) {
+	return l.decide(ctx, key, Limiter.Peek)
+}
+
+func (l *FallbackLimiter) decide(ctx context.Context, key string, call func(Limiter, context.Context, string) (Result, error)) (Result, error) {
+	result, err := l.breaker.Do(ctx, func() (Result, error) {
+		return call(l.primary, ctx, key)
+	})
+	if err == nil {
+		return *result, nil
+	}
+	fallbackResult, fallbackErr := call(l.fallback, ctx, key)
+	if fallbackErr != nil {
+		return Result{}, fmt.Errorf("%w: %w", fallbackErr, err)
+	}
+	return fallbackResult, nil
+}
+
+// OpenLimiter allows every request, failing open.
+type OpenLimiter struct {
+	limit  int
+	window time.Duration
+}
+
+func NewOpenLimiter(limit int, window time.Duration) *OpenLimiter {
+	return &OpenLimiter{limit: limit, window: window}
+}
+
+func (l *OpenLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return Result{Allowed: true, Limit: l.limit, Remaining: l.limit, ResetAt: time.Now(), Window: l.window}, nil
+}
+
+func (l *OpenLimiter) Peek(ctx context.Context, key string) (Result, error) {
+	return l.Allow(ctx, key)
+}
+
+// ClosedLimiter allows no request, failing closed.
+type ClosedLimiter struct{}
+
+func (ClosedLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return Result{}, ErrUnavailable
+}
+
+func (ClosedLimiter) Peek(ctx context.Context, key string) (Result, error) {
+	return Result{}, ErrUnavailable
+}
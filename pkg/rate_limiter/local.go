@@ -0,0 +1,97 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// LocalRateLimiter counts requests in the memory of the process with the
+// sliding window of SlidingWindow. Every instance counts on its own, so it
+// only approximates a limit shared by several of them.
+type LocalRateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*localWindow
+	// swept is the index of the window the stale keys were last removed in
+	swept int64
+}
+
+type localWindow struct {
+	index    int64
+	current  int
+	previous int
+}
+
+func NewLocalRateLimiter(limit int, window time.Duration) *LocalRateLimiter {
+	return &LocalRateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: map[string]*localWindow{},
+	}
+}
+
+func (l *LocalRateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.count(key, false)
+}
+
+func (l *LocalRateLimiter) Peek(ctx context.Context, key string) (Result, error) {
+	return l.count(key, true)
+}
+
+func (l *LocalRateLimiter) count(key string, peek bool) (Result, error) {
+	if l.limit <= 0 || l.window < time.Millisecond {
+		return Result{}, fmt.Errorf("rate limit of %d per %s is not valid", l.limit, l.window)
+	}
+	now := time.Now()
+	window := l.window.Milliseconds()
+	index := now.UnixMilli() / window
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if index > l.swept {
+		// keys idle for a whole window no longer weigh on the count
+		for k, w := range l.windows {
+			if w.index < index-1 {
+				delete(l.windows, k)
+			}
+		}
+		l.swept = index
+	}
+	w, ok := l.windows[key]
+	if !ok {
+		w = &localWindow{index: index}
+		l.windows[key] = w
+	}
+	if w.index == index-1 {
+		w.previous, w.current = w.current, 0
+	} else if w.index != index {
+		w.previous, w.current = 0, 0
+	}
+	w.index = index
+
+	windowEnd := time.UnixMilli((index + 1) * window)
+	// share of the previous window still inside the sliding one
+	overlap := float64(windowEnd.Sub(now)) / float64(l.window)
+	count := float64(w.previous)*overlap + float64(w.current)
+	result := Result{Limit: l.limit, ResetAt: windowEnd, Window: l.window}
+	if count+1 <= float64(l.limit) {
+		if !peek {
+			w.current++
+		}
+		result.Allowed = true
+		result.Remaining = int(math.Floor(float64(l.limit) - count - 1))
+		return result, nil
+	}
+	result.RetryAfter = windowEnd.Sub(now)
+	if room := l.limit - w.current - 1; room >= 0 && w.previous > 0 {
+		// the previous window weighs less as time passes
+		result.RetryAfter = time.Duration(float64(l.window)*(1-float64(room)/float64(w.previous))) - now.Sub(time.UnixMilli(index*window))
+	}
+	result.RetryAfter = max(result.RetryAfter, time.Millisecond)
+	return result, nil
+}
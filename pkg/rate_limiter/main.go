@@ -5,52 +5,88 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+// Result is the outcome of one request against a limit.
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining is how many more requests are allowed right now.
+	Remaining int
+	// ResetAt is when the limit has room again, for a bucket when it is full.
+	ResetAt time.Time
+	// RetryAfter is the wait before a denied request may be allowed.
+	RetryAfter time.Duration
+	// Window is the period Limit applies to.
+	Window time.Duration
+}
+
+// Limiter decides whether the request counted under key is allowed.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+	// Peek tells what Allow would decide without counting the request.
+	Peek(ctx context.Context, key string) (Result, error)
+}
+
+// RateLimiter allows limit requests per window and key with an algorithm run
+// in redis. It holds no state of its own, so it is safe for concurrent use.
 type RateLimiter struct {
-	redisClient          *redis.Client
-	limit                int
-	window               time.Duration
-	ctx                  context.Context
-	currentNumberRequest int
+	redisClient *redis.Client
+	algorithm   Algorithm
+	limit       int
+	window      time.Duration
 }
 
-func NewRateLimiter(redisClient *redis.Client, limit int, window time.Duration, ctx context.Context) *RateLimiter {
+func NewRateLimiter(redisClient *redis.Client, algorithm Algorithm, limit int, window time.Duration) *RateLimiter {
 	return &RateLimiter{
 		redisClient: redisClient,
+		algorithm:   algorithm,
 		limit:       limit,
 		window:      window,
-		ctx:         ctx,
 	}
 }
 
-func (r *RateLimiter) IsAllow(key string) (bool, error) {
-	redisKey := fmt.Sprintf("rate_limit:%s", key)
-	script := redis.NewScript(`
-	local current
-	current = redis.call("INCR", KEYS[1])
-	if tonumber(current) == 1	then 
-		redis.call("PEXPIRE", KEYS[1], ARGV[1])
-	end
-	return current
-	`)
-	result, err := script.Run(r.ctx, r.redisClient, []string{redisKey}, int(r.window.Milliseconds())).Result()
+func (r *RateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return r.run(ctx, key, false)
+}
+
+func (r *RateLimiter) Peek(ctx context.Context, key string) (Result, error) {
+	return r.run(ctx, key, true)
+}
+
+func (r *RateLimiter) run(ctx context.Context, key string, peek bool) (Result, error) {
+	script, ok := scripts[r.algorithm]
+	if !ok {
+		return Result{}, fmt.Errorf("unknown rate limit algorithm %q", r.algorithm)
+	}
+	if r.limit <= 0 || r.window < time.Millisecond {
+		return Result{}, fmt.Errorf("rate limit of %d per %s is not valid", r.limit, r.window)
+	}
+	// keys of each algorithm hold different types
+	redisKey := fmt.Sprintf("rate_limit:%s:%s", r.algorithm, key)
+	peekArg := 0
+	if peek {
+		peekArg = 1
+	}
+	values, err := script.Run(ctx, r.redisClient, []string{redisKey}, r.limit, r.window.Milliseconds(), uuid.NewString(), peekArg).Int64Slice()
 	if err != nil {
-		return false, err
+		return Result{}, fmt.Errorf("fail to run rate limit script: %w", err)
 	}
-	count := int(result.(int64))
-	r.currentNumberRequest = count
-	if count > r.limit {
-		return false, nil
+	if len(values) != 4 {
+		return Result{}, fmt.Errorf("rate limit script returned %d values", len(values))
 	}
-	return true, nil
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      r.limit,
+		Remaining:  int(max(values[1], 0)),
+		ResetAt:    time.UnixMilli(values[2]),
+		RetryAfter: time.Duration(values[3]) * time.Millisecond,
+		Window:     r.window,
+	}, nil
 }
 
 func (r *RateLimiter) GetLimit() int {
 	return r.limit
 }
-
-func (r *RateLimiter) GetCurrentNumberRequest() int {
-	return r.currentNumberRequest
-}
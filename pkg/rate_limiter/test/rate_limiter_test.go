@@ -0,0 +1,109 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/circuitbreaker"
+	ratelimiter "github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/rate_limiter"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseAlgorithm(t *testing.T) {
+	for _, name := range []string{"sliding_log", "sliding_window", "token_bucket"} {
+		algorithm, err := ratelimiter.ParseAlgorithm(name)
+		require.NoError(t, err)
+		require.Equal(t, ratelimiter.Algorithm(name), algorithm)
+	}
+
+	algorithm, err := ratelimiter.ParseAlgorithm("")
+	require.NoError(t, err)
+	require.Equal(t, ratelimiter.DefaultAlgorithm, algorithm)
+
+	_, err = ratelimiter.ParseAlgorithm("fixed_window")
+	require.Error(t, err)
+}
+
+func Test_RateLimiter_Invalid_Limit(t *testing.T) {
+	// checked before redis is called
+	for _, limiter := range []*ratelimiter.RateLimiter{
+		ratelimiter.NewRateLimiter(nil, ratelimiter.TokenBucket, 0, time.Minute),
+		ratelimiter.NewRateLimiter(nil, ratelimiter.SlidingLog, 10, 0),
+		ratelimiter.NewRateLimiter(nil, "fixed_window", 10, time.Minute),
+	} {
+		_, err := limiter.Allow(context.Background(), "client")
+		require.Error(t, err)
+	}
+}
+
+func Test_LocalRateLimiter(t *testing.T) {
+	limiter := ratelimiter.NewLocalRateLimiter(3, time.Hour)
+	for i := range 3 {
+		result, err := limiter.Allow(context.Background(), "client")
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, 2-i, result.Remaining)
+	}
+	result, err := limiter.Allow(context.Background(), "client")
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Positive(t, result.RetryAfter)
+
+	result, err = limiter.Peek(context.Background(), "other")
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	result, err = limiter.Allow(context.Background(), "other")
+	require.NoError(t, err)
+	require.True(t, result.Allowed, "keys are counted apart")
+	require.Equal(t, 2, result.Remaining, "a peek is not counted")
+
+	_, err = ratelimiter.NewLocalRateLimiter(0, time.Minute).Allow(context.Background(), "client")
+	require.Error(t, err)
+}
+
+// failingLimiter fails every request and counts them.
+type failingLimiter struct {
+	calls int
+}
+
+func (l *failingLimiter) Allow(ctx context.Context, key string) (ratelimiter.Result, error) {
+	l.calls++
+	return ratelimiter.Result{}, errors.New("redis is down")
+}
+
+func (l *failingLimiter) Peek(ctx context.Context, key string) (ratelimiter.Result, error) {
+	return l.Allow(ctx, key)
+}
+
+func Test_FallbackLimiter(t *testing.T) {
+	newBreaker := func() *circuitbreaker.Breaker[ratelimiter.Result] {
+		return circuitbreaker.NewBreaker[ratelimiter.Result](&circuitbreaker.Config{
+			Name: "rate-limit-test", MaxRequests: 1, Interval: 30, Timeout: 10, FailureThreshold: 2,
+		})
+	}
+
+	t.Run("Test_Local_Until_Breaker_Opens", func(t *testing.T) {
+		primary := &failingLimiter{}
+		limiter := ratelimiter.NewFallbackLimiter(primary, ratelimiter.NewLocalRateLimiter(5, time.Minute), newBreaker())
+		for range 5 {
+			result, err := limiter.Allow(context.Background(), "client")
+			require.NoError(t, err)
+			require.True(t, result.Allowed)
+		}
+		result, err := limiter.Allow(context.Background(), "client")
+		require.NoError(t, err)
+		require.False(t, result.Allowed)
+		require.Equal(t, 3, primary.calls, "redis is not called once the breaker is open")
+	})
+
+	t.Run("Test_Open_And_Closed", func(t *testing.T) {
+		result, err := ratelimiter.NewFallbackLimiter(&failingLimiter{}, ratelimiter.NewOpenLimiter(5, time.Minute), newBreaker()).Allow(context.Background(), "client")
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+
+		_, err = ratelimiter.NewFallbackLimiter(&failingLimiter{}, ratelimiter.ClosedLimiter{}, newBreaker()).Allow(context.Background(), "client")
+		require.ErrorIs(t, err, ratelimiter.ErrUnavailable)
+	})
+}
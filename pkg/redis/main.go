@@ -24,3 +24,7 @@ func NewRedis(redisConfig *redis_config.RedisConfig) *Redis {
 func (r *Redis) GetClient() *redis.Client {
 	return r.client
 }
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
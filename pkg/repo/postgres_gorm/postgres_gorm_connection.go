@@ -19,3 +19,14 @@ func (p *PostgresGormConnection) Connect(dsn string) error {
 	p.Db = db
 	return nil
 }
+
+func (p *PostgresGormConnection) Close() error {
+	if p.Db == nil {
+		return nil
+	}
+	sqlDb, err := p.Db.DB()
+	if err != nil {
+		return fmt.Errorf("fail to get sql db from gorm: %w", err)
+	}
+	return sqlDb.Close()
+}
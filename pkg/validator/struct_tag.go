@@ -0,0 +1,203 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const TagName = "validate"
+
+// Rule is one entry of a rule list such as "required,min=3,oneof=a|b".
+type Rule struct {
+	Name string
+	Arg  string
+}
+
+var knownRules = map[string]bool{
+	"required": false,
+	"min":      true,
+	"max":      true,
+	"uuid":     false,
+	"email":    false,
+	"oneof":    true,
+}
+
+// ParseRules parses a comma separated rule list. It is shared by the struct
+// tag validator and the proto2dgo generator so both accept the same syntax.
+func ParseRules(rules string) ([]Rule, error) {
+	result := []Rule{}
+	for _, item := range strings.Split(rules, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, arg, hasArg := strings.Cut(item, "=")
+		needArg, ok := knownRules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown validation rule %q", name)
+		}
+		if needArg != hasArg || (hasArg && arg == "") {
+			return nil, fmt.Errorf("invalid argument for validation rule %q", name)
+		}
+		if name == "min" || name == "max" {
+			if _, err := strconv.ParseFloat(arg, 64); err != nil {
+				return nil, fmt.Errorf("validation rule %q needs a number: %w", name, err)
+			}
+		}
+		result = append(result, Rule{Name: name, Arg: arg})
+	}
+	return result, nil
+}
+
+type fieldPlan struct {
+	index int
+	name  string
+	rules []Rule
+}
+
+// plans caches the parsed tags per struct type, so reflection over the tags
+// only happens the first time a type is validated.
+var plans sync.Map
+
+func planFor(t reflect.Type) ([]fieldPlan, error) {
+	if cached, ok := plans.Load(t); ok {
+		return cached.([]fieldPlan), nil
+	}
+	plan := []fieldPlan{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup(TagName)
+		if !ok || !field.IsExported() {
+			continue
+		}
+		rules, err := ParseRules(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+		plan = append(plan, fieldPlan{
+			index: i,
+			name:  fieldName(field),
+			rules: rules,
+		})
+	}
+	plans.Store(t, plan)
+	return plan, nil
+}
+
+func fieldName(field reflect.StructField) string {
+	if jsonTag := field.Tag.Get("json"); jsonTag != "" {
+		if name, _, _ := strings.Cut(jsonTag, ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// ValidateStruct checks the `validate` struct tags of v, which must be a
+// struct or a pointer to one. Values of other kinds are accepted as is.
+func ValidateStruct(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	plan, err := planFor(rv.Type())
+	if err != nil {
+		return err
+	}
+	results := []*FieldError{}
+	for _, field := range plan {
+		value := rv.Field(field.index)
+		for _, rule := range field.rules {
+			results = append(results, checkRule(field.name, value, rule))
+		}
+	}
+	return Collect(results...)
+}
+
+func checkRule(field string, value reflect.Value, rule Rule) *FieldError {
+	if rule.Name == "required" {
+		if value.IsZero() || (hasLen(value) && value.Len() == 0) {
+			return newFieldError(field, "required", "is required")
+		}
+		return nil
+	}
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch rule.Name {
+	case "min", "max":
+		return checkBound(field, value, rule)
+	case "uuid":
+		if value.Kind() == reflect.String {
+			return UUID(field, value.String())
+		}
+	case "email":
+		if value.Kind() == reflect.String {
+			return Email(field, value.String())
+		}
+	case "oneof":
+		if value.IsZero() {
+			return nil
+		}
+		allowed := strings.Split(rule.Arg, "|")
+		current := fmt.Sprint(value.Interface())
+		for _, item := range allowed {
+			if item == current {
+				return nil
+			}
+		}
+		return newFieldError(field, "oneof", fmt.Sprintf("must be one of %v", allowed))
+	}
+	return nil
+}
+
+func checkBound(field string, value reflect.Value, rule Rule) *FieldError {
+	limit, _ := strconv.ParseFloat(rule.Arg, 64)
+	isMin := rule.Name == "min"
+	switch value.Kind() {
+	case reflect.String:
+		if isMin {
+			return MinLen(field, value.String(), int(limit))
+		}
+		return MaxLen(field, value.String(), int(limit))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if isMin {
+			return MinItems(field, value.Len(), int(limit))
+		}
+		return MaxItems(field, value.Len(), int(limit))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return bound(field, float64(value.Int()), limit, isMin)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return bound(field, float64(value.Uint()), limit, isMin)
+	case reflect.Float32, reflect.Float64:
+		return bound(field, value.Float(), limit, isMin)
+	}
+	return nil
+}
+
+func bound(field string, value, limit float64, isMin bool) *FieldError {
+	if isMin {
+		return Min(field, value, limit)
+	}
+	return Max(field, value, limit)
+}
+
+func hasLen(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package validator_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/pkg/validator"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+	"github.com/stretchr/testify/require"
+)
+
+type createProductRequest struct {
+	Name     string   `json:"name" validate:"required,min=3,max=10"`
+	Email    string   `json:"email" validate:"email"`
+	Quantity int32    `json:"quantity" validate:"min=1,max=100"`
+	Status   string   `json:"status" validate:"oneof=draft|active"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	ShopId   string   `validate:"uuid"`
+}
+
+type selfValidated struct{}
+
+func (selfValidated) Validate() error {
+	return validator.Collect(validator.Required("id", ""))
+}
+
+func Test_ValidateStruct(t *testing.T) {
+	t.Run("Test_Valid_Struct", func(t *testing.T) {
+		err := validator.ValidateStruct(&createProductRequest{
+			Name:     "phone",
+			Email:    "shop@example.com",
+			Quantity: 3,
+			Status:   "active",
+			ShopId:   "9f2c7a3e-6b8d-4e5f-9a1c-3d4f8b2e6a90",
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Test_Every_Failed_Field_Is_Reported", func(t *testing.T) {
+		err := validator.ValidateStruct(&createProductRequest{
+			Name:     "ab",
+			Email:    "not-an-email",
+			Quantity: 0,
+			Status:   "deleted",
+			Tags:     []string{"a", "b", "c"},
+			ShopId:   "123",
+		})
+		var errs validator.ValidationErrors
+		require.True(t, errors.As(err, &errs))
+		fields := []string{}
+		for _, fieldErr := range errs {
+			fields = append(fields, fieldErr.Field)
+		}
+		require.Equal(t, []string{"name", "email", "quantity", "status", "tags", "ShopId"}, fields)
+	})
+
+	t.Run("Test_Invalid_Tag", func(t *testing.T) {
+		type badTag struct {
+			Name string `validate:"unknown"`
+		}
+		err := validator.ValidateStruct(badTag{})
+		require.ErrorContains(t, err, "unknown validation rule")
+	})
+}
+
+func Test_Validate(t *testing.T) {
+	t.Run("Test_Uses_Validate_Method_And_Returns_422", func(t *testing.T) {
+		err := validator.Validate(selfValidated{})
+		var appErr *shared.AppError
+		require.True(t, errors.As(err, &appErr))
+		require.Equal(t, http.StatusUnprocessableEntity, appErr.StatusCode)
+		require.Equal(t, []validator.FieldError{{Field: "id", Rule: "required", Message: "is required"}}, appErr.Details["fields"])
+	})
+
+	t.Run("Test_Required_Rule_Only_Fails_On_Zero", func(t *testing.T) {
+		require.Nil(t, validator.Required("count", 1))
+		require.NotNil(t, validator.Required("count", 0))
+	})
+}
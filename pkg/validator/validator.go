@@ -0,0 +1,168 @@
+package validator
+
+import (
+	"cmp"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hoangdaochuz/ecommerce-microservice-golang/shared"
+)
+
+// Validator is implemented by request messages that carry their own rules,
+// e.g. the Validate methods generated by proto2dgo.
+type Validator interface {
+	Validate() error
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, fieldErr := range v {
+		messages = append(messages, fmt.Sprintf("%s %s", fieldErr.Field, fieldErr.Message))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ToAppError turns the field errors into a 422 response listing every field.
+func (v ValidationErrors) ToAppError() *shared.AppError {
+	return shared.NewUnprocessableEntityError("validation failed").
+		WithDetails(map[string]any{"fields": []FieldError(v)}).
+		WithCause(v)
+}
+
+// Collect gathers the results of rule checks. It returns nil when every check
+// passed, so generated Validate methods can return it directly.
+func Collect(results ...*FieldError) error {
+	var errs ValidationErrors
+	for _, result := range results {
+		if result != nil {
+			errs = append(errs, *result)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Validate runs the rules of v: its Validate method when it has one, its
+// `validate` struct tags otherwise. Failures come back as a 422 AppError.
+func Validate(v any) error {
+	var err error
+	if validator, ok := v.(Validator); ok {
+		err = validator.Validate()
+	} else {
+		err = ValidateStruct(v)
+	}
+	if err == nil {
+		return nil
+	}
+	if errs, ok := err.(ValidationErrors); ok {
+		return errs.ToAppError()
+	}
+	return err
+}
+
+func newFieldError(field, rule, message string) *FieldError {
+	return &FieldError{
+		Field:   field,
+		Rule:    rule,
+		Message: message,
+	}
+}
+
+// Required fails on the zero value of T.
+func Required[T comparable](field string, value T) *FieldError {
+	var zero T
+	if value == zero {
+		return newFieldError(field, "required", "is required")
+	}
+	return nil
+}
+
+// Rules below skip empty values; combine them with Required when the field
+// must be present.
+
+func MinLen(field, value string, n int) *FieldError {
+	if value != "" && len([]rune(value)) < n {
+		return newFieldError(field, "min", fmt.Sprintf("must be at least %d characters", n))
+	}
+	return nil
+}
+
+func MaxLen(field, value string, n int) *FieldError {
+	if len([]rune(value)) > n {
+		return newFieldError(field, "max", fmt.Sprintf("must be at most %d characters", n))
+	}
+	return nil
+}
+
+func Min[T cmp.Ordered](field string, value, limit T) *FieldError {
+	if value < limit {
+		return newFieldError(field, "min", fmt.Sprintf("must be greater than or equal to %v", limit))
+	}
+	return nil
+}
+
+func Max[T cmp.Ordered](field string, value, limit T) *FieldError {
+	if value > limit {
+		return newFieldError(field, "max", fmt.Sprintf("must be less than or equal to %v", limit))
+	}
+	return nil
+}
+
+func MinItems(field string, count, n int) *FieldError {
+	if count < n {
+		return newFieldError(field, "min", fmt.Sprintf("must contain at least %d items", n))
+	}
+	return nil
+}
+
+func MaxItems(field string, count, n int) *FieldError {
+	if count > n {
+		return newFieldError(field, "max", fmt.Sprintf("must contain at most %d items", n))
+	}
+	return nil
+}
+
+func UUID(field, value string) *FieldError {
+	if value == "" {
+		return nil
+	}
+	if _, err := uuid.Parse(value); err != nil {
+		return newFieldError(field, "uuid", "must be a valid UUID")
+	}
+	return nil
+}
+
+func Email(field, value string) *FieldError {
+	if value == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(value); err != nil {
+		return newFieldError(field, "email", "must be a valid email address")
+	}
+	return nil
+}
+
+func OneOf[T comparable](field string, value T, allowed ...T) *FieldError {
+	var zero T
+	if value == zero {
+		return nil
+	}
+	for _, item := range allowed {
+		if value == item {
+			return nil
+		}
+	}
+	return newFieldError(field, "oneof", fmt.Sprintf("must be one of %v", allowed))
+}
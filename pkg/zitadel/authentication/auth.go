@@ -2,7 +2,6 @@ package zitadel_authentication
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -48,16 +47,8 @@ func (a *Auth[T]) GetAuthEncryptKey() string {
 	return a.Config.EncryptKey
 }
 
-func getDecodeEncryptKey(key string) (string, error) {
-	bytes, err := base64.StdEncoding.DecodeString(key)
-	if err != nil {
-		return "", err
-	}
-	return string(bytes), nil
-}
-
 func (a *Auth[T]) SetSessionCookie(cookieHandler CookieHandler, sessionId, cookiePath string) error {
-	decodeEncryptKey, err := getDecodeEncryptKey(a.Config.EncryptKey)
+	decodeEncryptKey, err := zitadel_pkg.DecodeEncryptKey(a.Config.EncryptKey)
 	if err != nil {
 		return err
 	}
@@ -85,7 +76,7 @@ func (a *Auth[T]) logoutUrl(idToken, postLogoutUrl string) (string, error) {
 	state := authentication.State{
 		RequestedURI: "",
 	}
-	decodeEncryptKey, err := getDecodeEncryptKey(a.Config.EncryptKey)
+	decodeEncryptKey, err := zitadel_pkg.DecodeEncryptKey(a.Config.EncryptKey)
 	if err != nil {
 		return "", err
 	}
@@ -118,7 +109,7 @@ func (a *Auth[T]) LogoutUrl(cookieHanlder CookieHandler, cookiesPath, idToken, p
 		return "", err
 	}
 
-	decodeEncryptKey, err := getDecodeEncryptKey(a.Config.EncryptKey)
+	decodeEncryptKey, err := zitadel_pkg.DecodeEncryptKey(a.Config.EncryptKey)
 	if err != nil {
 		return "", err
 	}
@@ -204,7 +195,7 @@ func (a *Auth[T]) AuthCodeUrl(r *http.Request, w http.ResponseWriter, postLoginS
 		RequestedURI: postLoginSuccessURI,
 	}
 
-	decodeEnctyptKey, err := getDecodeEncryptKey(a.Config.EncryptKey)
+	decodeEnctyptKey, err := zitadel_pkg.DecodeEncryptKey(a.Config.EncryptKey)
 	if err != nil {
 		return "", err
 	}
@@ -386,7 +377,7 @@ func (a *Auth[T]) Callback(ctx context.Context, r *http.Request, w http.Response
 		return fmt.Errorf("fail to store user info to session store")
 	}
 	// redirect to entry point of application
-	decodeEncryptKey, err := getDecodeEncryptKey(a.Config.EncryptKey)
+	decodeEncryptKey, err := zitadel_pkg.DecodeEncryptKey(a.Config.EncryptKey)
 	if err != nil {
 		return err
 	}
@@ -403,7 +394,7 @@ func (a *Auth[T]) GetCurrentUser(ctx context.Context, cookieHandler CookieHandle
 	if err != nil {
 		return nil, err
 	}
-	decodeEncryptKey, err := getDecodeEncryptKey(a.Config.EncryptKey)
+	decodeEncryptKey, err := zitadel_pkg.DecodeEncryptKey(a.Config.EncryptKey)
 	if err != nil {
 		return nil, err
 	}
@@ -15,6 +15,8 @@ type ZitadelClaim struct {
 	PreferredUsername            string                       `json:"preferred_username"`
 	UrnZitadelIAMOrgProjectRoles map[string]map[string]string `json:"urn:zitadel:iam:org:project:roles"`
 	Metadata                     map[string]string            `json:"urn:zitadel:iam:user:metadata"`
+	ResourceOwnerId              string                       `json:"urn:zitadel:iam:user:resourceowner:id"`
+	Scope                        string                       `json:"scope"`
 	IdToken                      string                       `json:"id_token"`
 	Token                        string                       `json:"token"`
 	Name                         string                       `json:"name"`
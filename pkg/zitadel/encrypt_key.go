@@ -0,0 +1,14 @@
+package zitadel_pkg
+
+import "encoding/base64"
+
+// DecodeEncryptKey returns the AES key of the session cookie. The config holds
+// it base64 encoded, so the auth service that sets the cookie and the gateway
+// that reads it must both decode it here.
+func DecodeEncryptKey(key string) (string, error) {
+	bytes, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
@@ -0,0 +1,135 @@
+package shared
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// AppError is the typed error returned by service handlers. It is carried back
+// to the gateway inside custom_nats.Response, so the client gets the status
+// code picked by the service instead of a blanket 500.
+type AppError struct {
+	Code       ErrorType      `json:"code"`
+	StatusCode int            `json:"status_code"`
+	Message    string         `json:"error"` // keep the "error" key used by older clients
+	Details    map[string]any `json:"details,omitempty"`
+	Retryable  bool           `json:"retryable"`
+	cause      error
+}
+
+func NewAppError(code ErrorType, message string) *AppError {
+	statusCode, ok := StatusCodeMap[code]
+	if !ok {
+		statusCode = StatusCodeMap[Internal_Server_Err]
+	}
+	return &AppError{
+		Code:       code,
+		StatusCode: statusCode,
+		Message:    message,
+		Retryable:  code == Service_Unavailable || code == Gateway_Timeout || code == Too_Many_Requests,
+	}
+}
+
+func NewBadRequestError(message string) *AppError {
+	return NewAppError(Bad_Request, message)
+}
+
+func NewUnauthorizedError(message string) *AppError {
+	return NewAppError(Unauthorized, message)
+}
+
+func NewForbiddenError(message string) *AppError {
+	return NewAppError(Forbidden, message)
+}
+
+func NewNotFoundError(message string) *AppError {
+	return NewAppError(Not_Found, message)
+}
+
+func NewMethodNotAllowedError(message string) *AppError {
+	return NewAppError(Method_Not_Allowed, message)
+}
+
+func NewConflictError(message string) *AppError {
+	return NewAppError(Conflict, message)
+}
+
+func NewPayloadTooLargeError(message string) *AppError {
+	return NewAppError(Payload_Too_Large, message)
+}
+
+func NewUnsupportedMediaError(message string) *AppError {
+	return NewAppError(Unsupported_Media, message)
+}
+
+func NewUnprocessableEntityError(message string) *AppError {
+	return NewAppError(Unprocessable_Entity, message)
+}
+
+func NewTooManyRequestsError(message string) *AppError {
+	return NewAppError(Too_Many_Requests, message)
+}
+
+func NewInternalServerError(message string) *AppError {
+	return NewAppError(Internal_Server_Err, message)
+}
+
+func NewServiceUnavailableError(message string) *AppError {
+	return NewAppError(Service_Unavailable, message)
+}
+
+func NewGatewayTimeoutError(message string) *AppError {
+	return NewAppError(Gateway_Timeout, message)
+}
+
+func (e *AppError) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.cause)
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.cause
+}
+
+// WithCause keeps the underlying error for logging and errors.Is/As. The
+// cause is never serialized, so internal details do not leak to clients.
+func (e *AppError) WithCause(err error) *AppError {
+	e.cause = err
+	return e
+}
+
+func (e *AppError) WithDetails(details map[string]any) *AppError {
+	e.Details = details
+	return e
+}
+
+func (e *AppError) WithRetryable(retryable bool) *AppError {
+	e.Retryable = retryable
+	return e
+}
+
+// ToJSON returns the body sent to the client for this error.
+func (e *AppError) ToJSON() []byte {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return []byte(fmt.Sprintf(`{"error":%q}`, e.Message))
+	}
+	return body
+}
+
+// ToAppError returns err as an *AppError. Errors that are not typed become an
+// Internal_Server_Err with a generic message, so internal details never reach
+// the client; the original error is kept as its cause.
+func ToAppError(err error) *AppError {
+	if err == nil {
+		return nil
+	}
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return NewInternalServerError("internal server error").WithCause(err)
+}
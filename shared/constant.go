@@ -3,4 +3,5 @@ package shared
 const (
 	NATS_CIRCUIT_BREAKER    = "NATS_CIRCUIT_BREAKER"
 	ZITADEL_CIRCUIT_BREAKER = "ZITADEL_CIRCUIT_BREAKER"
+	REDIS_CIRCUIT_BREAKER   = "REDIS_CIRCUIT_BREAKER"
 )
@@ -6,4 +6,10 @@ const (
 	HTTPRequest_ContextKey  ContextKey = "httpRequest"
 	HTTPResponse_ContextKey ContextKey = "httpResponse"
 	UserId_ContextKey       ContextKey = "userId"
+	NatsResponse_ContextKey ContextKey = "natsResponse"
+	RouteInfo_ContextKey    ContextKey = "routeInfo"
+	Identity_ContextKey     ContextKey = "identity"
+	// SignedIdentity_ContextKey keeps the signed identity header so it can be
+	// forwarded on calls to other services.
+	SignedIdentity_ContextKey ContextKey = "signedIdentity"
 )
@@ -3,8 +3,19 @@ package shared
 type ErrorType string
 
 const (
-	Not_Found           ErrorType = "Not_Found"
-	Internal_Server_Err ErrorType = "Internal_Server_Err"
+	Bad_Request          ErrorType = "Bad_Request"
+	Unauthorized         ErrorType = "Unauthorized"
+	Forbidden            ErrorType = "Forbidden"
+	Not_Found            ErrorType = "Not_Found"
+	Method_Not_Allowed   ErrorType = "Method_Not_Allowed"
+	Conflict             ErrorType = "Conflict"
+	Payload_Too_Large    ErrorType = "Payload_Too_Large"
+	Unsupported_Media    ErrorType = "Unsupported_Media"
+	Unprocessable_Entity ErrorType = "Unprocessable_Entity"
+	Too_Many_Requests    ErrorType = "Too_Many_Requests"
+	Internal_Server_Err  ErrorType = "Internal_Server_Err"
+	Service_Unavailable  ErrorType = "Service_Unavailable"
+	Gateway_Timeout      ErrorType = "Gateway_Timeout"
 )
 
 type ErrorResponse struct {
@@ -1,6 +1,28 @@
 package shared
 
 var StatusCodeMap = map[ErrorType]int{
-	Not_Found:           404,
-	Internal_Server_Err: 500,
+	Bad_Request:          400,
+	Unauthorized:         401,
+	Forbidden:            403,
+	Not_Found:            404,
+	Method_Not_Allowed:   405,
+	Conflict:             409,
+	Payload_Too_Large:    413,
+	Unsupported_Media:    415,
+	Unprocessable_Entity: 422,
+	Too_Many_Requests:    429,
+	Internal_Server_Err:  500,
+	Service_Unavailable:  503,
+	Gateway_Timeout:      504,
+}
+
+// ErrorTypeFromStatusCode is the reverse lookup of StatusCodeMap. Unknown
+// codes fall back to Internal_Server_Err.
+func ErrorTypeFromStatusCode(statusCode int) ErrorType {
+	for errType, code := range StatusCodeMap {
+		if code == statusCode {
+			return errType
+		}
+	}
+	return Internal_Server_Err
 }